@@ -0,0 +1,360 @@
+// Command migratevariants moves products from the old sizes, colors and stock
+// fields to options and variants, and points carts and orders at the new SKUs.
+//
+// Usage: go run ./cmd/migratevariants [-dry-run]
+//
+// Every size and color combination becomes a variant. Stock used to be kept per
+// size only, so it carries over when a product has at most one color; otherwise the
+// variants start at zero and the old stock is logged to be split by hand. Products
+// that already have variants are skipped, so it is safe to run again.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/config"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/database"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// The sizes products could have before options were configurable, smallest first
+var legacySizes = []string{"S", "M", "L", "XL"}
+
+type legacyProduct struct {
+	ID     primitive.ObjectID `bson:"_id"`
+	Name   string             `bson:"name"`
+	Sizes  []string           `bson:"sizes"`
+	Colors []string           `bson:"colors"`
+	Stock  map[string]int     `bson:"stock"`
+}
+
+type legacyCartItem struct {
+	ProductID primitive.ObjectID `bson:"productId"`
+	Size      string             `bson:"size"`
+	SKU       string             `bson:"sku"`
+	Quantity  int                `bson:"quantity"`
+}
+
+type legacyOrderItem struct {
+	ProductID primitive.ObjectID `bson:"productId"`
+	Size      string             `bson:"size"`
+	SKU       string             `bson:"sku"`
+	Options   map[string]string  `bson:"options"`
+	Quantity  int                `bson:"quantity"`
+	Price     string             `bson:"price"`
+}
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "log what would change without writing")
+	flag.Parse()
+
+	config.LoadEnv()
+	if err := database.ConnectDB(); err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	variants, err := migrateProducts(ctx, *dryRun)
+	if err != nil {
+		log.Fatal("Failed to migrate products:", err)
+	}
+	if err := migrateCarts(ctx, variants, *dryRun); err != nil {
+		log.Fatal("Failed to migrate carts:", err)
+	}
+	if err := migrateOrders(ctx, variants, *dryRun); err != nil {
+		log.Fatal("Failed to migrate orders:", err)
+	}
+
+	if *dryRun {
+		log.Println("🧪 Dry run, nothing was written")
+		return
+	}
+	if err := utils.EnsureProductIndexes(ctx); err != nil {
+		log.Fatal("Failed to create product indexes:", err)
+	}
+	log.Println("✅ Variant migration complete")
+}
+
+// migrateProducts converts every product without variants and returns the variants
+// of all products, for resolving the sizes in carts and orders
+func migrateProducts(ctx context.Context, dryRun bool) (map[primitive.ObjectID]models.Product, error) {
+	collection := database.DB.Collection("products")
+
+	var legacy []legacyProduct
+	cursor, err := collection.Find(ctx, bson.M{"variants": bson.M{"$exists": false}})
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(ctx, &legacy); err != nil {
+		return nil, err
+	}
+
+	migrated := make(map[primitive.ObjectID]models.Product, len(legacy))
+	for _, product := range legacy {
+		options, variants := buildVariants(product)
+		migrated[product.ID] = models.Product{ID: product.ID, Options: options, Variants: variants}
+
+		if len(product.Colors) > 1 && len(product.Stock) > 0 {
+			log.Printf("⚠️ %s (%s) has %d colors; its variants start with no stock, set it from the old per-size stock %v",
+				product.Name, product.ID.Hex(), len(product.Colors), product.Stock)
+		}
+		log.Printf("📦 %s (%s): %d variants", product.Name, product.ID.Hex(), len(variants))
+		if dryRun {
+			continue
+		}
+
+		_, err := collection.UpdateOne(
+			ctx,
+			bson.M{"_id": product.ID, "variants": bson.M{"$exists": false}},
+			bson.M{
+				"$set":   bson.M{"options": options, "variants": variants, "updatedAt": time.Now()},
+				"$unset": bson.M{"sizes": "", "colors": "", "stock": ""},
+				"$inc":   bson.M{"version": 1},
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Products migrated by an earlier run still resolve the carts and orders left over
+	var existing []models.Product
+	cursor, err = collection.Find(ctx, bson.M{"variants": bson.M{"$exists": true}})
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(ctx, &existing); err != nil {
+		return nil, err
+	}
+	for _, product := range existing {
+		if _, ok := migrated[product.ID]; !ok {
+			migrated[product.ID] = product
+		}
+	}
+	return migrated, nil
+}
+
+// buildVariants turns the sizes and colors into options and their cross product
+// into variants
+func buildVariants(product legacyProduct) ([]models.ProductOption, []models.ProductVariant) {
+	sizes := productSizes(product)
+
+	var options []models.ProductOption
+	if len(sizes) > 0 {
+		options = append(options, models.ProductOption{Name: "Size", Values: sizes})
+	}
+	if len(product.Colors) > 0 {
+		options = append(options, models.ProductOption{Name: "Color", Values: product.Colors})
+	}
+
+	// An empty value stands for an option the product doesn't have
+	if len(sizes) == 0 {
+		sizes = []string{""}
+	}
+	colors := product.Colors
+	if len(colors) == 0 {
+		colors = []string{""}
+	}
+
+	var variants []models.ProductVariant
+	used := map[string]bool{}
+	for _, size := range sizes {
+		for _, color := range colors {
+			values := map[string]string{}
+			var skuValues []string
+			if size != "" {
+				values["Size"] = size
+				skuValues = append(skuValues, size)
+			}
+			if color != "" {
+				values["Color"] = color
+				skuValues = append(skuValues, color)
+			}
+
+			stock := 0
+			if len(product.Colors) <= 1 {
+				stock = product.Stock[size]
+			}
+
+			generated := utils.GenerateSKU(product.ID, skuValues)
+			sku := uniqueSKU(generated, used)
+			if sku != generated {
+				log.Printf("⚠️ %s (%s): %v would reuse SKU %s, using %s", product.Name, product.ID.Hex(), skuValues, generated, sku)
+			}
+			used[sku] = true
+
+			variants = append(variants, models.ProductVariant{
+				SKU:     sku,
+				Options: values,
+				Stock:   stock,
+			})
+		}
+	}
+	return options, variants
+}
+
+// uniqueSKU numbers a SKU that another variant of the product already has, which
+// happens when values only differ in punctuation, e.g. "M/L" and "ML", or are cut
+// to the same prefix
+func uniqueSKU(sku string, used map[string]bool) string {
+	if !used[sku] {
+		return sku
+	}
+	for n := 2; ; n++ {
+		suffix := "-" + strconv.Itoa(n)
+		base := sku
+		if len(base)+len(suffix) > utils.MaxSKULength {
+			base = strings.TrimRight(base[:utils.MaxSKULength-len(suffix)], "-")
+		}
+		if candidate := base + suffix; !used[candidate] {
+			return candidate
+		}
+	}
+}
+
+// productSizes returns the listed sizes and those that only had stock, in size order
+func productSizes(product legacyProduct) []string {
+	seen := map[string]bool{}
+	for _, size := range product.Sizes {
+		seen[size] = true
+	}
+	for size := range product.Stock {
+		seen[size] = true
+	}
+
+	var sizes []string
+	for _, size := range legacySizes {
+		if seen[size] {
+			sizes = append(sizes, size)
+			delete(seen, size)
+		}
+	}
+	var others []string
+	for size := range seen {
+		if size != "" {
+			others = append(others, size)
+		}
+	}
+	sort.Strings(others)
+	return append(sizes, others...)
+}
+
+// resolveSKU finds the variant an old size referred to. It fails when the product has
+// several colors in that size, since the color was never recorded.
+func resolveSKU(product models.Product, size string) (models.ProductVariant, bool) {
+	var match []models.ProductVariant
+	for _, variant := range product.Variants {
+		if value, ok := variant.Options["Size"]; !ok || value == size {
+			match = append(match, variant)
+		}
+	}
+	if len(match) != 1 {
+		return models.ProductVariant{}, false
+	}
+	return match[0], true
+}
+
+// migrateCarts moves cart items to SKUs and drops those that can't be resolved
+func migrateCarts(ctx context.Context, products map[primitive.ObjectID]models.Product, dryRun bool) error {
+	collection := database.DB.Collection("carts")
+
+	cursor, err := collection.Find(ctx, bson.M{"items.size": bson.M{"$exists": true}})
+	if err != nil {
+		return err
+	}
+	var carts []struct {
+		ID    primitive.ObjectID `bson:"_id"`
+		Items []legacyCartItem   `bson:"items"`
+	}
+	if err := cursor.All(ctx, &carts); err != nil {
+		return err
+	}
+
+	for _, cart := range carts {
+		items := []models.CartItem{}
+		for _, item := range cart.Items {
+			sku := item.SKU
+			if sku == "" {
+				variant, ok := resolveSKU(products[item.ProductID], item.Size)
+				if !ok {
+					log.Printf("🗑️ Dropping %s size %q from cart %s, its variant is ambiguous or gone", item.ProductID.Hex(), item.Size, cart.ID.Hex())
+					continue
+				}
+				sku = variant.SKU
+			}
+			items = append(items, models.CartItem{ProductID: item.ProductID, SKU: sku, Quantity: item.Quantity})
+		}
+		if dryRun {
+			continue
+		}
+
+		_, err := collection.UpdateOne(ctx, bson.M{"_id": cart.ID}, bson.M{"$set": bson.M{"items": items, "updatedAt": time.Now()}})
+		if err != nil {
+			return err
+		}
+	}
+	log.Printf("🛒 Migrated %d carts", len(carts))
+	return nil
+}
+
+// migrateOrders records the size as an option on each order item, with the SKU where
+// it can be resolved. Orders are history, so nothing is dropped.
+func migrateOrders(ctx context.Context, products map[primitive.ObjectID]models.Product, dryRun bool) error {
+	collection := database.DB.Collection("orders")
+
+	cursor, err := collection.Find(ctx, bson.M{"items.size": bson.M{"$exists": true}})
+	if err != nil {
+		return err
+	}
+	var orders []struct {
+		ID    primitive.ObjectID `bson:"_id"`
+		Items []legacyOrderItem  `bson:"items"`
+	}
+	if err := cursor.All(ctx, &orders); err != nil {
+		return err
+	}
+
+	for _, order := range orders {
+		items := make([]models.OrderItem, len(order.Items))
+		for i, item := range order.Items {
+			items[i] = models.OrderItem{
+				ProductID: item.ProductID,
+				SKU:       item.SKU,
+				Options:   item.Options,
+				Quantity:  item.Quantity,
+				Price:     item.Price,
+			}
+			if item.Size == "" {
+				continue
+			}
+			if items[i].Options == nil {
+				items[i].Options = map[string]string{"Size": item.Size}
+			}
+			if items[i].SKU == "" {
+				if variant, ok := resolveSKU(products[item.ProductID], item.Size); ok {
+					items[i].SKU = variant.SKU
+				}
+			}
+		}
+		if dryRun {
+			continue
+		}
+
+		if _, err := collection.UpdateOne(ctx, bson.M{"_id": order.ID}, bson.M{"$set": bson.M{"items": items}}); err != nil {
+			return err
+		}
+	}
+	log.Printf("🧾 Migrated %d orders", len(orders))
+	return nil
+}
@@ -0,0 +1,82 @@
+// Command promoteadmin grants the admin role to an existing user.
+//
+// Usage: go run ./cmd/promoteadmin -email admin@example.com [-force]
+//
+// It refuses to run once an admin exists unless -force is given; later
+// admins should be promoted by an existing admin through
+// PUT /api/admin/users/:id/roles/admin.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/config"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/database"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func main() {
+	email := flag.String("email", "", "email of the user to promote")
+	force := flag.Bool("force", false, "promote even if an admin already exists")
+	flag.Parse()
+
+	if *email == "" {
+		log.Fatal("-email is required")
+	}
+
+	config.LoadEnv()
+	if err := database.ConnectDB(); err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	users := database.DB.Collection("users")
+
+	if !*force {
+		admins, err := users.CountDocuments(ctx, bson.M{"roles": models.RoleAdmin})
+		if err != nil {
+			log.Fatal("Failed to count admins:", err)
+		}
+		if admins > 0 {
+			log.Fatal("An admin already exists, use -force to promote another user")
+		}
+	}
+
+	var user models.User
+	err := users.FindOneAndUpdate(
+		ctx,
+		bson.M{"email": strings.TrimSpace(*email)},
+		bson.M{
+			"$addToSet": bson.M{"roles": models.RoleAdmin},
+			"$set":      bson.M{"updatedAt": time.Now()},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		log.Fatalf("No user with email %s", *email)
+	}
+	if err != nil {
+		log.Fatal("Failed to promote user:", err)
+	}
+
+	// Written before exiting; AppendAudit logs its own failures and the promotion has already happened
+	utils.AppendAudit(ctx, models.AuditEntry{
+		ActorType:  models.AuditActorSystem,
+		Action:     models.AuditRoleGranted,
+		TargetType: models.AuditTargetUser,
+		TargetID:   user.ID.Hex(),
+		Metadata:   map[string]string{"role": models.RoleAdmin, "via": "promoteadmin"},
+	})
+
+	log.Printf("👑 %s is now an admin; new tokens will carry the role", *email)
+}
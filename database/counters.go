@@ -0,0 +1,27 @@
+package database
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// NextSequence atomically increments and returns the named counter
+func NextSequence(ctx context.Context, name string) (uint64, error) {
+	var counter struct {
+		Seq int64 `bson:"seq"`
+	}
+
+	err := DB.Collection("counters").FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": name},
+		bson.M{"$inc": bson.M{"seq": int64(1)}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&counter)
+	if err != nil {
+		return 0, err
+	}
+
+	return uint64(counter.Seq), nil
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/database"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/utils"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type AccountDeletionRequest struct {
+	Confirm  string `json:"confirm"`            // Must equal the account email
+	Password string `json:"password,omitempty"` // Required when the account has a password
+}
+
+// ExportUserData downloads everything stored about the current user as JSON,
+// or as a ZIP archive with ?format=zip
+func ExportUserData(c echo.Context) error {
+	userID := c.Get("userID").(primitive.ObjectID)
+
+	export, err := utils.BuildUserExport(c.Request().Context(), userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to export data"})
+	}
+
+	filename := "0xmart-export-" + userID.Hex() + "-" + export.ExportedAt.Format("20060102")
+
+	if c.QueryParam("format") == "zip" {
+		var buf bytes.Buffer
+		if err := utils.WriteExportZip(&buf, export); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to export data"})
+		}
+		c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="`+filename+`.zip"`)
+		return c.Blob(http.StatusOK, "application/zip", buf.Bytes())
+	}
+
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="`+filename+`.json"`)
+	return c.JSON(http.StatusOK, export)
+}
+
+// RequestAccountDeletion schedules the current user's account for deletion after
+// the grace period
+func RequestAccountDeletion(c echo.Context) error {
+	userID := c.Get("userID").(primitive.ObjectID)
+
+	var req AccountDeletionRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	ctx := c.Request().Context()
+
+	var user models.User
+	if err := database.DB.Collection("users").FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
+	}
+
+	if !strings.EqualFold(strings.TrimSpace(req.Confirm), user.Email) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Type your email address to confirm"})
+	}
+
+	if user.Password != "" {
+		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Password is incorrect"})
+		}
+	}
+
+	if user.DeleteAt != nil {
+		return c.JSON(http.StatusOK, map[string]interface{}{"deleteAt": user.DeleteAt})
+	}
+
+	deleteAt, err := utils.ScheduleAccountDeletion(ctx, user)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to schedule deletion"})
+	}
+	auditUser(c, userID, models.AuditDeletionRequested, map[string]string{"deleteAt": deleteAt.UTC().Format(time.RFC3339)})
+
+	return c.JSON(http.StatusAccepted, map[string]interface{}{"deleteAt": deleteAt})
+}
+
+// CancelAccountDeletion keeps the current user's account
+func CancelAccountDeletion(c echo.Context) error {
+	userID := c.Get("userID").(primitive.ObjectID)
+
+	if err := utils.CancelAccountDeletion(c.Request().Context(), userID); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to cancel deletion"})
+	}
+	auditUser(c, userID, models.AuditDeletionCancelled, nil)
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Account deletion cancelled"})
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/utils"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	RateLimit int        `json:"rateLimit,omitempty"` // Requests per minute, defaults to API_KEY_RATE_LIMIT
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// CreateAPIKey issues a key for an integration. The key is only returned here.
+func CreateAPIKey(c echo.Context) error {
+	userID := c.Get("userID").(primitive.ObjectID)
+
+	var req CreateAPIKeyRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Name is required"})
+	}
+	if len(req.Scopes) == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "At least one scope is required"})
+	}
+	for _, scope := range req.Scopes {
+		if !models.IsValidAPIKeyScope(scope) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid scope " + scope})
+		}
+	}
+	if req.RateLimit < 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Rate limit must be positive"})
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Expiry must be in the future"})
+	}
+
+	key, raw, err := utils.CreateAPIKey(c.Request().Context(), models.APIKey{
+		Name:      req.Name,
+		Scopes:    req.Scopes,
+		RateLimit: req.RateLimit,
+		ExpiresAt: req.ExpiresAt,
+		CreatedBy: userID,
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create API key"})
+	}
+	audit(c, models.AuditEntry{
+		Action:     models.AuditAPIKeyCreated,
+		TargetType: models.AuditTargetAPIKey,
+		TargetID:   key.ID.Hex(),
+		Changes:    utils.AuditDiff(nil, key),
+	})
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"apiKey": key,
+		"key":    raw,
+	})
+}
+
+// GetAPIKeys lists every API key, including revoked ones
+func GetAPIKeys(c echo.Context) error {
+	keys, err := utils.ListAPIKeys(c.Request().Context())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch API keys"})
+	}
+	return c.JSON(http.StatusOK, keys)
+}
+
+// RevokeAPIKey disables an API key immediately
+func RevokeAPIKey(c echo.Context) error {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid API key ID"})
+	}
+
+	key, err := utils.RevokeAPIKey(c.Request().Context(), id)
+	if err == utils.ErrInvalidAPIKey {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "API key not found or already revoked"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to revoke API key"})
+	}
+	audit(c, models.AuditEntry{
+		Action:     models.AuditAPIKeyRevoked,
+		TargetType: models.AuditTargetAPIKey,
+		TargetID:   key.ID.Hex(),
+		Metadata:   map[string]string{"name": key.Name, "prefix": key.Prefix},
+	})
+
+	return c.JSON(http.StatusOK, key)
+}
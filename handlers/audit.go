@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/database"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/utils"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 200
+)
+
+// audit records an action with the request's IP, user agent and request ID. The actor
+// is the authenticated user or API key unless the entry names one.
+func audit(c echo.Context, entry models.AuditEntry) {
+	// RecordAudit logs its own failures and the action has already happened
+	utils.RecordAudit(requestAuditEntry(c, entry))
+}
+
+// requestAuditEntry fills in the request details of an entry, for entries recorded
+// after the handler has returned
+func requestAuditEntry(c echo.Context, entry models.AuditEntry) models.AuditEntry {
+	if entry.ActorType == "" {
+		if userID, ok := c.Get("userID").(primitive.ObjectID); ok {
+			entry.ActorType = models.AuditActorUser
+			entry.ActorID = userID.Hex()
+		} else if keyID, ok := c.Get("apiKeyID").(primitive.ObjectID); ok {
+			entry.ActorType = models.AuditActorAPIKey
+			entry.ActorID = keyID.Hex()
+		}
+	}
+	entry.IP = c.RealIP()
+	entry.UserAgent = c.Request().UserAgent()
+	entry.RequestID = c.Response().Header().Get(echo.HeaderXRequestID)
+	return entry
+}
+
+// auditUser records an action a user took on their own account
+func auditUser(c echo.Context, userID primitive.ObjectID, action string, metadata map[string]string) {
+	audit(c, models.AuditEntry{
+		ActorType:  models.AuditActorUser,
+		ActorID:    userID.Hex(),
+		Action:     action,
+		TargetType: models.AuditTargetUser,
+		TargetID:   userID.Hex(),
+		Metadata:   metadata,
+	})
+}
+
+// GetAuditLog lists audit entries, newest first. Filters: actorId, actorType, action
+// (comma separated), targetType, targetId, ip, requestId, from and to (RFC 3339).
+// Pages continue with ?beforeSeq= set to the previous page's nextBeforeSeq.
+func GetAuditLog(c echo.Context) error {
+	filter := bson.M{}
+	for param, field := range map[string]string{
+		"actorId":    "actorId",
+		"actorType":  "actorType",
+		"targetType": "targetType",
+		"targetId":   "targetId",
+		"ip":         "ip",
+		"requestId":  "requestId",
+	} {
+		if value := c.QueryParam(param); value != "" {
+			filter[field] = value
+		}
+	}
+
+	if action := c.QueryParam("action"); action != "" {
+		filter["action"] = bson.M{"$in": strings.Split(action, ",")}
+	}
+
+	timestamp := bson.M{}
+	for param, operator := range map[string]string{"from": "$gte", "to": "$lte"} {
+		value := c.QueryParam(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid " + param + " time, use RFC 3339"})
+		}
+		timestamp[operator] = t
+	}
+	if len(timestamp) > 0 {
+		filter["timestamp"] = timestamp
+	}
+
+	if value := c.QueryParam("beforeSeq"); value != "" {
+		beforeSeq, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid beforeSeq"})
+		}
+		filter["seq"] = bson.M{"$lt": beforeSeq}
+	}
+
+	limit := defaultAuditPageSize
+	if value := c.QueryParam("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid limit"})
+		}
+		if n > maxAuditPageSize {
+			n = maxAuditPageSize
+		}
+		limit = n
+	}
+
+	ctx := c.Request().Context()
+	cursor, err := database.DB.Collection("audit_log").Find(
+		ctx,
+		filter,
+		options.Find().SetSort(bson.M{"seq": -1}).SetLimit(int64(limit)),
+	)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch audit log"})
+	}
+	defer cursor.Close(ctx)
+
+	entries := []models.AuditEntry{}
+	if err := cursor.All(ctx, &entries); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to decode audit log"})
+	}
+
+	response := map[string]interface{}{"entries": entries}
+	if len(entries) == limit {
+		response["nextBeforeSeq"] = entries[len(entries)-1].Seq
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+// VerifyAuditLog checks the audit log's hash chain for edited or removed entries
+func VerifyAuditLog(c echo.Context) error {
+	result, err := utils.VerifyAuditChain(c.Request().Context())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to verify audit log"})
+	}
+	return c.JSON(http.StatusOK, result)
+}
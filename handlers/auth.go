@@ -2,14 +2,12 @@ package handlers
 
 import (
 	"net/http"
-	"time"
 
 	"github.com/Madhav-Gupta-28/0xmart-backend-go/database"
 	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
 	"github.com/Madhav-Gupta-28/0xmart-backend-go/utils"
 	"github.com/labstack/echo/v4"
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -24,82 +22,42 @@ func NextAuthLogin(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
 	}
 
+	ctx := c.Request().Context()
+	if throttled, err := loginThrottled(ctx, c, credentials.Email); throttled {
+		return err
+	}
+
 	var user models.User
 	err := database.DB.Collection("users").FindOne(
-		c.Request().Context(),
+		ctx,
 		bson.M{"email": credentials.Email},
 	).Decode(&user)
 
 	if err != nil {
-		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid credentials"})
+		return loginFailed(ctx, c, credentials.Email, "Invalid credentials")
 	}
 
 	// Compare password
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(credentials.Password)); err != nil {
-		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid credentials"})
-	}
-
-	// Generate JWT token
-	token, err := utils.GenerateJWT(user.ID.Hex())
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate token"})
+		return loginFailed(ctx, c, credentials.Email, "Invalid credentials")
 	}
+	loginSucceeded(ctx, credentials.Email)
 
-	return c.JSON(http.StatusOK, map[string]interface{}{
-		"user":  user,
-		"token": token,
-	})
-}
-
-// NextAuthCallback handles OAuth callbacks from NextAuth.js
-func NextAuthCallback(c echo.Context) error {
-	var userData struct {
-		Email      string `json:"email"`
-		Name       string `json:"name"`
-		Image      string `json:"image"`
-		Provider   string `json:"provider"`
-		ProviderId string `json:"providerId"`
-	}
-
-	if err := c.Bind(&userData); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
-	}
-
-	// Check if user exists
-	var user models.User
-	err := database.DB.Collection("users").FindOne(
-		c.Request().Context(),
-		bson.M{"email": userData.Email},
-	).Decode(&user)
-
-	if err != nil {
-		// Create new user if not exists
-		user = models.User{
-			ID:            primitive.NewObjectID(),
-			Email:         userData.Email,
-			Name:          userData.Name,
-			Image:         userData.Image,
-			Provider:      userData.Provider,
-			ProviderId:    userData.ProviderId,
-			EmailVerified: true,
-			CreatedAt:     time.Now(),
-			UpdatedAt:     time.Now(),
-		}
-
-		_, err = database.DB.Collection("users").InsertOne(c.Request().Context(), user)
-		if err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create user"})
-		}
+	if challenged, err := twoFactorChallenge(c, user); challenged {
+		return err
 	}
 
-	// Generate JWT token
-	token, err := utils.GenerateJWT(user.ID.Hex())
+	// Issue access and refresh tokens
+	tokens, err := utils.IssueTokenPair(ctx, user.ID, sessionInfo(c, false))
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate token"})
 	}
+	auditLogin(c, user.ID, "password")
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"user":  user,
-		"token": token,
+		"user":         user,
+		"token":        tokens.AccessToken,
+		"refreshToken": tokens.RefreshToken,
+		"expiresIn":    tokens.ExpiresIn,
 	})
 }
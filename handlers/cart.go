@@ -7,6 +7,7 @@ import (
 
 	"github.com/Madhav-Gupta-28/0xmart-backend-go/database"
 	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/utils"
 	"github.com/labstack/echo/v4"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -19,13 +20,19 @@ func AddToCart(c echo.Context) error {
 
 	var req struct {
 		ProductID string `json:"productId"`
-		Size      string `json:"size"`
+		SKU       string `json:"sku"`
 		Quantity  int    `json:"quantity"`
 	}
 
 	if err := c.Bind(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request format"})
 	}
+	if req.SKU == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "SKU is required"})
+	}
+	if req.Quantity < 1 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Quantity must be at least 1"})
+	}
 
 	productID, err := primitive.ObjectIDFromHex(req.ProductID)
 	if err != nil || productID.IsZero() {
@@ -36,12 +43,22 @@ func AddToCart(c echo.Context) error {
 	var product models.Product
 	err = database.DB.Collection("products").FindOne(
 		c.Request().Context(),
-		bson.M{"_id": productID},
+		availableProducts(bson.M{"_id": productID}),
 	).Decode(&product)
 
 	if err != nil {
 		return c.JSON(http.StatusNotFound, map[string]string{"error": "Product not found"})
 	}
+	if _, ok := product.Variant(req.SKU); !ok {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Variant not found"})
+	}
+
+	// Token-gated products can only be added by holders
+	if product.Gate != nil {
+		if status, msg := checkTokenGate(c.Request().Context(), userID, product); status != http.StatusOK {
+			return c.JSON(status, map[string]string{"error": msg})
+		}
+	}
 
 	// Check if item already exists in cart
 	update := bson.M{
@@ -58,7 +75,7 @@ func AddToCart(c echo.Context) error {
 			"items": bson.M{
 				"$elemMatch": bson.M{
 					"productId": productID,
-					"size":      req.Size,
+					"sku":       req.SKU,
 				},
 			},
 		},
@@ -79,7 +96,7 @@ func AddToCart(c echo.Context) error {
 			"$push": bson.M{
 				"items": bson.M{
 					"productId": productID,
-					"size":      req.Size,
+					"sku":       req.SKU,
 					"quantity":  req.Quantity,
 				},
 			},
@@ -110,7 +127,7 @@ func AddToCart(c echo.Context) error {
 	return c.JSON(http.StatusOK, cart)
 }
 
-// GetCart retrieves the user's cart and cleans invalid products
+// GetCart retrieves the user's cart and cleans invalid products and variants
 func GetCart(c echo.Context) error {
 	userID := c.Get("userID").(primitive.ObjectID)
 
@@ -147,9 +164,12 @@ func GetCart(c echo.Context) error {
 			var product models.Product
 			err := database.DB.Collection("products").FindOne(
 				c.Request().Context(),
-				bson.M{"_id": item.ProductID},
+				availableProducts(bson.M{"_id": item.ProductID}),
 			).Decode(&product)
-			if err == nil {
+			if err != nil {
+				continue
+			}
+			if _, ok := product.Variant(item.SKU); ok {
 				validItems = append(validItems, item)
 			}
 		}
@@ -176,7 +196,8 @@ func GetCart(c echo.Context) error {
 	return c.JSON(http.StatusOK, cart)
 }
 
-// RemoveFromCart removes an item from the cart
+// RemoveFromCart removes a product from the cart, or only one of its variants when
+// the sku query parameter is given
 func RemoveFromCart(c echo.Context) error {
 	userID := c.Get("userID").(primitive.ObjectID)
 	productID, err := primitive.ObjectIDFromHex(c.Param("productId"))
@@ -184,13 +205,18 @@ func RemoveFromCart(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid product ID"})
 	}
 
+	item := bson.M{"productId": productID}
+	if sku := c.QueryParam("sku"); sku != "" {
+		item["sku"] = sku
+	}
+
 	collection := database.DB.Collection("carts")
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
 	update := bson.M{
 		"$pull": bson.M{
-			"items": bson.M{"productId": productID},
+			"items": item,
 		},
 		"$set": bson.M{"updatedAt": time.Now()},
 	}
@@ -213,6 +239,7 @@ func UpdateCartItemQuantity(c echo.Context) error {
 
 	var req struct {
 		ProductID string `json:"productId"`
+		SKU       string `json:"sku"`
 		Quantity  int    `json:"quantity"`
 	}
 
@@ -234,12 +261,15 @@ func UpdateCartItemQuantity(c echo.Context) error {
 	var product models.Product
 	err = database.DB.Collection("products").FindOne(
 		c.Request().Context(),
-		bson.M{"_id": productID},
+		availableProducts(bson.M{"_id": productID}),
 	).Decode(&product)
 
 	if err != nil {
 		return c.JSON(http.StatusNotFound, map[string]string{"error": "Product not found"})
 	}
+	if _, ok := product.Variant(req.SKU); !ok {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Variant not found"})
+	}
 
 	// Update the cart item quantity
 	update := bson.M{
@@ -251,7 +281,7 @@ func UpdateCartItemQuantity(c echo.Context) error {
 
 	arrayFilters := options.ArrayFilters{
 		Filters: []interface{}{
-			bson.M{"elem.productId": productID},
+			bson.M{"elem.productId": productID, "elem.sku": req.SKU},
 		},
 	}
 
@@ -287,3 +317,24 @@ func UpdateCartItemQuantity(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, cart)
 }
+
+// checkTokenGate verifies one of the user's wallets satisfies the product's gate
+func checkTokenGate(ctx context.Context, userID primitive.ObjectID, product models.Product) (int, string) {
+	wallets, err := verifiedWallets(ctx, userID)
+	if err != nil {
+		return http.StatusInternalServerError, "Failed to fetch user wallets"
+	}
+	if len(wallets) == 0 {
+		return http.StatusForbidden, "Link a verified wallet to buy " + product.Name
+	}
+
+	ok, err := utils.MeetsTokenRequirement(ctx, *product.Gate, wallets)
+	if err != nil {
+		return http.StatusBadGateway, "Failed to check token balance"
+	}
+	if !ok {
+		return http.StatusForbidden, product.Name + " is only available to token holders"
+	}
+
+	return http.StatusOK, ""
+}
@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/database"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/utils"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const maxCategoryNameLength = 100
+
+type CategoryRequest struct {
+	Name     string `json:"name"`
+	Slug     string `json:"slug"`     // Generated from the name when creating without one
+	ParentID string `json:"parentId"` // Empty for a top-level category
+}
+
+// GetCategories lists every category, sorted by name
+func GetCategories(c echo.Context) error {
+	categories, err := utils.ListCategories(c.Request().Context())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch categories"})
+	}
+	return c.JSON(http.StatusOK, categories)
+}
+
+// GetCategoryTree returns the categories nested under their parents with the number
+// of products available in each
+func GetCategoryTree(c echo.Context) error {
+	tree, err := utils.CategoryTree(c.Request().Context(), availableProducts(bson.M{}))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch categories"})
+	}
+	return c.JSON(http.StatusOK, tree)
+}
+
+// GetCategory returns one category, looked up by ID or slug
+func GetCategory(c echo.Context) error {
+	category, err := utils.FindCategory(c.Request().Context(), c.Param("id"))
+	if err == utils.ErrCategoryNotFound {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Category not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch category"})
+	}
+	return c.JSON(http.StatusOK, category)
+}
+
+// CreateCategory adds a category, under a parent when parentId is given
+func CreateCategory(c echo.Context) error {
+	var req CategoryRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	now := time.Now()
+	category := models.ProductCategory{ID: primitive.NewObjectID(), CreatedAt: now, UpdatedAt: now}
+	if status, message := applyCategoryRequest(c, &category, req); status != 0 {
+		return c.JSON(status, map[string]string{"error": message})
+	}
+
+	_, err := database.DB.Collection("categories").InsertOne(c.Request().Context(), category)
+	if mongo.IsDuplicateKeyError(err) {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Slug is already used by another category"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create category"})
+	}
+	audit(c, models.AuditEntry{
+		Action:     models.AuditCategoryCreated,
+		TargetType: models.AuditTargetCategory,
+		TargetID:   category.ID.Hex(),
+		Changes:    utils.AuditDiff(nil, category),
+	})
+
+	return c.JSON(http.StatusCreated, category)
+}
+
+// UpdateCategory renames or moves a category. The slug is kept unless a new one is given.
+func UpdateCategory(c echo.Context) error {
+	categoryID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid category ID"})
+	}
+
+	var req CategoryRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	ctx := c.Request().Context()
+	current, err := utils.FindCategory(ctx, categoryID.Hex())
+	if err == utils.ErrCategoryNotFound {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Category not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch category"})
+	}
+
+	updated := current
+	if strings.TrimSpace(req.Slug) == "" {
+		req.Slug = current.Slug
+	}
+	if status, message := applyCategoryRequest(c, &updated, req); status != 0 {
+		return c.JSON(status, map[string]string{"error": message})
+	}
+	updated.UpdatedAt = time.Now()
+
+	update := bson.M{"$set": bson.M{"name": updated.Name, "slug": updated.Slug, "updatedAt": updated.UpdatedAt}}
+	if updated.ParentID != nil {
+		update["$set"].(bson.M)["parentId"] = updated.ParentID
+	} else {
+		update["$unset"] = bson.M{"parentId": ""}
+	}
+
+	_, err = database.DB.Collection("categories").UpdateOne(ctx, bson.M{"_id": categoryID}, update)
+	if mongo.IsDuplicateKeyError(err) {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Slug is already used by another category"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update category"})
+	}
+
+	// The check in applyCategoryRequest can't see a concurrent move, so look again and
+	// put the parent back if the two moves made a cycle
+	if updated.ParentID != nil && !sameParent(updated.ParentID, current.ParentID) {
+		cycle, err := utils.CategoryInCycle(ctx, categoryID)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to check categories"})
+		}
+		if cycle {
+			restore := bson.M{"$set": bson.M{"parentId": current.ParentID}}
+			if current.ParentID == nil {
+				restore = bson.M{"$unset": bson.M{"parentId": ""}}
+			}
+			if _, err := database.DB.Collection("categories").UpdateOne(ctx, bson.M{"_id": categoryID}, restore); err != nil {
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update category"})
+			}
+			return c.JSON(http.StatusConflict, map[string]string{"error": "The new parent was just moved under this category"})
+		}
+	}
+
+	audit(c, models.AuditEntry{
+		Action:     models.AuditCategoryUpdated,
+		TargetType: models.AuditTargetCategory,
+		TargetID:   categoryID.Hex(),
+		Changes:    utils.AuditDiff(current, updated),
+	})
+
+	return c.JSON(http.StatusOK, updated)
+}
+
+// DeleteCategory removes a category that has no subcategories and no products
+func DeleteCategory(c echo.Context) error {
+	categoryID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid category ID"})
+	}
+
+	ctx := c.Request().Context()
+	category, err := utils.FindCategory(ctx, categoryID.Hex())
+	if err == utils.ErrCategoryNotFound {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Category not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch category"})
+	}
+
+	children, err := database.DB.Collection("categories").CountDocuments(ctx, bson.M{"parentId": categoryID})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to check subcategories"})
+	}
+	if children > 0 {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Move or delete the subcategories first"})
+	}
+
+	// Archived products count, since they can be restored into the category
+	products, err := database.DB.Collection("products").CountDocuments(ctx, bson.M{
+		"categories": categoryID,
+		"deletedAt":  bson.M{"$exists": false},
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to check products"})
+	}
+	if products > 0 {
+		return c.JSON(http.StatusConflict, map[string]interface{}{
+			"error":        "Remove the category from its products first",
+			"productCount": products,
+		})
+	}
+
+	if _, err := database.DB.Collection("categories").DeleteOne(ctx, bson.M{"_id": categoryID}); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to delete category"})
+	}
+	audit(c, models.AuditEntry{
+		Action:     models.AuditCategoryDeleted,
+		TargetType: models.AuditTargetCategory,
+		TargetID:   categoryID.Hex(),
+		Metadata:   map[string]string{"name": category.Name, "slug": category.Slug},
+	})
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func sameParent(a, b *primitive.ObjectID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// applyCategoryRequest validates the request and copies it onto the category. A new
+// parent must exist and may not be the category itself or one of its subcategories.
+func applyCategoryRequest(c echo.Context, category *models.ProductCategory, req CategoryRequest) (int, string) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return http.StatusBadRequest, "Name is required"
+	}
+	if len(name) > maxCategoryNameLength {
+		return http.StatusBadRequest, "Name must be at most 100 characters"
+	}
+
+	slug := strings.TrimSpace(req.Slug)
+	if slug == "" {
+		slug = utils.Slugify(name)
+		if slug == "" {
+			return http.StatusBadRequest, "Name has no letters or digits to make a slug from, give a slug"
+		}
+	}
+	if !utils.IsValidSlug(slug) {
+		return http.StatusBadRequest, "Slug must be lowercase letters and digits separated by dashes"
+	}
+
+	// Slugs that parse as IDs would be shadowed when looking categories up
+	if _, err := primitive.ObjectIDFromHex(slug); err == nil {
+		return http.StatusBadRequest, "Slug can't look like a category ID"
+	}
+
+	var parentID *primitive.ObjectID
+	if req.ParentID != "" {
+		id, err := primitive.ObjectIDFromHex(req.ParentID)
+		if err != nil {
+			return http.StatusBadRequest, "Invalid parent ID"
+		}
+		if id == category.ID {
+			return http.StatusBadRequest, "A category can't be its own parent"
+		}
+
+		ctx := c.Request().Context()
+		if _, err := utils.FindCategory(ctx, id.Hex()); err != nil {
+			if err == utils.ErrCategoryNotFound {
+				return http.StatusBadRequest, "Parent category not found"
+			}
+			return http.StatusInternalServerError, "Failed to fetch parent category"
+		}
+
+		below, err := utils.CategoryDescendants(ctx, category.ID)
+		if err != nil {
+			return http.StatusInternalServerError, "Failed to fetch categories"
+		}
+		for _, descendant := range below {
+			if descendant == id {
+				return http.StatusBadRequest, "A category can't be moved under its own subcategory"
+			}
+		}
+		parentID = &id
+	}
+
+	category.Name = name
+	category.Slug = slug
+	category.ParentID = parentID
+	return 0, ""
+}
+
+// checkProductCategories makes sure every category a product is put in exists
+func checkProductCategories(c echo.Context, ids []primitive.ObjectID) (int, string) {
+	if len(ids) == 0 {
+		return 0, ""
+	}
+
+	unique := map[primitive.ObjectID]bool{}
+	for _, id := range ids {
+		unique[id] = true
+	}
+
+	count, err := database.DB.Collection("categories").CountDocuments(
+		c.Request().Context(),
+		bson.M{"_id": bson.M{"$in": ids}},
+	)
+	if err != nil {
+		return http.StatusInternalServerError, "Failed to check categories"
+	}
+	if count != int64(len(unique)) {
+		return http.StatusBadRequest, "Unknown category"
+	}
+	return 0, ""
+}
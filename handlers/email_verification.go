@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/database"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/utils"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Minimum time between verification emails for the same user
+const verificationResendInterval = time.Minute
+
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+// VerifyEmail consumes an emailed verification token and marks the email verified
+func VerifyEmail(c echo.Context) error {
+	var req VerifyEmailRequest
+	if err := c.Bind(&req); err != nil || req.Token == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Token is required"})
+	}
+
+	ctx := c.Request().Context()
+
+	token, err := utils.ConsumeUserToken(ctx, req.Token, models.UserTokenEmailVerification)
+	if err != nil {
+		if err == utils.ErrInvalidUserToken {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid or expired verification token"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to verify email"})
+	}
+
+	// The token only vouches for the address it was sent to
+	result, err := database.DB.Collection("users").UpdateOne(
+		ctx,
+		bson.M{"_id": token.UserID, "email": token.Email},
+		bson.M{"$set": bson.M{"emailVerified": true, "updatedAt": time.Now()}},
+	)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to verify email"})
+	}
+	if result.MatchedCount == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Email address has changed since the token was sent"})
+	}
+	auditUser(c, token.UserID, models.AuditEmailVerified, map[string]string{"email": token.Email})
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Email verified successfully"})
+}
+
+// ResendVerificationEmail mails the current user a new verification link
+func ResendVerificationEmail(c echo.Context) error {
+	userID := c.Get("userID").(primitive.ObjectID)
+	ctx := c.Request().Context()
+
+	var user models.User
+	if err := database.DB.Collection("users").FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
+	}
+
+	if user.EmailVerified {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Email is already verified"})
+	}
+
+	lastSent, err := utils.LastUserTokenIssuedAt(ctx, userID, models.UserTokenEmailVerification)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to send verification email"})
+	}
+	if wait := verificationResendInterval - time.Since(lastSent); wait > 0 {
+		c.Response().Header().Set("Retry-After", retryAfterSeconds(wait))
+		return c.JSON(http.StatusTooManyRequests, map[string]string{"error": "Please wait before requesting another verification email"})
+	}
+
+	if err := utils.SendVerificationEmail(ctx, user); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to send verification email"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Verification email sent"})
+}
+
+// retryAfterSeconds formats a wait as a Retry-After header value, rounded up
+func retryAfterSeconds(wait time.Duration) string {
+	return strconv.Itoa(int((wait + time.Second - 1) / time.Second))
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Uncached lookups run at once when a list of orders or wallets is named
+const maxConcurrentENSLookups = 8
+
+// primaryENSName returns the verified primary ENS name of address, or "" if it has none
+func primaryENSName(ctx context.Context, address string) string {
+	if !common.IsHexAddress(address) {
+		return ""
+	}
+
+	name, err := utils.ENS().LookupAddress(ctx, common.HexToAddress(address))
+	if err != nil {
+		return ""
+	}
+	return name
+}
+
+// primaryENSNames looks up each distinct address once, in parallel
+func primaryENSNames(ctx context.Context, addresses []string) map[string]string {
+	names := make(map[string]string, len(addresses))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, maxConcurrentENSLookups)
+
+	for _, address := range addresses {
+		mu.Lock()
+		_, seen := names[address]
+		names[address] = ""
+		mu.Unlock()
+		if seen {
+			continue
+		}
+
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(address string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			name := primaryENSName(ctx, address)
+			mu.Lock()
+			names[address] = name
+			mu.Unlock()
+		}(address)
+	}
+
+	wg.Wait()
+	return names
+}
+
+// attachOrderENSName fills in the ENS name of the order's paying wallet
+func attachOrderENSName(ctx context.Context, order *models.Order) {
+	order.ENSName = primaryENSName(ctx, order.WalletAddress)
+}
+
+// attachOrderENSNames fills in the ENS names of the orders' paying wallets
+func attachOrderENSNames(ctx context.Context, orders []models.Order) {
+	addresses := make([]string, len(orders))
+	for i := range orders {
+		addresses[i] = orders[i].WalletAddress
+	}
+
+	names := primaryENSNames(ctx, addresses)
+	for i := range orders {
+		orders[i].ENSName = names[orders[i].WalletAddress]
+	}
+}
+
+// attachWalletENSNames fills in the ENS names of linked wallets
+func attachWalletENSNames(ctx context.Context, wallets []models.Wallet) {
+	addresses := make([]string, len(wallets))
+	for i := range wallets {
+		addresses[i] = wallets[i].Address
+	}
+
+	names := primaryENSNames(ctx, addresses)
+	for i := range wallets {
+		wallets[i].ENSName = names[wallets[i].Address]
+	}
+}
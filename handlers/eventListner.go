@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/Madhav-Gupta-28/0xmart-backend-go/database"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
 	"github.com/Madhav-Gupta-28/0xmart-backend-go/utils"
 	"github.com/labstack/echo/v4"
 	"github.com/prometheus/client_golang/prometheus"
@@ -104,6 +105,7 @@ func StartListener(c echo.Context) error {
 	go retryFailedEvents()
 
 	successfulTransactions.Inc()
+	audit(c, models.AuditEntry{Action: models.AuditListenerStarted, TargetType: models.AuditTargetListener})
 	return c.JSON(http.StatusOK, map[string]string{"status": "Listener started successfully"})
 }
 
@@ -191,6 +193,7 @@ func RestartListener(c echo.Context) error {
 			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		}
 		successfulTransactions.Inc()
+		audit(c, models.AuditEntry{Action: models.AuditListenerStarted, TargetType: models.AuditTargetListener})
 		return c.JSON(http.StatusOK, map[string]string{"status": "Listener started successfully"})
 	}
 
@@ -201,5 +204,6 @@ func RestartListener(c echo.Context) error {
 	}
 
 	successfulTransactions.Inc()
+	audit(c, models.AuditEntry{Action: models.AuditListenerRestarted, TargetType: models.AuditTargetListener})
 	return c.JSON(http.StatusOK, map[string]string{"status": "Listener restarted successfully"})
 }
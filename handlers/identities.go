@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/database"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// findUserByIdentity finds the user a provider account is linked to, including
+// identities only recorded in the legacy Provider/ProviderId fields
+func findUserByIdentity(ctx context.Context, provider, providerID string) (models.User, error) {
+	var user models.User
+	err := database.DB.Collection("users").FindOne(ctx, bson.M{
+		"$or": []bson.M{
+			{"identities": bson.M{"$elemMatch": bson.M{"provider": provider, "providerId": providerID}}},
+			{"provider": provider, "providerId": providerID},
+		},
+	}).Decode(&user)
+	return user, err
+}
+
+// GetIdentities lists the provider identities linked to the current user
+func GetIdentities(c echo.Context) error {
+	userID := c.Get("userID").(primitive.ObjectID)
+
+	var user models.User
+	if err := database.DB.Collection("users").FindOne(c.Request().Context(), bson.M{"_id": userID}).Decode(&user); err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"identities":  user.LinkedIdentities(),
+		"hasPassword": user.Password != "",
+	})
+}
+
+// LinkIdentity links a provider account to the current user. The body is the same as
+// for NextAuthSignIn and must prove the identity with an ID token or a signature.
+func LinkIdentity(c echo.Context) error {
+	userID := c.Get("userID").(primitive.ObjectID)
+
+	body, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	var req NextAuthSignInRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	if req.Provider == "" || req.Provider == "credentials" || req.Email == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "An OAuth provider and email are required"})
+	}
+
+	ctx := c.Request().Context()
+
+	if status, message := verifyProviderIdentity(ctx, c, body, &req); status != 0 {
+		return c.JSON(status, map[string]string{"error": message})
+	}
+	if req.ProviderId == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Provider ID is required"})
+	}
+
+	owner, err := findUserByIdentity(ctx, req.Provider, req.ProviderId)
+	if err == nil {
+		if owner.ID == userID {
+			return c.JSON(http.StatusOK, map[string]interface{}{"identities": owner.LinkedIdentities()})
+		}
+		return c.JSON(http.StatusConflict, map[string]string{"error": "This " + req.Provider + " account is linked to another user"})
+	}
+	if err != mongo.ErrNoDocuments {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch user"})
+	}
+
+	// Only one account per provider, so an account can't collect logins it doesn't own
+	var user models.User
+	if err := database.DB.Collection("users").FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
+	}
+	for _, identity := range user.LinkedIdentities() {
+		if identity.Provider == req.Provider {
+			return c.JSON(http.StatusConflict, map[string]string{"error": "Another " + req.Provider + " account is already linked, unlink it first"})
+		}
+	}
+
+	identities := append(user.LinkedIdentities(), models.Identity{
+		Provider:   req.Provider,
+		ProviderID: req.ProviderId,
+		Email:      req.Email,
+		LinkedAt:   time.Now(),
+	})
+
+	// Guard against a concurrent link of the same provider
+	result, err := database.DB.Collection("users").UpdateOne(
+		ctx,
+		bson.M{"_id": userID, "identities.provider": bson.M{"$ne": req.Provider}},
+		bson.M{"$set": bson.M{"identities": identities, "updatedAt": time.Now()}},
+	)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to link identity"})
+	}
+	if result.MatchedCount == 0 {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Another " + req.Provider + " account is already linked, unlink it first"})
+	}
+	auditUser(c, userID, models.AuditIdentityLinked, map[string]string{"provider": req.Provider, "providerId": req.ProviderId})
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"identities": identities})
+}
+
+// UnlinkIdentity removes a provider identity from the current user, as long as
+// another way to sign in remains
+func UnlinkIdentity(c echo.Context) error {
+	userID := c.Get("userID").(primitive.ObjectID)
+	provider := c.Param("provider")
+	providerID := c.Param("providerId")
+	ctx := c.Request().Context()
+
+	var user models.User
+	if err := database.DB.Collection("users").FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
+	}
+
+	remaining := []models.Identity{}
+	found := false
+	for _, identity := range user.LinkedIdentities() {
+		if identity.Provider == provider && identity.ProviderID == providerID {
+			found = true
+			continue
+		}
+		remaining = append(remaining, identity)
+	}
+
+	if !found {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Identity not found"})
+	}
+	if len(remaining) == 0 && user.Password == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Set a password or link another provider before unlinking your only sign-in method"})
+	}
+
+	update := bson.M{"$set": bson.M{"identities": remaining, "updatedAt": time.Now()}}
+	if user.Provider == provider && user.ProviderId == providerID {
+		update["$unset"] = bson.M{"providerId": ""}
+	}
+
+	if _, err := database.DB.Collection("users").UpdateOne(ctx, bson.M{"_id": userID}, update); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to unlink identity"})
+	}
+	auditUser(c, userID, models.AuditIdentityUnlinked, map[string]string{"provider": provider, "providerId": providerID})
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"identities": remaining})
+}
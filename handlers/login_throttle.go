@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/utils"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// loginThrottled writes a 429 response and returns true when the client has to
+// wait before its next login attempt
+func loginThrottled(ctx context.Context, c echo.Context, email string) (bool, error) {
+	wait, err := utils.CheckLogin(ctx, c.RealIP(), email)
+	if err != nil {
+		return true, c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to check login attempts"})
+	}
+	if wait > 0 {
+		c.Response().Header().Set("Retry-After", retryAfterSeconds(wait))
+		return true, c.JSON(http.StatusTooManyRequests, map[string]string{"error": "Too many failed login attempts, try again later"})
+	}
+	return false, nil
+}
+
+// loginFailed records the failure and returns the invalid credentials response
+func loginFailed(ctx context.Context, c echo.Context, email, message string) error {
+	if err := utils.RecordLoginFailure(ctx, c.RealIP(), email); err != nil {
+		log.Printf("❌ Failed to record login failure: %v", err)
+	}
+	audit(c, models.AuditEntry{
+		Action:     models.AuditLoginFailed,
+		TargetType: models.AuditTargetAccount,
+		TargetID:   strings.ToLower(strings.TrimSpace(email)),
+		Metadata:   map[string]string{"reason": message},
+	})
+	return c.JSON(http.StatusUnauthorized, map[string]string{"error": message})
+}
+
+func loginSucceeded(ctx context.Context, email string) {
+	if err := utils.RecordLoginSuccess(ctx, email); err != nil {
+		log.Printf("❌ Failed to reset login attempts: %v", err)
+	}
+}
+
+// auditLogin records a login that was granted a session
+func auditLogin(c echo.Context, userID primitive.ObjectID, method string) {
+	auditUser(c, userID, models.AuditLogin, map[string]string{"method": method})
+}
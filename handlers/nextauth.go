@@ -2,8 +2,12 @@ package handlers
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/Madhav-Gupta-28/0xmart-backend-go/database"
@@ -12,6 +16,7 @@ import (
 	"github.com/labstack/echo/v4"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -22,6 +27,7 @@ type NextAuthSignInRequest struct {
 	Name       string `json:"name,omitempty"`
 	Image      string `json:"image,omitempty"`
 	ProviderId string `json:"providerId,omitempty"`
+	IDToken    string `json:"idToken,omitempty"` // OpenID Connect ID token from the provider
 }
 
 // SignUpRequest represents the expected request body for signup
@@ -70,6 +76,7 @@ func SignUp(c echo.Context) error {
 		Password:      string(hashedPassword),
 		Provider:      "credentials",
 		EmailVerified: false,
+		Roles:         []string{models.RoleCustomer},
 		CreatedAt:     time.Now(),
 		UpdatedAt:     time.Now(),
 	}
@@ -79,9 +86,16 @@ func SignUp(c echo.Context) error {
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create user"})
 	}
+	auditUser(c, newUser.ID, models.AuditSignUp, map[string]string{"provider": "credentials"})
 
-	// Generate JWT token
-	token, err := utils.GenerateJWT(newUser.ID.Hex())
+	// A failed send is not fatal, the user can ask for another link
+	verifyUser := newUser
+	utils.SendInBackground("verification email to "+newUser.Email, func(ctx context.Context) error {
+		return utils.SendVerificationEmail(ctx, verifyUser)
+	})
+
+	// Issue access and refresh tokens
+	tokens, err := utils.IssueTokenPair(c.Request().Context(), newUser.ID, sessionInfo(c, false))
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate token"})
 	}
@@ -90,50 +104,152 @@ func SignUp(c echo.Context) error {
 	newUser.Password = ""
 
 	return c.JSON(http.StatusCreated, map[string]interface{}{
-		"user":  newUser,
-		"token": token,
+		"user":         newUser,
+		"token":        tokens.AccessToken,
+		"refreshToken": tokens.RefreshToken,
+		"expiresIn":    tokens.ExpiresIn,
 	})
 }
 
-// NextAuthSignIn handles sign-in requests from NextAuth
+// NextAuthSignIn handles sign-in requests from NextAuth. Credentials sign-ins must
+// carry the password; OAuth sign-ins must carry the provider's ID token or be
+// signed by our NextAuth server with NEXTAUTH_SIGNING_SECRET.
 func NextAuthSignIn(c echo.Context) error {
+	// The raw body is kept because the NextAuth signature covers it
+	body, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
 	var req NextAuthSignInRequest
-	if err := c.Bind(&req); err != nil {
+	if err := json.Unmarshal(body, &req); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
 	}
 
+	if req.Provider == "" || req.Email == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Provider and email are required"})
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	// Check if user exists
-	var user models.User
-	err := database.DB.Collection("users").FindOne(ctx, bson.M{"email": req.Email}).Decode(&user)
+	if req.Provider == "credentials" {
+		if throttled, err := loginThrottled(ctx, c, req.Email); throttled {
+			return err
+		}
 
-	if err != nil {
-		// Create new user if not exists
-		user = models.User{
-			ID:            primitive.NewObjectID(),
-			Email:         req.Email,
-			Name:          req.Name,
-			Image:         req.Image,
-			Provider:      req.Provider,
-			ProviderId:    req.ProviderId,
-			EmailVerified: true,
-			CreatedAt:     time.Now(),
-			UpdatedAt:     time.Now(),
+		var user models.User
+		err := database.DB.Collection("users").FindOne(ctx, bson.M{"email": req.Email}).Decode(&user)
+		if err != nil || user.Password == "" {
+			return loginFailed(ctx, c, req.Email, "Invalid credentials")
+		}
+		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+			return loginFailed(ctx, c, req.Email, "Invalid credentials")
 		}
+		loginSucceeded(ctx, req.Email)
+		return nextAuthSignInResponse(ctx, c, user, req.Provider)
+	}
 
-		_, err = database.DB.Collection("users").InsertOne(ctx, user)
+	if status, message := verifyProviderIdentity(ctx, c, body, &req); status != 0 {
+		return c.JSON(status, map[string]string{"error": message})
+	}
+
+	if req.ProviderId == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Provider ID is required"})
+	}
+
+	// Sign in the user this provider account is linked to
+	user, err := findUserByIdentity(ctx, req.Provider, req.ProviderId)
+	if err == nil {
+		return nextAuthSignInResponse(ctx, c, user, req.Provider)
+	}
+	if err != mongo.ErrNoDocuments {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch user"})
+	}
+
+	// An email owned by another sign-in method is never merged automatically; the owner
+	// has to sign in the usual way and link this provider from their account
+	count, err := database.DB.Collection("users").CountDocuments(ctx, bson.M{"email": req.Email})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch user"})
+	}
+	if count > 0 {
+		return c.JSON(http.StatusConflict, map[string]string{
+			"error": "An account with this email already exists. Sign in with your existing method and link " + req.Provider + " from your account settings",
+			"code":  "identity_conflict",
+		})
+	}
+
+	now := time.Now()
+	user = models.User{
+		ID:            primitive.NewObjectID(),
+		Email:         req.Email,
+		Name:          req.Name,
+		Image:         req.Image,
+		Provider:      req.Provider,
+		ProviderId:    req.ProviderId,
+		Identities:    []models.Identity{{Provider: req.Provider, ProviderID: req.ProviderId, Email: req.Email, LinkedAt: now}},
+		EmailVerified: true,
+		Roles:         []string{models.RoleCustomer},
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+
+	_, err = database.DB.Collection("users").InsertOne(ctx, user)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create user"})
+	}
+	auditUser(c, user.ID, models.AuditSignUp, map[string]string{"provider": req.Provider})
+
+	return nextAuthSignInResponse(ctx, c, user, req.Provider)
+}
+
+// verifyProviderIdentity checks an OAuth identity posted to NextAuthSignIn or LinkIdentity and returns
+// a non-zero status when it cannot be trusted. A verified ID token also fills in ProviderId.
+func verifyProviderIdentity(ctx context.Context, c echo.Context, body []byte, req *NextAuthSignInRequest) (int, string) {
+	if req.IDToken != "" {
+		identity, err := utils.VerifyProviderIDToken(ctx, req.Provider, req.IDToken)
+		if errors.Is(err, utils.ErrProviderNotConfigured) {
+			return http.StatusBadRequest, "Unsupported provider"
+		}
 		if err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create user"})
+			return http.StatusUnauthorized, "Invalid ID token"
+		}
+		if !strings.EqualFold(identity.Email, req.Email) {
+			return http.StatusUnauthorized, "Email does not match the provider identity"
 		}
+		if !identity.EmailVerified {
+			return http.StatusUnauthorized, "Provider email is not verified"
+		}
+		if req.ProviderId != "" && req.ProviderId != identity.Subject {
+			return http.StatusUnauthorized, "Provider ID does not match the provider identity"
+		}
+		req.ProviderId = identity.Subject
+		return 0, ""
 	}
 
-	// Generate JWT token
-	token, err := utils.GenerateJWT(user.ID.Hex())
+	if signature := c.Request().Header.Get("X-NextAuth-Signature"); signature != "" && utils.NextAuthSigningEnabled() {
+		timestamp := c.Request().Header.Get("X-NextAuth-Timestamp")
+		if err := utils.VerifyNextAuthSignature(body, timestamp, signature); err != nil {
+			return http.StatusUnauthorized, "Invalid signature"
+		}
+		return 0, ""
+	}
+
+	return http.StatusUnauthorized, "An ID token or signed request is required"
+}
+
+func nextAuthSignInResponse(ctx context.Context, c echo.Context, user models.User, provider string) error {
+	if challenged, err := twoFactorChallenge(c, user); challenged {
+		return err
+	}
+
+	// Issue access and refresh tokens
+	tokens, err := utils.IssueTokenPair(ctx, user.ID, sessionInfo(c, false))
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate token"})
 	}
+	auditLogin(c, user.ID, provider)
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"user": map[string]interface{}{
@@ -143,7 +259,9 @@ func NextAuthSignIn(c echo.Context) error {
 			"image":         user.Image,
 			"emailVerified": user.EmailVerified,
 		},
-		"token": token,
+		"token":        tokens.AccessToken,
+		"refreshToken": tokens.RefreshToken,
+		"expiresIn":    tokens.ExpiresIn,
 	})
 }
 
@@ -174,9 +292,32 @@ func NextAuthSession(c echo.Context) error {
 	})
 }
 
-// NextAuthCSRF generates and returns a CSRF token
+// NextAuthCSRF returns a CSRF token and sets the signed cookie it is checked against.
+// Browsers using the access token cookie send it back in the X-CSRF-Token header.
+// Tokens are bound to the cookie's session, so clients fetch a new one after signing in.
 func NextAuthCSRF(c echo.Context) error {
-	token := utils.GenerateCSRFToken()
+	cookie, err := c.Cookie(utils.AuthCookieName())
+	if err != nil || cookie.Value == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Sign in before requesting a CSRF token"})
+	}
+	claims, err := utils.ValidateJWT(cookie.Value)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid or expired token"})
+	}
+
+	token, cookieValue, expiresAt := utils.IssueCSRFToken(claims.SessionID)
+
+	c.SetCookie(&http.Cookie{
+		Name:     utils.CSRFCookieName,
+		Value:    cookieValue,
+		Path:     "/",
+		Expires:  expiresAt,
+		HttpOnly: true,
+		Secure:   utils.CookieSecure(),
+		SameSite: http.SameSiteLaxMode,
+	})
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+
 	return c.JSON(http.StatusOK, map[string]string{
 		"csrfToken": token,
 	})
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
+)
+
+// useStandInJWKS writes a local key set and points the "test" provider at it
+func useStandInJWKS(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	set, _ := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kid": "stand-in",
+			"kty": "RSA",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	})
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, set, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("OAUTH_TEST_CLIENT_ID", "test-client")
+	t.Setenv("OAUTH_TEST_JWKS_URL", "file://"+path)
+	t.Setenv("OAUTH_TEST_ISSUER", "https://issuer.example.com")
+	return key
+}
+
+func TestVerifyProviderIdentity(t *testing.T) {
+	key := useStandInJWKS(t)
+
+	sign := func(email string, verified bool) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss":            "https://issuer.example.com",
+			"aud":            "test-client",
+			"sub":            "provider-user-1",
+			"email":          email,
+			"email_verified": verified,
+			"exp":            time.Now().Add(time.Hour).Unix(),
+		})
+		token.Header["kid"] = "stand-in"
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+
+	tests := []struct {
+		name       string
+		req        NextAuthSignInRequest
+		wantStatus int
+	}{
+		{
+			name:       "verified identity",
+			req:        NextAuthSignInRequest{Provider: "test", Email: "user@example.com", IDToken: sign("user@example.com", true)},
+			wantStatus: 0,
+		},
+		{
+			name:       "unverified email",
+			req:        NextAuthSignInRequest{Provider: "test", Email: "user@example.com", IDToken: sign("user@example.com", false)},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "email differs from token",
+			req:        NextAuthSignInRequest{Provider: "test", Email: "victim@example.com", IDToken: sign("user@example.com", true)},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "provider ID differs from subject",
+			req:        NextAuthSignInRequest{Provider: "test", Email: "user@example.com", ProviderId: "other", IDToken: sign("user@example.com", true)},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "unconfigured provider",
+			req:        NextAuthSignInRequest{Provider: "unknown", Email: "user@example.com", IDToken: sign("user@example.com", true)},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "no proof of identity",
+			req:        NextAuthSignInRequest{Provider: "test", Email: "user@example.com", ProviderId: "provider-user-1"},
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c := echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/api/auth/signin", nil), httptest.NewRecorder())
+
+			req := tc.req
+			status, message := verifyProviderIdentity(context.Background(), c, nil, &req)
+			if status != tc.wantStatus {
+				t.Fatalf("status = %d (%s), want %d", status, message, tc.wantStatus)
+			}
+			if status == 0 && req.ProviderId != "provider-user-1" {
+				t.Errorf("ProviderId = %q, want the token subject", req.ProviderId)
+			}
+		})
+	}
+}
@@ -5,17 +5,25 @@ import (
 	"fmt"
 	"math/big"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"log"
 
 	"github.com/Madhav-Gupta-28/0xmart-backend-go/database"
 	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
-	"github.com/ethereum/go-ethereum/common"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/utils"
 	"github.com/labstack/echo/v4"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	defaultFulfillmentPageSize = 50
+	maxFulfillmentPageSize     = 200
 )
 
 type CreateOrderRequest struct {
@@ -29,14 +37,28 @@ func CreateOrder(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request format"})
 	}
 
-	// Make wallet address validation optional
-	if req.WalletAddress != "" && !common.IsHexAddress(req.WalletAddress) {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid wallet address format"})
+	// Make wallet address validation optional; ENS names are resolved to their address
+	if req.WalletAddress != "" {
+		address, err := utils.ResolveWalletInput(c.Request().Context(), req.WalletAddress)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid wallet address or unresolvable ENS name"})
+		}
+		req.WalletAddress = address
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	if utils.RequireVerifiedEmail() {
+		var user models.User
+		if err := database.DB.Collection("users").FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch user"})
+		}
+		if !user.EmailVerified {
+			return c.JSON(http.StatusForbidden, map[string]string{"error": "Verify your email address before placing an order"})
+		}
+	}
+
 	// Get user's cart
 	var cart models.Cart
 	err := database.DB.Collection("carts").FindOne(ctx, bson.M{"userId": userID}).Decode(&cart)
@@ -51,6 +73,12 @@ func CreateOrder(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Cart is empty"})
 	}
 
+	// Token gates and holder discounts are checked against the user's verified wallets
+	wallets, err := verifiedWallets(ctx, userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch user wallets"})
+	}
+
 	// Calculate total price and validate items
 	totalPrice := big.NewInt(0)
 	var orderItems []models.OrderItem
@@ -58,37 +86,58 @@ func CreateOrder(c echo.Context) error {
 
 	for _, item := range cart.Items {
 		var product models.Product
-		err := productsCollection.FindOne(ctx, bson.M{"_id": item.ProductID}).Decode(&product)
+		err := productsCollection.FindOne(ctx, availableProducts(bson.M{"_id": item.ProductID})).Decode(&product)
+		if err == mongo.ErrNoDocuments {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": fmt.Sprintf("Product %s is no longer available, remove it from your cart", item.ProductID.Hex()),
+			})
+		}
 		if err != nil {
 			return c.JSON(http.StatusInternalServerError, map[string]string{
 				"error": fmt.Sprintf("Failed to fetch product %s", item.ProductID.Hex()),
 			})
 		}
 
-		// Convert string size to ProductSize
-		productSize := models.ProductSize(item.Size)
+		if product.Gate != nil {
+			if status, msg := checkTokenGate(ctx, userID, product); status != http.StatusOK {
+				return c.JSON(status, map[string]string{"error": msg})
+			}
+		}
+
+		variant, ok := product.Variant(item.SKU)
+		if !ok {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": fmt.Sprintf("Variant %s of product %s is no longer available, remove it from your cart", item.SKU, product.Name),
+			})
+		}
 
 		// Validate stock
-		if stock, ok := product.Stock[productSize]; !ok || stock < item.Quantity {
+		if variant.Stock < item.Quantity {
 			return c.JSON(http.StatusBadRequest, map[string]string{
-				"error": fmt.Sprintf("Insufficient stock for product %s size %s", product.Name, item.Size),
+				"error": fmt.Sprintf("Insufficient stock for product %s variant %s", product.Name, variant.SKU),
 			})
 		}
 
 		// Parse the price (already in Wei)
-		price := new(big.Int)
-		price, ok := price.SetString(product.Price, 10)
+		price, ok := new(big.Int).SetString(product.VariantPrice(variant), 10)
 		if !ok {
-			// If parsing fails, try to convert it to Wei
-			priceFloat, ok := new(big.Float).SetString(product.Price)
-			if !ok {
+			// Products saved before prices were converted hold an ETH amount
+			var err error
+			price, err = utils.PriceToWei(product.VariantPrice(variant))
+			if err != nil {
 				return c.JSON(http.StatusInternalServerError, map[string]string{
 					"error": fmt.Sprintf("Invalid price format for product %s", product.Name),
 				})
 			}
-			multiplier := new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil))
-			priceInWei := new(big.Float).Mul(priceFloat, multiplier)
-			price, _ = priceInWei.Int(nil)
+		}
+
+		if len(product.Discounts) > 0 && len(wallets) > 0 {
+			discountBps, err := utils.BestHolderDiscount(ctx, product.Discounts, wallets)
+			if err != nil {
+				return c.JSON(http.StatusBadGateway, map[string]string{"error": "Failed to check token balance"})
+			}
+			price.Mul(price, big.NewInt(10000-discountBps))
+			price.Div(price, big.NewInt(10000))
 		}
 
 		itemTotal := new(big.Int).Mul(price, big.NewInt(int64(item.Quantity)))
@@ -96,24 +145,68 @@ func CreateOrder(c echo.Context) error {
 
 		orderItems = append(orderItems, models.OrderItem{
 			ProductID: item.ProductID,
-			Size:      productSize,
+			SKU:       variant.SKU,
+			Options:   variant.Options,
 			Quantity:  item.Quantity,
 			Price:     price.String(),
 		})
 	}
 
+	// Allocate the numeric order ID referenced by the payment contract
+	orderNumber, err := database.NextSequence(ctx, "orderNumber")
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to allocate order number"})
+	}
+
 	// Create order
 	order := models.Order{
 		ID:            primitive.NewObjectID(),
+		OrderNumber:   orderNumber,
 		UserID:        userID,
 		Items:         orderItems,
 		TotalPrice:    totalPrice.String(),
 		Status:        models.OrderStatusPending,
+		AmountPaid:    "0",
+		BalanceDue:    totalPrice.String(),
 		WalletAddress: req.WalletAddress,
 		CreatedAt:     time.Now(),
 		UpdatedAt:     time.Now(),
 	}
 
+	// Derive a fresh deposit address so the order can be paid with a plain transfer
+	if utils.DepositAddressMode() {
+		index, err := database.NextSequence(ctx, "depositIndex")
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to allocate deposit address"})
+		}
+
+		// Higher indexes would need hardened derivation, which an xpub cannot do
+		if index > utils.MaxChildIndex {
+			log.Printf("Deposit index %d is beyond the non-hardened range of DEPOSIT_XPUB", index)
+			return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": "Failed to allocate deposit address"})
+		}
+
+		depositAddress, err := utils.DeriveDepositAddress(uint32(index))
+		if err != nil {
+			log.Printf("Failed to derive deposit address: %v", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to allocate deposit address"})
+		}
+
+		order.DepositIndex = uint32(index)
+		order.DepositAddress = depositAddress
+	}
+
+	// Sign a checkout quote so the payment contract can verify the amount on-chain
+	if !utils.DepositAddressMode() && utils.QuotesEnabled() {
+		quote, err := utils.NewCheckoutQuote(order.OrderNumber, order.TotalPrice, order.WalletAddress)
+		if err != nil {
+			log.Printf("Failed to sign checkout quote: %v", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create checkout quote"})
+		}
+		order.Quote = quote
+		order.IssuedQuotes = []models.PaymentQuote{*quote}
+	}
+
 	// Insert order
 	_, err = database.DB.Collection("orders").InsertOne(ctx, order)
 	if err != nil {
@@ -130,11 +223,14 @@ func CreateOrder(c echo.Context) error {
 		log.Printf("Failed to clear cart after order creation: %v", err)
 	}
 
+	utils.PublishOrderEvent(utils.OrderEventCreated, order)
+
+	attachOrderENSName(ctx, &order)
 	return c.JSON(http.StatusCreated, order)
 }
 
 func ProcessPayment(c echo.Context) error {
-	_, ok := c.Get("userID").(primitive.ObjectID)
+	userID, ok := c.Get("userID").(primitive.ObjectID)
 	if !ok {
 		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "User not authenticated"})
 	}
@@ -148,11 +244,54 @@ func ProcessPayment(c echo.Context) error {
 	defer cancel()
 
 	var order models.Order
-	err = database.DB.Collection("orders").FindOne(ctx, bson.M{"_id": orderID}).Decode(&order)
+	err = database.DB.Collection("orders").FindOne(ctx, bson.M{"_id": orderID, "userId": userID}).Decode(&order)
 	if err != nil {
 		return c.JSON(http.StatusNotFound, map[string]string{"error": "Order not found"})
 	}
 
+	if order.Status == models.OrderStatusPaid {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Order is already paid"})
+	}
+
+	// Issue a fresh quote for whatever is still owed; earlier quotes stay valid until they expire
+	var quote *models.PaymentQuote
+	if order.Quote != nil && utils.QuotesEnabled() {
+		amount := order.TotalPrice
+		if order.Status == models.OrderStatusPartiallyPaid {
+			amount = order.BalanceDue
+		}
+
+		quote, err = utils.NewCheckoutQuote(order.OrderNumber, amount, order.WalletAddress)
+		if err != nil {
+			log.Printf("Failed to sign checkout quote: %v", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create checkout quote"})
+		}
+
+		_, err = database.DB.Collection("orders").UpdateOne(
+			ctx,
+			bson.M{"_id": orderID},
+			bson.M{"$set": bson.M{
+				"quote":        quote,
+				"issuedQuotes": utils.RetainQuotes(order.PaymentQuotes(), quote),
+				"updatedAt":    time.Now(),
+			}},
+		)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update order"})
+		}
+	}
+
+	// A partially paid order stays as it is and accepts a top-up for the balance due
+	if order.Status == models.OrderStatusPartiallyPaid {
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"message":     "Top-up payment required",
+			"orderNumber": order.OrderNumber,
+			"amountPaid":  order.AmountPaid,
+			"balanceDue":  order.BalanceDue,
+			"quote":       quote,
+		})
+	}
+
 	// Update order status to pending
 	update := bson.M{
 		"$set": bson.M{
@@ -161,11 +300,19 @@ func ProcessPayment(c echo.Context) error {
 		},
 	}
 
-	_, err = database.DB.Collection("orders").UpdateOne(ctx, bson.M{"_id": orderID}, update)
+	// A payment applied since the order was read must not be undone
+	_, err = database.DB.Collection("orders").UpdateOne(ctx, bson.M{"_id": orderID, "status": order.Status}, update)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update order"})
 	}
 
+	if quote != nil {
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"message": "Payment processing initiated",
+			"quote":   quote,
+		})
+	}
+
 	return c.JSON(http.StatusOK, map[string]string{"message": "Payment processing initiated"})
 }
 
@@ -206,9 +353,68 @@ func GetOrders(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to decode orders"})
 	}
 
+	attachOrderENSNames(ctx, orders)
+
 	return c.JSON(http.StatusOK, orders)
 }
 
+// GetFulfillmentOrders lists every customer's orders, newest first, for fulfilment
+// partners. Filters: status and fulfillmentStatus (comma separated). Pages continue
+// with ?beforeId= set to the previous page's nextBeforeId.
+func GetFulfillmentOrders(c echo.Context) error {
+	filter := bson.M{}
+	for param, field := range map[string]string{
+		"status":            "status",
+		"fulfillmentStatus": "fulfillmentStatus",
+	} {
+		if value := c.QueryParam(param); value != "" {
+			filter[field] = bson.M{"$in": strings.Split(value, ",")}
+		}
+	}
+
+	if value := c.QueryParam("beforeId"); value != "" {
+		beforeID, err := primitive.ObjectIDFromHex(value)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid beforeId"})
+		}
+		filter["_id"] = bson.M{"$lt": beforeID}
+	}
+
+	limit := defaultFulfillmentPageSize
+	if value := c.QueryParam("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid limit"})
+		}
+		if n > maxFulfillmentPageSize {
+			n = maxFulfillmentPageSize
+		}
+		limit = n
+	}
+
+	ctx := c.Request().Context()
+	cursor, err := database.DB.Collection("orders").Find(
+		ctx,
+		filter,
+		options.Find().SetSort(bson.M{"_id": -1}).SetLimit(int64(limit)),
+	)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch orders"})
+	}
+	defer cursor.Close(ctx)
+
+	orders := []models.Order{}
+	if err := cursor.All(ctx, &orders); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to decode orders"})
+	}
+
+	response := map[string]interface{}{"orders": orders}
+	if len(orders) == limit {
+		response["nextBeforeId"] = orders[len(orders)-1].ID.Hex()
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
 // UpdateOrderFulfillment updates the order fulfillment status
 func UpdateOrderFulfillment(c echo.Context) error {
 	orderID := c.Param("orderId")
@@ -222,24 +428,47 @@ func UpdateOrderFulfillment(c echo.Context) error {
 	}
 
 	objID, _ := primitive.ObjectIDFromHex(orderID)
+	now := time.Now()
 	update := bson.M{
 		"$set": bson.M{
 			"fulfillmentStatus": req.Status,
 			"trackingNumber":    req.TrackingNumber,
-			"updatedAt":         time.Now(),
+			"updatedAt":         now,
 		},
 	}
 
-	_, err := database.DB.Collection("orders").UpdateOne(
+	// The previous document is kept for the audit log
+	var order models.Order
+	err := database.DB.Collection("orders").FindOneAndUpdate(
 		c.Request().Context(),
 		bson.M{"_id": objID},
 		update,
-	)
+		options.FindOneAndUpdate().SetReturnDocument(options.Before),
+	).Decode(&order)
 
 	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Order not found"})
+		}
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
 
+	before := order
+	order.FulfillmentStatus = req.Status
+	order.TrackingNumber = req.TrackingNumber
+	order.UpdatedAt = now
+
+	audit(c, models.AuditEntry{
+		Action:     models.AuditFulfillmentUpdated,
+		TargetType: models.AuditTargetOrder,
+		TargetID:   order.ID.Hex(),
+		Changes: utils.AuditDiff(
+			map[string]interface{}{"fulfillmentStatus": before.FulfillmentStatus, "trackingNumber": before.TrackingNumber},
+			map[string]interface{}{"fulfillmentStatus": order.FulfillmentStatus, "trackingNumber": order.TrackingNumber},
+		),
+	})
+	utils.PublishOrderEvent(utils.OrderEventFulfillment, order)
+
 	return c.JSON(http.StatusOK, map[string]string{"status": "updated"})
 }
 
@@ -267,6 +496,7 @@ func GetOrder(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch order"})
 	}
 
+	attachOrderENSName(c.Request().Context(), &order)
 	return c.JSON(http.StatusOK, order)
 }
 
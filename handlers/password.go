@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/database"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/utils"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	minPasswordLength = 8
+
+	// Minimum time between reset emails for the same user
+	passwordResetInterval = time.Minute
+)
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+}
+
+// ForgotPassword mails a reset link. It answers the same way whether or not the
+// email is registered, so it cannot be used to discover accounts.
+func ForgotPassword(c echo.Context) error {
+	var req ForgotPasswordRequest
+	if err := c.Bind(&req); err != nil || req.Email == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Email is required"})
+	}
+
+	// Even the account lookup happens after responding, so the response time doesn't
+	// depend on whether the email is registered
+	email := req.Email
+	entry := requestAuditEntry(c, models.AuditEntry{
+		Action:     models.AuditPasswordResetSent,
+		TargetType: models.AuditTargetUser,
+	})
+	utils.SendInBackground("password reset email", func(ctx context.Context) error {
+		return sendPasswordReset(ctx, email, entry)
+	})
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "If the email is registered, a reset link has been sent"})
+}
+
+// sendPasswordReset mails a reset link if the email belongs to a user who hasn't been
+// sent one in the last passwordResetInterval
+func sendPasswordReset(ctx context.Context, email string, entry models.AuditEntry) error {
+	var user models.User
+	err := database.DB.Collection("users").FindOne(ctx, bson.M{"email": email}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	lastSent, err := utils.LastUserTokenIssuedAt(ctx, user.ID, models.UserTokenPasswordReset)
+	if err != nil {
+		return err
+	}
+	if time.Since(lastSent) < passwordResetInterval {
+		return nil
+	}
+
+	if err := utils.SendPasswordResetEmail(ctx, user); err != nil {
+		return fmt.Errorf("%s: %v", user.Email, err)
+	}
+	entry.TargetID = user.ID.Hex()
+	utils.RecordAudit(entry)
+	return nil
+}
+
+// ResetPassword sets a new password from an emailed reset token and signs out every session
+func ResetPassword(c echo.Context) error {
+	var req ResetPasswordRequest
+	if err := c.Bind(&req); err != nil || req.Token == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Token is required"})
+	}
+
+	if len(req.Password) < minPasswordLength {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Password must be at least 8 characters"})
+	}
+
+	ctx := c.Request().Context()
+
+	token, err := utils.ConsumeUserToken(ctx, req.Token, models.UserTokenPasswordReset)
+	if err != nil {
+		if err == utils.ErrInvalidUserToken {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid or expired reset token"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to reset password"})
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to process password"})
+	}
+
+	// Receiving the link proves control of the address, so the email counts as verified too
+	result, err := database.DB.Collection("users").UpdateOne(
+		ctx,
+		bson.M{"_id": token.UserID, "email": token.Email},
+		bson.M{"$set": bson.M{
+			"password":      string(hashedPassword),
+			"emailVerified": true,
+			"updatedAt":     time.Now(),
+		}},
+	)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to reset password"})
+	}
+	if result.MatchedCount == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Email address has changed since the token was sent"})
+	}
+
+	if err := utils.RevokeUserSessions(ctx, token.UserID, primitive.NilObjectID); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to sign out existing sessions"})
+	}
+	// Holding the emailed token is what identifies the user here
+	auditUser(c, token.UserID, models.AuditPasswordReset, nil)
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Password reset successfully, please sign in again"})
+}
+
+// ChangePassword replaces the current user's password and signs out every other session
+func ChangePassword(c echo.Context) error {
+	userID := c.Get("userID").(primitive.ObjectID)
+
+	var req ChangePasswordRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	if len(req.NewPassword) < minPasswordLength {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Password must be at least 8 characters"})
+	}
+
+	ctx := c.Request().Context()
+
+	var user models.User
+	if err := database.DB.Collection("users").FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
+	}
+
+	if user.Password == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Account has no password, use forgot password to set one"})
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.CurrentPassword)); err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Current password is incorrect"})
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to process password"})
+	}
+
+	_, err = database.DB.Collection("users").UpdateOne(
+		ctx,
+		bson.M{"_id": userID},
+		bson.M{"$set": bson.M{"password": string(hashedPassword), "updatedAt": time.Now()}},
+	)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update password"})
+	}
+
+	if err := utils.RevokeUserSessions(ctx, userID, currentSessionID(c)); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to sign out existing sessions"})
+	}
+	auditUser(c, userID, models.AuditPasswordChanged, nil)
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Password changed, other sessions have been signed out"})
+}
@@ -0,0 +1,337 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/database"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	defaultProductPageSize = 20
+	maxProductPageSize     = 100
+)
+
+var errCursorSort = errors.New("cursor was made for a different sort")
+
+// productSort is one way of ordering product listings. Products are ordered by a
+// stored, indexed field, then by ID so that pages never overlap.
+type productSort struct {
+	field      string
+	descending bool
+}
+
+var productSorts = map[string]productSort{
+	"newest":     {field: "createdAt", descending: true},
+	"price_asc":  {field: "priceUSD"},
+	"price_desc": {field: "priceUSD", descending: true},
+	"rating":     {field: "avgRating", descending: true},
+	// Most rated first, as no sales count is kept on products
+	"popularity": {field: "ratingCount", descending: true},
+}
+
+// Product fields that can be picked with the fields parameter, by JSON name
+var productListFields = map[string]bool{
+	"name": true, "description": true, "price": true, "priceUSD": true,
+	"options": true, "variants": true, "images": true, "createdAt": true,
+	"updatedAt": true, "categories": true, "tags": true, "ratings": true,
+	"avgRating": true, "ratingCount": true, "gate": true, "discounts": true, "version": true,
+	"archivedAt": true,
+}
+
+// productCursor marks where the previous page ended
+type productCursor struct {
+	Sort  string          `json:"s"`
+	Value json.RawMessage `json:"v"`
+	ID    string          `json:"id"`
+}
+
+// listProducts writes one page of the products matching filter. It reads limit,
+// cursor, sort (newest, price_asc, price_desc, rating or popularity) and fields,
+// a comma separated list of fields to return, or to leave out when prefixed with "-".
+// The total number of matches goes in X-Total-Count and the cursor of the next page
+// in X-Next-Cursor and a Link header.
+//
+// Price sorts compare priceUSD, since wei amounts are stored as strings. Products
+// without a USD price have a priceUSD of 0 and sort as the cheapest.
+func listProducts(c echo.Context, filter bson.M) error {
+	limit := defaultProductPageSize
+	if value := c.QueryParam("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid limit"})
+		}
+		if n > maxProductPageSize {
+			n = maxProductPageSize
+		}
+		limit = n
+	}
+
+	sortName := c.QueryParam("sort")
+	if sortName == "" {
+		sortName = "newest"
+	}
+	sort, ok := productSorts[sortName]
+	if !ok {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid sort, use newest, price_asc, price_desc, rating or popularity"})
+	}
+
+	fields, exclude, message := parseProductFields(c.QueryParam("fields"))
+	if message != "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": message})
+	}
+
+	page := filter
+	if raw := c.QueryParam("cursor"); raw != "" {
+		after, err := productCursorFilter(raw, sortName, sort)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid cursor"})
+		}
+		page = bson.M{"$and": bson.A{filter, after}}
+	}
+
+	direction := 1
+	if sort.descending {
+		direction = -1
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: sort.field, Value: direction}, {Key: "_id", Value: direction}}).
+		SetLimit(int64(limit))
+	if projection := productProjection(fields, exclude, sort.field); projection != nil {
+		opts.SetProjection(projection)
+	}
+
+	ctx := c.Request().Context()
+	collection := database.DB.Collection("products")
+
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to count products"})
+	}
+
+	cursor, err := collection.Find(ctx, page, opts)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch products"})
+	}
+	defer cursor.Close(ctx)
+
+	var listed []models.Product
+	var lastSortValue interface{}
+	for cursor.Next(ctx) {
+		var product models.Product
+		if err := cursor.Decode(&product); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to decode products"})
+		}
+		listed = append(listed, product)
+		lastSortValue = rawSortValue(cursor.Current, sort.field)
+	}
+	if err := cursor.Err(); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch products"})
+	}
+
+	c.Response().Header().Set("X-Total-Count", strconv.FormatInt(total, 10))
+	if len(listed) == limit {
+		last := listed[len(listed)-1]
+		next, err := encodeProductCursor(sortName, lastSortValue, last.ID)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to build cursor"})
+		}
+		query := c.QueryParams()
+		query.Set("cursor", next)
+		c.Response().Header().Set("X-Next-Cursor", next)
+		c.Response().Header().Set("Link", `<`+c.Request().URL.Path+"?"+query.Encode()+`>; rel="next"`)
+	}
+
+	products := make([]interface{}, len(listed))
+	for i, item := range listed {
+		if fields == nil {
+			products[i] = item
+			continue
+		}
+		sparse, err := sparseProduct(item, fields, exclude)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to encode products"})
+		}
+		products[i] = sparse
+	}
+	return c.JSON(http.StatusOK, products)
+}
+
+// parseProductFields reads the fields parameter. The returned set is nil when all
+// fields are wanted.
+func parseProductFields(value string) (map[string]bool, bool, string) {
+	if value == "" {
+		return nil, false, ""
+	}
+
+	fields := map[string]bool{}
+	exclude := strings.HasPrefix(value, "-")
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if strings.HasPrefix(field, "-") != exclude {
+			return nil, false, "Fields must all be included or all be excluded"
+		}
+		field = strings.TrimPrefix(field, "-")
+		if field == "id" && !exclude {
+			continue
+		}
+		if !productListFields[field] {
+			return nil, false, "Unknown field " + field
+		}
+		fields[field] = true
+	}
+	return fields, exclude, ""
+}
+
+// productProjection limits what is read from the database to the requested fields.
+// Fields and BSON names are the same apart from the ID, which is always returned.
+// The sort field is always read, as the next page's cursor is built from it.
+func productProjection(fields map[string]bool, exclude bool, sortField string) bson.M {
+	if fields == nil {
+		return nil
+	}
+
+	projection := bson.M{}
+	if exclude {
+		for field := range fields {
+			if field != sortField {
+				projection[field] = 0
+			}
+		}
+		if len(projection) == 0 {
+			return nil
+		}
+		return projection
+	}
+
+	projection["_id"] = 1
+	projection[sortField] = 1
+	for field := range fields {
+		projection[field] = 1
+	}
+	return projection
+}
+
+// sparseProduct encodes only the requested fields of a product
+func sparseProduct(product models.Product, fields map[string]bool, exclude bool) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(product)
+	if err != nil {
+		return nil, err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+
+	sparse := make(map[string]json.RawMessage, len(all))
+	for field, value := range all {
+		if field == "id" || fields[field] != exclude {
+			sparse[field] = value
+		}
+	}
+	return sparse, nil
+}
+
+// rawSortValue reads the value a product was sorted by; nil when it has none
+func rawSortValue(doc bson.Raw, field string) interface{} {
+	value, err := doc.LookupErr(field)
+	if err != nil {
+		return nil
+	}
+	switch value.Type {
+	case bsontype.DateTime:
+		return value.DateTime()
+	case bsontype.Double:
+		return value.Double()
+	case bsontype.Int32:
+		return value.Int32()
+	case bsontype.Int64:
+		return value.Int64()
+	}
+	return nil
+}
+
+func encodeProductCursor(sortName string, value interface{}, id primitive.ObjectID) (string, error) {
+	// Dates are kept as Unix milliseconds, which is how they are stored
+	if date, ok := value.(primitive.DateTime); ok {
+		value = int64(date)
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(productCursor{Sort: sortName, Value: raw, ID: id.Hex()})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// productCursorFilter matches the products after the cursor in the sort order
+func productCursorFilter(raw, sortName string, sort productSort) (bson.M, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, err
+	}
+	var cursor productCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	if cursor.Sort != sortName {
+		return nil, errCursorSort
+	}
+	id, err := primitive.ObjectIDFromHex(cursor.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	operator := "$gt"
+	if sort.descending {
+		operator = "$lt"
+	}
+
+	// Products missing the field sort before every value, so they come first in
+	// ascending order and last in descending order
+	if string(cursor.Value) == "null" {
+		after := bson.A{bson.M{sort.field: nil, "_id": bson.M{operator: id}}}
+		if !sort.descending {
+			after = append(after, bson.M{sort.field: bson.M{"$ne": nil}})
+		}
+		return bson.M{"$or": after}, nil
+	}
+
+	var value interface{}
+	if sortName == "newest" {
+		var ms int64
+		if err := json.Unmarshal(cursor.Value, &ms); err != nil {
+			return nil, err
+		}
+		value = primitive.DateTime(ms)
+	} else {
+		var n float64
+		if err := json.Unmarshal(cursor.Value, &n); err != nil {
+			return nil, err
+		}
+		value = n
+	}
+
+	after := bson.A{
+		bson.M{sort.field: bson.M{operator: value}},
+		bson.M{sort.field: value, "_id": bson.M{operator: id}},
+	}
+	if sort.descending {
+		after = append(after, bson.M{sort.field: nil})
+	}
+	return bson.M{"$or": after}, nil
+}
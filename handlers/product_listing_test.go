@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// nextPageFilter does what listProducts does between pages: read the sort value off
+// the last stored product, put it in a cursor and turn the cursor back into a filter
+func nextPageFilter(t *testing.T, sortName string, last interface{}, id primitive.ObjectID) bson.M {
+	t.Helper()
+
+	doc, err := bson.Marshal(last)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort := productSorts[sortName]
+	cursor, err := encodeProductCursor(sortName, rawSortValue(doc, sort.field), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter, err := productCursorFilter(cursor, sortName, sort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filter
+}
+
+func TestNextPageAfterNewestProduct(t *testing.T) {
+	product := models.Product{
+		ID:        primitive.NewObjectID(),
+		CreatedAt: time.Date(2026, 3, 1, 12, 0, 0, 987654321, time.UTC),
+	}
+	created := primitive.NewDateTimeFromTime(product.CreatedAt)
+
+	got := nextPageFilter(t, "newest", product, product.ID)
+	want := bson.M{"$or": bson.A{
+		bson.M{"createdAt": bson.M{"$lt": created}},
+		bson.M{"createdAt": created, "_id": bson.M{"$lt": product.ID}},
+		bson.M{"createdAt": nil},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("filter = %v, want %v", got, want)
+	}
+}
+
+func TestNextPageAfterCheapestProduct(t *testing.T) {
+	product := models.Product{ID: primitive.NewObjectID(), PriceUSD: 24.99}
+
+	got := nextPageFilter(t, "price_asc", product, product.ID)
+	want := bson.M{"$or": bson.A{
+		bson.M{"priceUSD": bson.M{"$gt": 24.99}},
+		bson.M{"priceUSD": 24.99, "_id": bson.M{"$gt": product.ID}},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("filter = %v, want %v", got, want)
+	}
+}
+
+// Integer counts come back from the cursor as float64, which Mongo compares equal
+func TestNextPageAfterMostRatedProduct(t *testing.T) {
+	id := primitive.NewObjectID()
+
+	got := nextPageFilter(t, "popularity", bson.M{"_id": id, "ratingCount": int32(12)}, id)
+	want := bson.M{"$or": bson.A{
+		bson.M{"ratingCount": bson.M{"$lt": 12.0}},
+		bson.M{"ratingCount": 12.0, "_id": bson.M{"$lt": id}},
+		bson.M{"ratingCount": nil},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("filter = %v, want %v", got, want)
+	}
+}
+
+// Products without the sort field come first in ascending order and last in
+// descending order, so the next page continues among them by ID
+func TestNextPageAfterProductMissingSortField(t *testing.T) {
+	id := primitive.NewObjectID()
+	unpriced := bson.M{"_id": id}
+
+	ascending := nextPageFilter(t, "price_asc", unpriced, id)
+	wantAscending := bson.M{"$or": bson.A{
+		bson.M{"priceUSD": nil, "_id": bson.M{"$gt": id}},
+		bson.M{"priceUSD": bson.M{"$ne": nil}},
+	}}
+	if !reflect.DeepEqual(ascending, wantAscending) {
+		t.Errorf("ascending filter = %v, want %v", ascending, wantAscending)
+	}
+
+	descending := nextPageFilter(t, "price_desc", unpriced, id)
+	wantDescending := bson.M{"$or": bson.A{bson.M{"priceUSD": nil, "_id": bson.M{"$lt": id}}}}
+	if !reflect.DeepEqual(descending, wantDescending) {
+		t.Errorf("descending filter = %v, want %v", descending, wantDescending)
+	}
+}
+
+func TestProductCursorFilterRejectsForeignCursors(t *testing.T) {
+	cursor, err := encodeProductCursor("price_asc", 10.0, primitive.NewObjectID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := productCursorFilter(cursor, "rating", productSorts["rating"]); !errors.Is(err, errCursorSort) {
+		t.Errorf("cursor from another sort: error = %v, want errCursorSort", err)
+	}
+	for name, raw := range map[string]string{
+		"not base64": "%%%",
+		"not JSON":   "bm90IGpzb24",
+		"bad value":  "eyJzIjoibmV3ZXN0IiwidiI6InllcyIsImlkIjoiNjY1MGYxYzJhMWIyYzNkNGU1ZjYwNzE4In0",
+	} {
+		if _, err := productCursorFilter(raw, "newest", productSorts["newest"]); err == nil {
+			t.Errorf("%s cursor accepted", name)
+		}
+	}
+}
+
+func TestSparseProductKeepsID(t *testing.T) {
+	product := models.Product{ID: primitive.NewObjectID(), Name: "Hoodie", PriceUSD: 40}
+
+	picked, err := sparseProduct(product, map[string]bool{"name": true}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(picked) != 2 || picked["id"] == nil || picked["name"] == nil {
+		t.Errorf("fields=name gave %v, want id and name", picked)
+	}
+
+	dropped, err := sparseProduct(product, map[string]bool{"name": true}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dropped["name"] != nil || dropped["id"] == nil || dropped["priceUSD"] == nil {
+		t.Errorf("excluding name gave %v", dropped)
+	}
+}
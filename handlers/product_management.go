@@ -0,0 +1,513 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/database"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/utils"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const maxProductNameLength = 200
+
+// Fields managed by the server that updates may not set
+var readOnlyProductFields = map[string]bool{
+	"id":          true,
+	"createdAt":   true,
+	"updatedAt":   true,
+	"ratings":     true,
+	"avgRating":   true,
+	"ratingCount": true,
+	"archivedAt":  true,
+}
+
+// availableProducts limits a product query to products that are listed and can be bought
+func availableProducts(filter bson.M) bson.M {
+	filter["archivedAt"] = bson.M{"$exists": false}
+	filter["deletedAt"] = bson.M{"$exists": false}
+	return filter
+}
+
+// validateProduct checks the fields an admin sets and returns a message for the first
+// problem. The price must already be converted to wei.
+func validateProduct(product models.Product) string {
+	name := strings.TrimSpace(product.Name)
+	if name == "" {
+		return "Name is required"
+	}
+	if len(name) > maxProductNameLength {
+		return "Name must be at most 200 characters"
+	}
+
+	if price, ok := new(big.Int).SetString(product.Price, 10); !ok || price.Sign() <= 0 {
+		return "Price must be positive"
+	}
+	if product.PriceUSD < 0 {
+		return "USD price can't be negative"
+	}
+
+	if message := validateVariants(product); message != "" {
+		return message
+	}
+
+	// Validate gating rules and holder discounts
+	if product.Gate != nil {
+		if err := utils.ValidateTokenRequirement(*product.Gate); err != nil {
+			return err.Error()
+		}
+	}
+	for _, discount := range product.Discounts {
+		if err := utils.ValidateHolderDiscount(discount); err != nil {
+			return err.Error()
+		}
+	}
+	return ""
+}
+
+// validateVariants checks that the options are well formed and that every variant
+// picks one value of each option, with no two variants picking the same values
+func validateVariants(product models.Product) string {
+	optionValues := make(map[string]map[string]bool, len(product.Options))
+	for _, option := range product.Options {
+		if strings.TrimSpace(option.Name) == "" {
+			return "Option names are required"
+		}
+		if optionValues[option.Name] != nil {
+			return "Duplicate option " + option.Name
+		}
+		if len(option.Values) == 0 {
+			return "Option " + option.Name + " needs at least one value"
+		}
+		values := make(map[string]bool, len(option.Values))
+		for _, value := range option.Values {
+			if strings.TrimSpace(value) == "" {
+				return "Option " + option.Name + " has an empty value"
+			}
+			if values[value] {
+				return "Duplicate value " + value + " for option " + option.Name
+			}
+			values[value] = true
+		}
+		optionValues[option.Name] = values
+	}
+
+	if len(product.Variants) == 0 {
+		return "At least one variant is required"
+	}
+
+	skus := make(map[string]bool, len(product.Variants))
+	combinations := make(map[string]string, len(product.Variants))
+	for _, variant := range product.Variants {
+		if !validSKU(variant.SKU) {
+			return "SKUs must be 1 to 64 letters, digits, dashes, underscores or dots"
+		}
+		if skus[variant.SKU] {
+			return "Duplicate SKU " + variant.SKU
+		}
+		skus[variant.SKU] = true
+
+		if len(variant.Options) != len(product.Options) {
+			return "Variant " + variant.SKU + " must set exactly one value for each option"
+		}
+		values := make([]string, 0, len(product.Options))
+		for _, option := range product.Options {
+			value, ok := variant.Options[option.Name]
+			if !ok || !optionValues[option.Name][value] {
+				return "Variant " + variant.SKU + " has no valid " + option.Name
+			}
+			values = append(values, value)
+		}
+		combination := strings.Join(values, "\x00")
+		if other, ok := combinations[combination]; ok {
+			return "Variants " + other + " and " + variant.SKU + " have the same options"
+		}
+		combinations[combination] = variant.SKU
+
+		if variant.Stock < 0 {
+			return "Stock can't be negative"
+		}
+		if variant.Price != "" {
+			if price, ok := new(big.Int).SetString(variant.Price, 10); !ok || price.Sign() <= 0 {
+				return "Variant " + variant.SKU + " price must be positive"
+			}
+		}
+	}
+	return ""
+}
+
+// prepareVariants converts variant prices sent in ETH to wei and generates the SKUs
+// the client left out. Only call it on variants that came from the request.
+func prepareVariants(product *models.Product) string {
+	for i := range product.Variants {
+		variant := &product.Variants[i]
+
+		if variant.Price != "" {
+			priceInWei, err := utils.PriceToWei(variant.Price)
+			if err != nil {
+				return "Invalid price format for variant " + variant.SKU
+			}
+			variant.Price = priceInWei.String()
+		}
+
+		variant.SKU = strings.TrimSpace(variant.SKU)
+		if variant.SKU == "" {
+			values := make([]string, 0, len(product.Options))
+			for _, option := range product.Options {
+				values = append(values, variant.Options[option.Name])
+			}
+			variant.SKU = utils.GenerateSKU(product.ID, values)
+		}
+	}
+	return ""
+}
+
+func validSKU(sku string) bool {
+	if sku == "" || len(sku) > utils.MaxSKULength {
+		return false
+	}
+	for _, r := range sku {
+		if !(r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// UpdateProduct replaces a product's editable fields. Fields left out are cleared.
+func UpdateProduct(c echo.Context) error {
+	return updateProduct(c, true)
+}
+
+// PatchProduct changes only the fields present in the body. Fields that are lists,
+// such as variants, are replaced as a whole.
+func PatchProduct(c echo.Context) error {
+	return updateProduct(c, false)
+}
+
+// updateProduct applies a PUT or PATCH body. The caller must send the version it last
+// read, in If-Match or the version field, and gets 409 if the product changed since.
+func updateProduct(c echo.Context, replace bool) error {
+	productID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid product ID"})
+	}
+
+	body, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	for field := range fields {
+		if readOnlyProductFields[field] {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Field " + field + " can't be changed"})
+		}
+	}
+
+	current, status, message := findManagedProduct(c, productID)
+	if status != 0 {
+		return c.JSON(status, map[string]string{"error": message})
+	}
+
+	expected, ok := expectedProductVersion(c, fields)
+	if !ok {
+		return c.JSON(http.StatusPreconditionRequired, map[string]string{"error": "Send the product version in If-Match or the version field"})
+	}
+	if expected != current.Version {
+		return productVersionConflict(c, current.ID)
+	}
+
+	base := current
+	if replace {
+		base = models.Product{
+			ID:          current.ID,
+			CreatedAt:   current.CreatedAt,
+			Ratings:     current.Ratings,
+			AvgRating:   current.AvgRating,
+			RatingCount: current.RatingCount,
+			ArchivedAt:  current.ArchivedAt,
+		}
+	}
+	updated, err := mergeProductFields(base, fields)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	// Prices are sent in ETH and stored in wei
+	if _, ok := fields["price"]; ok {
+		priceInWei, err := utils.PriceToWei(updated.Price)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid price format"})
+		}
+		updated.Price = priceInWei.String()
+	}
+	if _, ok := fields["variants"]; ok {
+		if message := prepareVariants(&updated); message != "" {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": message})
+		}
+	}
+
+	if message := validateProduct(updated); message != "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": message})
+	}
+	if _, ok := fields["categories"]; ok {
+		if status, message := checkProductCategories(c, updated.Categories); status != 0 {
+			return c.JSON(status, map[string]string{"error": message})
+		}
+	}
+
+	updated.UpdatedAt = time.Now()
+	updated.Version = current.Version + 1
+
+	set := bson.M{
+		"name":        updated.Name,
+		"description": updated.Description,
+		"price":       updated.Price,
+		"priceUSD":    updated.PriceUSD,
+		"options":     updated.Options,
+		"variants":    updated.Variants,
+		"images":      updated.Images,
+		"categories":  updated.Categories,
+		"tags":        updated.Tags,
+		"discounts":   updated.Discounts,
+		"updatedAt":   updated.UpdatedAt,
+		"version":     updated.Version,
+	}
+	update := bson.M{"$set": set}
+	if updated.Gate != nil {
+		set["gate"] = updated.Gate
+	} else {
+		update["$unset"] = bson.M{"gate": ""}
+	}
+
+	// Ratings are left alone, so concurrent reviews aren't lost
+	if status, message := saveProductVersion(c, current, update); status != 0 {
+		if status == http.StatusConflict {
+			return productVersionConflict(c, current.ID)
+		}
+		return c.JSON(status, map[string]string{"error": message})
+	}
+
+	audit(c, models.AuditEntry{
+		Action:     models.AuditProductUpdated,
+		TargetType: models.AuditTargetProduct,
+		TargetID:   updated.ID.Hex(),
+		Changes:    utils.AuditDiff(current, updated),
+		Metadata:   map[string]string{"version": strconv.FormatInt(updated.Version, 10)},
+	})
+
+	setProductETag(c, updated)
+	return c.JSON(http.StatusOK, updated)
+}
+
+// ArchiveProduct hides a product from listings and stops new purchases of it
+func ArchiveProduct(c echo.Context) error {
+	return setProductArchived(c, true)
+}
+
+// UnarchiveProduct lists an archived product again
+func UnarchiveProduct(c echo.Context) error {
+	return setProductArchived(c, false)
+}
+
+func setProductArchived(c echo.Context, archived bool) error {
+	productID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid product ID"})
+	}
+
+	product, status, message := findManagedProduct(c, productID)
+	if status != 0 {
+		return c.JSON(status, map[string]string{"error": message})
+	}
+	if expected, ok := expectedProductVersion(c, nil); ok && expected != product.Version {
+		return productVersionConflict(c, product.ID)
+	}
+	if (product.ArchivedAt != nil) == archived {
+		setProductETag(c, product)
+		return c.JSON(http.StatusOK, product)
+	}
+
+	now := time.Now()
+	update := bson.M{
+		"$set": bson.M{"updatedAt": now, "version": product.Version + 1},
+	}
+	action := models.AuditProductRestored
+	if archived {
+		update["$set"].(bson.M)["archivedAt"] = now
+		action = models.AuditProductArchived
+	} else {
+		update["$unset"] = bson.M{"archivedAt": ""}
+	}
+
+	if status, message := saveProductVersion(c, product, update); status != 0 {
+		if status == http.StatusConflict {
+			return productVersionConflict(c, product.ID)
+		}
+		return c.JSON(status, map[string]string{"error": message})
+	}
+
+	product.UpdatedAt = now
+	product.Version++
+	product.ArchivedAt = nil
+	if archived {
+		product.ArchivedAt = &now
+	}
+	audit(c, models.AuditEntry{
+		Action:     action,
+		TargetType: models.AuditTargetProduct,
+		TargetID:   product.ID.Hex(),
+	})
+
+	setProductETag(c, product)
+	return c.JSON(http.StatusOK, product)
+}
+
+// DeleteProduct soft-deletes a product. It disappears from the API but the document
+// stays, so orders, receipts and ratings that reference it keep working.
+func DeleteProduct(c echo.Context) error {
+	productID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid product ID"})
+	}
+
+	product, status, message := findManagedProduct(c, productID)
+	if status != 0 {
+		return c.JSON(status, map[string]string{"error": message})
+	}
+	if expected, ok := expectedProductVersion(c, nil); ok && expected != product.Version {
+		return productVersionConflict(c, product.ID)
+	}
+
+	now := time.Now()
+	update := bson.M{"$set": bson.M{"deletedAt": now, "updatedAt": now, "version": product.Version + 1}}
+	if status, message := saveProductVersion(c, product, update); status != 0 {
+		if status == http.StatusConflict {
+			return productVersionConflict(c, product.ID)
+		}
+		return c.JSON(status, map[string]string{"error": message})
+	}
+
+	audit(c, models.AuditEntry{
+		Action:     models.AuditProductDeleted,
+		TargetType: models.AuditTargetProduct,
+		TargetID:   product.ID.Hex(),
+		Metadata:   map[string]string{"name": product.Name},
+	})
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// mergeProductFields overlays the top-level fields of the body on the product. Nested
+// values are replaced rather than merged, so a variant sent without a price loses its
+// price override instead of keeping the old one.
+func mergeProductFields(product models.Product, fields map[string]json.RawMessage) (models.Product, error) {
+	data, err := json.Marshal(product)
+	if err != nil {
+		return models.Product{}, err
+	}
+	var merged map[string]json.RawMessage
+	if err := json.Unmarshal(data, &merged); err != nil {
+		return models.Product{}, err
+	}
+	for field, value := range fields {
+		merged[field] = value
+	}
+
+	if data, err = json.Marshal(merged); err != nil {
+		return models.Product{}, err
+	}
+	var updated models.Product
+	if err := json.Unmarshal(data, &updated); err != nil {
+		return models.Product{}, err
+	}
+	return updated, nil
+}
+
+// findManagedProduct loads a product that has not been deleted
+func findManagedProduct(c echo.Context, productID primitive.ObjectID) (models.Product, int, string) {
+	var product models.Product
+	err := database.DB.Collection("products").FindOne(
+		c.Request().Context(),
+		bson.M{"_id": productID, "deletedAt": bson.M{"$exists": false}},
+	).Decode(&product)
+	if err == mongo.ErrNoDocuments {
+		return models.Product{}, http.StatusNotFound, "Product not found"
+	}
+	if err != nil {
+		return models.Product{}, http.StatusInternalServerError, "Failed to fetch product"
+	}
+	return product, 0, ""
+}
+
+// saveProductVersion applies update only if the product is still at the version it was
+// read at, returning 409 when another update got there first
+func saveProductVersion(c echo.Context, product models.Product, update bson.M) (int, string) {
+	// Products created before versioning have no version field, which reads as 0
+	version := interface{}(product.Version)
+	if product.Version == 0 {
+		version = bson.M{"$in": bson.A{0, nil}}
+	}
+
+	result, err := database.DB.Collection("products").UpdateOne(
+		c.Request().Context(),
+		bson.M{"_id": product.ID, "version": version, "deletedAt": bson.M{"$exists": false}},
+		update,
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return http.StatusBadRequest, "A SKU is already used by another product"
+	}
+	if err != nil {
+		return http.StatusInternalServerError, "Failed to update product"
+	}
+	if result.MatchedCount == 0 {
+		return http.StatusConflict, ""
+	}
+	return 0, ""
+}
+
+// expectedProductVersion reads the version the client last saw from If-Match, or from
+// the version field of the body
+func expectedProductVersion(c echo.Context, fields map[string]json.RawMessage) (int64, bool) {
+	if ifMatch := c.Request().Header.Get("If-Match"); ifMatch != "" {
+		version, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(ifMatch, "W/"), `"`), 10, 64)
+		return version, err == nil
+	}
+
+	raw, ok := fields["version"]
+	if !ok {
+		return 0, false
+	}
+	var version int64
+	if err := json.Unmarshal(raw, &version); err != nil {
+		return 0, false
+	}
+	return version, true
+}
+
+// productVersionConflict answers 409 with the product's current version
+func productVersionConflict(c echo.Context, productID primitive.ObjectID) error {
+	response := map[string]interface{}{"error": "Product was changed by someone else, reload it and try again"}
+	if product, status, _ := findManagedProduct(c, productID); status == 0 {
+		response["currentVersion"] = product.Version
+	}
+	return c.JSON(http.StatusConflict, response)
+}
+
+// setProductETag exposes the version for clients to send back in If-Match
+func setProductETag(c echo.Context, product models.Product) {
+	c.Response().Header().Set("ETag", `"`+strconv.FormatInt(product.Version, 10)+`"`)
+}
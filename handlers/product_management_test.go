@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func hoodie() models.Product {
+	return models.Product{
+		ID: primitive.NewObjectID(),
+		Options: []models.ProductOption{
+			{Name: "Size", Values: []string{"S", "M"}},
+			{Name: "Color", Values: []string{"Black", "Off White"}},
+		},
+		Variants: []models.ProductVariant{
+			{SKU: "HOODIE-S-BLK", Options: map[string]string{"Size": "S", "Color": "Black"}, Stock: 3},
+			{SKU: "HOODIE-M-BLK", Options: map[string]string{"Size": "M", "Color": "Black"}, Price: "2000000000000000"},
+		},
+	}
+}
+
+func TestValidateVariantsAcceptsHoodie(t *testing.T) {
+	if message := validateVariants(hoodie()); message != "" {
+		t.Fatalf("valid product rejected: %s", message)
+	}
+}
+
+func TestValidateVariantsRejects(t *testing.T) {
+	tests := map[string]struct {
+		change func(p *models.Product)
+		want   string
+	}{
+		"no variants": {
+			change: func(p *models.Product) { p.Variants = nil },
+			want:   "At least one variant is required",
+		},
+		"repeated SKU": {
+			change: func(p *models.Product) { p.Variants[1].SKU = p.Variants[0].SKU },
+			want:   "Duplicate SKU HOODIE-S-BLK",
+		},
+		"SKU with spaces": {
+			change: func(p *models.Product) { p.Variants[0].SKU = "hoodie small" },
+			want:   "SKUs must be",
+		},
+		"same options as another variant": {
+			change: func(p *models.Product) { p.Variants[1].Options["Size"] = "S" },
+			want:   "Variants HOODIE-S-BLK and HOODIE-M-BLK have the same options",
+		},
+		"value the product does not offer": {
+			change: func(p *models.Product) { p.Variants[0].Options["Size"] = "XL" },
+			want:   "Variant HOODIE-S-BLK has no valid Size",
+		},
+		"option left unset": {
+			change: func(p *models.Product) { delete(p.Variants[0].Options, "Color") },
+			want:   "must set exactly one value for each option",
+		},
+		"option listed twice": {
+			change: func(p *models.Product) { p.Options = append(p.Options, p.Options[0]) },
+			want:   "Duplicate option Size",
+		},
+		"negative stock": {
+			change: func(p *models.Product) { p.Variants[0].Stock = -1 },
+			want:   "Stock can't be negative",
+		},
+		"free variant": {
+			change: func(p *models.Product) { p.Variants[1].Price = "0" },
+			want:   "Variant HOODIE-M-BLK price must be positive",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			product := hoodie()
+			tc.change(&product)
+			if message := validateVariants(product); !strings.Contains(message, tc.want) {
+				t.Errorf("message = %q, want it to contain %q", message, tc.want)
+			}
+		})
+	}
+}
+
+func TestPrepareVariantsFillsMissingSKUs(t *testing.T) {
+	product := hoodie()
+	product.Variants[0].SKU = "  "
+	product.Variants[1].SKU = ""
+	product.Variants[1].Options["Color"] = "Off White"
+	product.Variants[1].Price = "0.002"
+
+	if message := prepareVariants(&product); message != "" {
+		t.Fatalf("prepareVariants: %s", message)
+	}
+
+	wantSKU := strings.ToUpper(product.ID.Hex()) + "-M-OFFWHITE"
+	if product.Variants[1].SKU != wantSKU {
+		t.Errorf("generated SKU = %s, want %s", product.Variants[1].SKU, wantSKU)
+	}
+	if product.Variants[1].Price != "2000000000000000" {
+		t.Errorf("price = %s wei, want 2000000000000000", product.Variants[1].Price)
+	}
+	if message := validateVariants(product); message != "" {
+		t.Errorf("generated SKUs rejected: %s", message)
+	}
+}
@@ -2,13 +2,13 @@ package handlers
 
 import (
 	"context"
-	"math/big"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/Madhav-Gupta-28/0xmart-backend-go/database"
 	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/utils"
 	"github.com/labstack/echo/v4"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -22,8 +22,12 @@ func GetProduct(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid product ID"})
 	}
 
+	// Archived products stay reachable by ID, for links from past orders
 	var product models.Product
-	err = database.DB.Collection("products").FindOne(c.Request().Context(), bson.M{"_id": objID}).Decode(&product)
+	err = database.DB.Collection("products").FindOne(
+		c.Request().Context(),
+		bson.M{"_id": objID, "deletedAt": bson.M{"$exists": false}},
+	).Decode(&product)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			return c.JSON(http.StatusNotFound, map[string]string{"error": "Product not found"})
@@ -31,28 +35,13 @@ func GetProduct(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch product"})
 	}
 
+	setProductETag(c, product)
 	return c.JSON(http.StatusOK, product)
 }
 
+// GetProducts lists the products that can be bought, a page at a time
 func GetProducts(c echo.Context) error {
-	var products []models.Product
-	collection := database.DB.Collection("products")
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	cursor, err := collection.Find(ctx, bson.M{})
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, err.Error())
-	}
-	defer cursor.Close(ctx)
-
-	for cursor.Next(ctx) {
-		var product models.Product
-		cursor.Decode(&product)
-		products = append(products, product)
-	}
-
-	return c.JSON(http.StatusOK, products)
+	return listProducts(c, availableProducts(bson.M{}))
 }
 
 func CreateProduct(c echo.Context) error {
@@ -66,32 +55,54 @@ func CreateProduct(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Price is required"})
 	}
 
-	// Convert price to Wei (multiply by 10^18)
-	priceFloat, ok := new(big.Float).SetString(product.Price)
-	if !ok {
+	// Convert price to Wei
+	priceInWei, err := utils.PriceToWei(product.Price)
+	if err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid price format"})
 	}
+	product.Price = priceInWei.String()
 
-	multiplier := new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil))
-	priceInWei := new(big.Float).Mul(priceFloat, multiplier)
+	// Generate new ObjectID for the product, which generated SKUs are based on
+	product.ID = primitive.NewObjectID()
+	if message := prepareVariants(&product); message != "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": message})
+	}
 
-	priceInt, _ := priceInWei.Int(nil)
-	product.Price = priceInt.String()
+	if message := validateProduct(product); message != "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": message})
+	}
+	if status, message := checkProductCategories(c, product.Categories); status != 0 {
+		return c.JSON(status, map[string]string{"error": message})
+	}
 
-	// Generate new ObjectID for the product
-	product.ID = primitive.NewObjectID()
 	product.CreatedAt = time.Now()
 	product.UpdatedAt = time.Now()
+	product.Ratings = nil
+	product.AvgRating = 0
+	product.RatingCount = 0
+	product.Version = 1
+	product.ArchivedAt = nil
+	product.DeletedAt = nil
 
 	collection := database.DB.Collection("products")
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	_, err := collection.InsertOne(ctx, product)
+	_, err = collection.InsertOne(ctx, product)
+	if mongo.IsDuplicateKeyError(err) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "A SKU is already used by another product"})
+	}
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create product"})
 	}
+	audit(c, models.AuditEntry{
+		Action:     models.AuditProductCreated,
+		TargetType: models.AuditTargetProduct,
+		TargetID:   product.ID.Hex(),
+		Changes:    utils.AuditDiff(nil, product),
+	})
 
+	setProductETag(c, product)
 	return c.JSON(http.StatusCreated, product)
 }
 
@@ -108,7 +119,12 @@ func GetOrderStatus(c echo.Context) error {
 		return c.JSON(http.StatusNotFound, map[string]string{"error": "Order not found"})
 	}
 
-	return c.JSON(http.StatusOK, map[string]string{"status": string(order.Status)})
+	return c.JSON(http.StatusOK, map[string]string{
+		"status":     string(order.Status),
+		"totalPrice": order.TotalPrice,
+		"amountPaid": order.AmountPaid,
+		"balanceDue": order.BalanceDue,
+	})
 }
 
 // SearchProducts handles product search with filters
@@ -118,7 +134,7 @@ func SearchProducts(c echo.Context) error {
 	minPrice := c.QueryParam("minPrice")
 	maxPrice := c.QueryParam("maxPrice")
 
-	filter := bson.M{}
+	filter := availableProducts(bson.M{})
 	if query != "" {
 		filter["$or"] = []bson.M{
 			{"name": bson.M{"$regex": query, "$options": "i"}},
@@ -127,37 +143,43 @@ func SearchProducts(c echo.Context) error {
 		}
 	}
 
+	// A category matches products in any of its subcategories too
 	if category != "" {
-		categoryID, _ := primitive.ObjectIDFromHex(category)
-		filter["categories"] = categoryID
+		found, err := utils.FindCategory(c.Request().Context(), category)
+		if err == utils.ErrCategoryNotFound {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Unknown category"})
+		}
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch category"})
+		}
+		categoryIDs, err := utils.CategoryDescendants(c.Request().Context(), found.ID)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch categories"})
+		}
+		filter["categories"] = bson.M{"$in": categoryIDs}
 	}
 
 	// Add price range filter if provided
 	if minPrice != "" || maxPrice != "" {
 		priceFilter := bson.M{}
 		if minPrice != "" {
-			min, _ := strconv.ParseFloat(minPrice, 64)
+			min, err := strconv.ParseFloat(minPrice, 64)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid minPrice"})
+			}
 			priceFilter["$gte"] = min
 		}
 		if maxPrice != "" {
-			max, _ := strconv.ParseFloat(maxPrice, 64)
+			max, err := strconv.ParseFloat(maxPrice, 64)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid maxPrice"})
+			}
 			priceFilter["$lte"] = max
 		}
 		filter["priceUSD"] = priceFilter
 	}
 
-	var products []models.Product
-	cursor, err := database.DB.Collection("products").Find(c.Request().Context(), filter)
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
-	}
-	defer cursor.Close(c.Request().Context())
-
-	if err = cursor.All(c.Request().Context(), &products); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
-	}
-
-	return c.JSON(http.StatusOK, products)
+	return listProducts(c, filter)
 }
 
 // RateProduct adds a rating to a product
@@ -186,6 +208,7 @@ func RateProduct(c echo.Context) error {
 
 	update := bson.M{
 		"$push": bson.M{"ratings": rating},
+		"$inc":  bson.M{"ratingCount": 1},
 		"$set":  bson.M{"updatedAt": time.Now()},
 	}
 
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/database"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/utils"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GrantRole adds a role to a user. It applies from the user's next login or token refresh.
+func GrantRole(c echo.Context) error {
+	userID, role, status, message := roleRequest(c)
+	if status != 0 {
+		return c.JSON(status, map[string]string{"error": message})
+	}
+
+	var user models.User
+	err := database.DB.Collection("users").FindOneAndUpdate(
+		c.Request().Context(),
+		bson.M{"_id": userID},
+		bson.M{
+			"$addToSet": bson.M{"roles": role},
+			"$set":      bson.M{"updatedAt": time.Now()},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to grant role"})
+	}
+	audit(c, models.AuditEntry{
+		Action:     models.AuditRoleGranted,
+		TargetType: models.AuditTargetUser,
+		TargetID:   userID.Hex(),
+		Metadata:   map[string]string{"role": role},
+	})
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"roles": user.Roles})
+}
+
+// RevokeRole removes a role from a user and ends their sessions, so tokens that still
+// carry the role stop working right away. The last admin cannot be removed.
+func RevokeRole(c echo.Context) error {
+	userID, role, status, message := roleRequest(c)
+	if status != 0 {
+		return c.JSON(status, map[string]string{"error": message})
+	}
+	ctx := c.Request().Context()
+	users := database.DB.Collection("users")
+
+	if role == models.RoleAdmin {
+		admins, err := users.CountDocuments(ctx, bson.M{"roles": models.RoleAdmin, "_id": bson.M{"$ne": userID}})
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to count admins"})
+		}
+		if admins == 0 {
+			return c.JSON(http.StatusConflict, map[string]string{"error": "Cannot remove the last admin"})
+		}
+	}
+
+	var user models.User
+	err := users.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": userID, "roles": role},
+		bson.M{
+			"$pull": bson.M{"roles": role},
+			"$set":  bson.M{"updatedAt": time.Now()},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found or does not have the role"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to revoke role"})
+	}
+
+	if err := utils.RevokeUserSessions(ctx, userID, primitive.NilObjectID); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Role revoked but failed to end the user's sessions"})
+	}
+	audit(c, models.AuditEntry{
+		Action:     models.AuditRoleRevoked,
+		TargetType: models.AuditTargetUser,
+		TargetID:   userID.Hex(),
+		Metadata:   map[string]string{"role": role},
+	})
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"roles": user.Roles})
+}
+
+// roleRequest reads the user and role from the path. A non-zero status is the
+// error to respond with.
+func roleRequest(c echo.Context) (primitive.ObjectID, string, int, string) {
+	userID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		return primitive.NilObjectID, "", http.StatusBadRequest, "Invalid user ID"
+	}
+
+	role := c.Param("role")
+	if !models.IsValidRole(role) {
+		return primitive.NilObjectID, "", http.StatusBadRequest, "Unknown role " + role
+	}
+	return userID, role, 0, ""
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/utils"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// sessionInfo describes the client making the request
+func sessionInfo(c echo.Context, mfa bool) utils.SessionInfo {
+	return utils.SessionInfo{
+		IP:        c.RealIP(),
+		UserAgent: c.Request().UserAgent(),
+		MFA:       mfa,
+	}
+}
+
+// currentSessionID returns the session of the access token used for the request
+func currentSessionID(c echo.Context) primitive.ObjectID {
+	sessionID, _ := c.Get("sessionID").(primitive.ObjectID)
+	return sessionID
+}
+
+// GetSessions lists the current user's active sessions
+func GetSessions(c echo.Context) error {
+	userID := c.Get("userID").(primitive.ObjectID)
+
+	sessions, err := utils.ListSessions(c.Request().Context(), userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch sessions"})
+	}
+
+	current := currentSessionID(c)
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == current
+	}
+
+	return c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeSession signs out one of the current user's sessions
+func RevokeSession(c echo.Context) error {
+	userID := c.Get("userID").(primitive.ObjectID)
+
+	sessionID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid session ID"})
+	}
+
+	sessions, err := utils.ListSessions(c.Request().Context(), userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch sessions"})
+	}
+
+	// Only the owner may revoke a session
+	found := false
+	for _, session := range sessions {
+		if session.ID == sessionID {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Session not found"})
+	}
+
+	if err := utils.RevokeSession(c.Request().Context(), sessionID); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to revoke session"})
+	}
+	audit(c, models.AuditEntry{
+		Action:     models.AuditSessionRevoked,
+		TargetType: models.AuditTargetSession,
+		TargetID:   sessionID.Hex(),
+	})
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Session revoked"})
+}
+
+// RevokeAllSessions logs the current user out everywhere. With ?keepCurrent=true
+// the session making the request stays signed in.
+func RevokeAllSessions(c echo.Context) error {
+	userID := c.Get("userID").(primitive.ObjectID)
+
+	except := primitive.NilObjectID
+	if c.QueryParam("keepCurrent") == "true" {
+		except = currentSessionID(c)
+	}
+
+	if err := utils.RevokeUserSessions(c.Request().Context(), userID, except); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to revoke sessions"})
+	}
+	auditUser(c, userID, models.AuditSessionsRevoked, map[string]string{"keepCurrent": strconv.FormatBool(!except.IsZero())})
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Signed out of all sessions"})
+}
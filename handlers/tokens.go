@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/utils"
+	"github.com/labstack/echo/v4"
+)
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// RefreshToken exchanges a refresh token for a new access and refresh token
+func RefreshToken(c echo.Context) error {
+	var req RefreshTokenRequest
+	if err := c.Bind(&req); err != nil || req.RefreshToken == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Refresh token is required"})
+	}
+
+	tokens, err := utils.RotateRefreshToken(c.Request().Context(), req.RefreshToken, sessionInfo(c, false))
+	if err != nil {
+		switch err {
+		case utils.ErrRefreshTokenReuse:
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Refresh token already used, please sign in again"})
+		case utils.ErrInvalidRefreshToken:
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid or expired refresh token"})
+		default:
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to refresh token"})
+		}
+	}
+
+	return c.JSON(http.StatusOK, tokens)
+}
+
+// Logout ends the session of the refresh token
+func Logout(c echo.Context) error {
+	var req RefreshTokenRequest
+	if err := c.Bind(&req); err != nil || req.RefreshToken == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Refresh token is required"})
+	}
+
+	token, err := utils.RevokeRefreshToken(c.Request().Context(), req.RefreshToken)
+	if err != nil && err != utils.ErrInvalidRefreshToken {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to revoke token"})
+	}
+	if err == nil {
+		audit(c, models.AuditEntry{
+			ActorType:  models.AuditActorUser,
+			ActorID:    token.UserID.Hex(),
+			Action:     models.AuditLogout,
+			TargetType: models.AuditTargetSession,
+			TargetID:   token.FamilyID.Hex(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Logged out successfully"})
+}
+
+// GetJWKS publishes the public keys access tokens can be verified with
+func GetJWKS(c echo.Context) error {
+	c.Response().Header().Set(echo.HeaderCacheControl, "public, max-age=300")
+	return c.JSON(http.StatusOK, map[string]interface{}{"keys": utils.JWKS()})
+}
@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/database"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/utils"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code"` // TOTP code or recovery code
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challengeToken"`
+	Code           string `json:"code"`
+}
+
+// twoFactorChallenge writes a challenge response instead of tokens and returns true
+// when the user has two-factor authentication enabled
+func twoFactorChallenge(c echo.Context, user models.User) (bool, error) {
+	if user.TwoFactor == nil || !user.TwoFactor.Enabled {
+		return false, nil
+	}
+
+	challenge, err := utils.IssueTwoFactorChallenge(user.ID.Hex())
+	if err != nil {
+		return true, c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate token"})
+	}
+	auditUser(c, user.ID, models.AuditTwoFactorChallenge, nil)
+
+	return true, c.JSON(http.StatusOK, map[string]interface{}{
+		"twoFactorRequired": true,
+		"challengeToken":    challenge,
+		"expiresIn":         int64(utils.TwoFactorChallengeTTL.Seconds()),
+	})
+}
+
+// VerifyTwoFactorLogin completes a login with the challenge token and a second factor
+func VerifyTwoFactorLogin(c echo.Context) error {
+	var req TwoFactorLoginRequest
+	if err := c.Bind(&req); err != nil || req.ChallengeToken == "" || req.Code == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Challenge token and code are required"})
+	}
+
+	userHex, err := utils.ValidateTwoFactorChallenge(req.ChallengeToken)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid or expired challenge, please sign in again"})
+	}
+
+	userID, err := primitive.ObjectIDFromHex(userHex)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid or expired challenge, please sign in again"})
+	}
+
+	ctx := c.Request().Context()
+
+	var user models.User
+	if err := database.DB.Collection("users").FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "User not found"})
+	}
+
+	// Codes are guessed against the same counters as passwords
+	if throttled, err := loginThrottled(ctx, c, user.Email); throttled {
+		return err
+	}
+
+	ok, err := utils.VerifyTwoFactorCode(ctx, user, req.Code)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to verify code"})
+	}
+	if !ok {
+		return loginFailed(ctx, c, user.Email, "Invalid two-factor code")
+	}
+	loginSucceeded(ctx, user.Email)
+
+	tokens, err := utils.IssueTokenPair(ctx, userID, sessionInfo(c, true))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate token"})
+	}
+	auditLogin(c, userID, "2fa")
+
+	return c.JSON(http.StatusOK, tokens)
+}
+
+// SetupTwoFactor generates a TOTP secret for the current user. It only takes effect
+// once EnableTwoFactor confirms a code from it.
+func SetupTwoFactor(c echo.Context) error {
+	userID := c.Get("userID").(primitive.ObjectID)
+	ctx := c.Request().Context()
+
+	var user models.User
+	if err := database.DB.Collection("users").FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
+	}
+
+	if user.TwoFactor != nil && user.TwoFactor.Enabled {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Two-factor authentication is already enabled"})
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate secret"})
+	}
+
+	_, err = database.DB.Collection("users").UpdateOne(
+		ctx,
+		bson.M{"_id": userID},
+		bson.M{"$set": bson.M{"twoFactor.pendingSecret": secret, "updatedAt": time.Now()}},
+	)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start two-factor setup"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"secret":          secret,
+		"provisioningUri": utils.TOTPProvisioningURI(secret, user.Email),
+	})
+}
+
+// EnableTwoFactor confirms the pending secret with a code and returns the recovery
+// codes, which are only ever shown here
+func EnableTwoFactor(c echo.Context) error {
+	userID := c.Get("userID").(primitive.ObjectID)
+
+	var req TwoFactorCodeRequest
+	if err := c.Bind(&req); err != nil || req.Code == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Code is required"})
+	}
+
+	ctx := c.Request().Context()
+
+	var user models.User
+	if err := database.DB.Collection("users").FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
+	}
+
+	if user.TwoFactor == nil || user.TwoFactor.PendingSecret == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Start two-factor setup first"})
+	}
+	if user.TwoFactor.Enabled {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Two-factor authentication is already enabled"})
+	}
+
+	step, ok := utils.ValidateTOTP(user.TwoFactor.PendingSecret, req.Code, time.Now())
+	if !ok {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid code"})
+	}
+
+	codes, hashes, err := utils.GenerateRecoveryCodes()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate recovery codes"})
+	}
+
+	now := time.Now()
+	_, err = database.DB.Collection("users").UpdateOne(
+		ctx,
+		bson.M{"_id": userID},
+		bson.M{"$set": bson.M{
+			"twoFactor": models.TwoFactor{
+				Enabled:       true,
+				Secret:        user.TwoFactor.PendingSecret,
+				RecoveryCodes: hashes,
+				LastUsedStep:  step,
+				EnabledAt:     &now,
+			},
+			"updatedAt": now,
+		}},
+	)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to enable two-factor authentication"})
+	}
+	auditUser(c, userID, models.AuditTwoFactorEnabled, nil)
+
+	// The code just proved the second factor, so the caller moves to a session that
+	// may use 2FA-only roles and the old one is ended
+	tokens, err := utils.IssueTokenPair(ctx, userID, sessionInfo(c, true))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate token"})
+	}
+	if err := utils.RevokeSession(ctx, currentSessionID(c)); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to end previous session"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"recoveryCodes": codes,
+		"tokens":        tokens,
+	})
+}
+
+// DisableTwoFactor turns two-factor authentication off after checking a code
+func DisableTwoFactor(c echo.Context) error {
+	userID := c.Get("userID").(primitive.ObjectID)
+
+	user, status, message := verifyCurrentTwoFactor(c.Request().Context(), c, userID)
+	if status != 0 {
+		return c.JSON(status, map[string]string{"error": message})
+	}
+
+	_, err := database.DB.Collection("users").UpdateOne(
+		c.Request().Context(),
+		bson.M{"_id": user.ID},
+		bson.M{
+			"$unset": bson.M{"twoFactor": ""},
+			"$set":   bson.M{"updatedAt": time.Now()},
+		},
+	)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to disable two-factor authentication"})
+	}
+	auditUser(c, user.ID, models.AuditTwoFactorDisabled, nil)
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes replaces every recovery code after checking a code
+func RegenerateRecoveryCodes(c echo.Context) error {
+	userID := c.Get("userID").(primitive.ObjectID)
+
+	user, status, message := verifyCurrentTwoFactor(c.Request().Context(), c, userID)
+	if status != 0 {
+		return c.JSON(status, map[string]string{"error": message})
+	}
+
+	codes, hashes, err := utils.GenerateRecoveryCodes()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate recovery codes"})
+	}
+
+	_, err = database.DB.Collection("users").UpdateOne(
+		c.Request().Context(),
+		bson.M{"_id": user.ID},
+		bson.M{"$set": bson.M{"twoFactor.recoveryCodes": hashes, "updatedAt": time.Now()}},
+	)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to save recovery codes"})
+	}
+	auditUser(c, user.ID, models.AuditRecoveryCodesReset, nil)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"recoveryCodes": codes})
+}
+
+// verifyCurrentTwoFactor loads the user and checks the code in the request body
+func verifyCurrentTwoFactor(ctx context.Context, c echo.Context, userID primitive.ObjectID) (models.User, int, string) {
+	var req TwoFactorCodeRequest
+	if err := c.Bind(&req); err != nil || req.Code == "" {
+		return models.User{}, http.StatusBadRequest, "Code is required"
+	}
+
+	var user models.User
+	if err := database.DB.Collection("users").FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		return models.User{}, http.StatusNotFound, "User not found"
+	}
+
+	if user.TwoFactor == nil || !user.TwoFactor.Enabled {
+		return models.User{}, http.StatusBadRequest, "Two-factor authentication is not enabled"
+	}
+
+	// Without this a stolen access token could guess the code and turn 2FA off
+	wait, err := utils.CheckTwoFactorAttempts(ctx, userID.Hex())
+	if err != nil {
+		return models.User{}, http.StatusInternalServerError, "Failed to check two-factor attempts"
+	}
+	if wait > 0 {
+		c.Response().Header().Set("Retry-After", retryAfterSeconds(wait))
+		return models.User{}, http.StatusTooManyRequests, "Too many invalid two-factor codes, try again later"
+	}
+
+	ok, err := utils.VerifyTwoFactorCode(ctx, user, req.Code)
+	if err != nil {
+		return models.User{}, http.StatusInternalServerError, "Failed to verify code"
+	}
+	if !ok {
+		if err := utils.RecordTwoFactorFailure(ctx, c.RealIP(), userID.Hex()); err != nil {
+			log.Printf("❌ Failed to record two-factor failure: %v", err)
+		}
+		return models.User{}, http.StatusUnauthorized, "Invalid two-factor code"
+	}
+	if err := utils.RecordTwoFactorSuccess(ctx, userID.Hex()); err != nil {
+		log.Printf("❌ Failed to reset two-factor attempts: %v", err)
+	}
+	return user, 0, ""
+}
@@ -8,8 +8,8 @@ import (
 	"net/mail"
 
 	"github.com/Madhav-Gupta-28/0xmart-backend-go/database"
-	"github.com/Madhav-Gupta-28/0xmart-backend-go/middleware"
 	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/utils"
 	"github.com/labstack/echo/v4"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -84,23 +84,33 @@ func LoginUser(c echo.Context) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	if throttled, err := loginThrottled(ctx, c, loginRequest.Email); throttled {
+		return err
+	}
+
 	var user models.User
 	err := collection.FindOne(ctx, bson.M{"email": loginRequest.Email}).Decode(&user)
 	if err != nil {
-		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid email or password"})
+		return loginFailed(ctx, c, loginRequest.Email, "Invalid email or password")
 	}
 
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(loginRequest.Password))
 	if err != nil {
-		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid email or password"})
+		return loginFailed(ctx, c, loginRequest.Email, "Invalid email or password")
+	}
+	loginSucceeded(ctx, loginRequest.Email)
+
+	if challenged, err := twoFactorChallenge(c, user); challenged {
+		return err
 	}
 
-	token, err := middleware.GenerateToken(user.ID)
+	tokens, err := utils.IssueTokenPair(ctx, user.ID, sessionInfo(c, false))
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate token"})
 	}
+	auditLogin(c, user.ID, "password")
 
-	return c.JSON(http.StatusOK, map[string]string{"token": token})
+	return c.JSON(http.StatusOK, tokens)
 }
 
 // GetUserProfile retrieves the user's profile
@@ -117,6 +127,7 @@ func GetUserProfile(c echo.Context) error {
 		return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
 	}
 
+	attachWalletENSNames(c.Request().Context(), user.Wallets)
 	return c.JSON(http.StatusOK, user)
 }
 
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/database"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/utils"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	walletLinkMessage = "Sign this message to link your wallet to 0xmart.\n\nNonce: "
+	walletNonceTTL    = 10 * time.Minute
+)
+
+// GetWalletNonce issues a nonce for the user to sign with the wallet being linked
+func GetWalletNonce(c echo.Context) error {
+	userID := c.Get("userID").(primitive.ObjectID)
+
+	nonceBytes := make([]byte, 16)
+	if _, err := rand.Read(nonceBytes); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate nonce"})
+	}
+	nonce := hex.EncodeToString(nonceBytes)
+
+	_, err := database.DB.Collection("users").UpdateOne(
+		c.Request().Context(),
+		bson.M{"_id": userID},
+		bson.M{"$set": bson.M{"walletNonce": nonce, "walletNonceAt": time.Now()}},
+	)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to store nonce"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": walletLinkMessage + nonce})
+}
+
+// LinkWallet verifies a personal_sign signature over the nonce message and links the wallet
+func LinkWallet(c echo.Context) error {
+	userID := c.Get("userID").(primitive.ObjectID)
+
+	var req struct {
+		Address   string `json:"address"`
+		Signature string `json:"signature"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	address, err := utils.ResolveWalletInput(c.Request().Context(), req.Address)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid wallet address or unresolvable ENS name"})
+	}
+
+	var user models.User
+	err = database.DB.Collection("users").FindOne(c.Request().Context(), bson.M{"_id": userID}).Decode(&user)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
+	}
+
+	if user.WalletNonce == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Request a nonce first"})
+	}
+	if user.WalletNonceAt == nil || time.Since(*user.WalletNonceAt) > walletNonceTTL {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Nonce expired, request a new one"})
+	}
+
+	signature, err := hexutil.Decode(req.Signature)
+	if err != nil || len(signature) != crypto.SignatureLength {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid signature format"})
+	}
+
+	// Wallets produce v as 27/28, crypto expects 0/1
+	if signature[crypto.RecoveryIDOffset] >= 27 {
+		signature[crypto.RecoveryIDOffset] -= 27
+	}
+
+	hash := accounts.TextHash([]byte(walletLinkMessage + user.WalletNonce))
+	publicKey, err := crypto.SigToPub(hash, signature)
+	if err != nil || crypto.PubkeyToAddress(*publicKey) != common.HexToAddress(address) {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Signature does not match wallet"})
+	}
+
+	// The unique index on wallets.address backs this check up against concurrent links
+	owners, err := database.DB.Collection("users").CountDocuments(
+		c.Request().Context(),
+		bson.M{"_id": bson.M{"$ne": userID}, "wallets.address": address},
+	)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to link wallet"})
+	}
+	if owners > 0 {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Wallet is linked to another account"})
+	}
+
+	wallet := models.Wallet{Address: address, VerifiedAt: time.Now()}
+
+	// Drop any previous link of the same address before re-adding it
+	_, err = database.DB.Collection("users").UpdateOne(
+		c.Request().Context(),
+		bson.M{"_id": userID},
+		bson.M{"$pull": bson.M{"wallets": bson.M{"address": address}}},
+	)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to link wallet"})
+	}
+
+	_, err = database.DB.Collection("users").UpdateOne(
+		c.Request().Context(),
+		bson.M{"_id": userID},
+		bson.M{
+			"$push":  bson.M{"wallets": wallet},
+			"$unset": bson.M{"walletNonce": "", "walletNonceAt": ""},
+			"$set":   bson.M{"updatedAt": time.Now()},
+		},
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Wallet is linked to another account"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to link wallet"})
+	}
+
+	linked := []models.Wallet{wallet}
+	attachWalletENSNames(c.Request().Context(), linked)
+	return c.JSON(http.StatusOK, linked[0])
+}
+
+// verifiedWallets returns the addresses the user has proved control of
+func verifiedWallets(ctx context.Context, userID primitive.ObjectID) ([]string, error) {
+	var user models.User
+	err := database.DB.Collection("users").FindOne(ctx, bson.M{"_id": userID}).Decode(&user)
+	if err != nil {
+		return nil, err
+	}
+
+	wallets := make([]string, 0, len(user.Wallets))
+	for _, wallet := range user.Wallets {
+		wallets = append(wallets, wallet.Address)
+	}
+	return wallets, nil
+}
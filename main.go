@@ -1,12 +1,15 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/Madhav-Gupta-28/0xmart-backend-go/config"
 	"github.com/Madhav-Gupta-28/0xmart-backend-go/database"
 	"github.com/Madhav-Gupta-28/0xmart-backend-go/routes"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/utils"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 )
@@ -15,20 +18,101 @@ func main() {
 	// Load environment variables
 	config.LoadEnv()
 
+	// Refuse to start without a key to sign tokens with
+	if err := utils.InitKeyStore(); err != nil {
+		log.Fatal("Failed to load JWT signing keys:", err)
+	}
+	go utils.StartKeyRotation(time.Hour)
+
 	// Initialize Echo
 	e := echo.New()
 	fmt.Println("Echo initialized") // Debug log
 
+	// Client IPs feed login throttling, so X-Forwarded-For is only trusted behind a proxy
+	if config.GetEnv("TRUST_PROXY", "false") == "true" {
+		e.IPExtractor = echo.ExtractIPFromXFFHeader()
+	} else {
+		e.IPExtractor = echo.ExtractIPDirect()
+	}
+
 	// Middleware
+	e.Use(middleware.RequestID())
 	e.Use(middleware.Logger())
 	e.Use(middleware.Recover())
-	e.Use(middleware.CORS())
+	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
+		// Let browsers read the listing and versioning headers
+		ExposeHeaders: []string{"X-Total-Count", "X-Next-Cursor", "Link", "ETag"},
+	}))
 
 	// Connect to MongoDB
 	if err := database.ConnectDB(); err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
 
+	// The audit log's sequence numbers must stay unique across replicas
+	if err := utils.EnsureAuditIndexes(context.Background()); err != nil {
+		log.Fatal("Failed to create audit log indexes:", err)
+	}
+	if err := utils.EnsurePaymentIndexes(context.Background()); err != nil {
+		log.Fatal("Failed to create payment indexes:", err)
+	}
+	if err := utils.EnsureSessionIndexes(context.Background()); err != nil {
+		log.Fatal("Failed to create session indexes:", err)
+	}
+	if err := utils.EnsureLoginAttemptIndexes(context.Background()); err != nil {
+		log.Fatal("Failed to create login attempt indexes:", err)
+	}
+	if err := utils.EnsureAPIKeyIndexes(context.Background()); err != nil {
+		log.Fatal("Failed to create API key indexes:", err)
+	}
+	if err := utils.EnsureProductIndexes(context.Background()); err != nil {
+		log.Fatal("Failed to create product indexes:", err)
+	}
+	if err := utils.BackfillRatingCounts(context.Background()); err != nil {
+		log.Fatal("Failed to backfill product rating counts:", err)
+	}
+	if err := utils.EnsureCategoryIndexes(context.Background()); err != nil {
+		log.Fatal("Failed to create category indexes:", err)
+	}
+	if err := utils.EnsureWalletIndexes(context.Background()); err != nil {
+		log.Fatal("Failed to create wallet indexes:", err)
+	}
+
+	// Receipt mints are queued for paid orders, but only a payment key can send them
+	if utils.ReceiptsEnabled() && config.GetEnv("PAYMENT_PRIVATE_KEY", "") == "" {
+		log.Fatal("RECEIPT_NFT_CONTRACT is set but PAYMENT_PRIVATE_KEY is not, so receipts would never be minted")
+	}
+
+	// Send queued overpayment refunds and mint receipts when a payment key is configured
+	if privateKey := config.GetEnv("PAYMENT_PRIVATE_KEY", ""); privateKey != "" {
+		processor, err := utils.NewPaymentProcessor(config.GetEnv("WEB3_RPC_URL", ""), privateKey)
+		if err != nil {
+			log.Fatal("Failed to initialize payment processor:", err)
+		}
+		go utils.StartRefundWorker(processor, time.Minute)
+
+		if utils.ReceiptsEnabled() {
+			go utils.RunJobWorker(map[string]utils.JobHandler{
+				utils.JobTypeMintReceipt: utils.MintReceiptJob(processor),
+			}, 30*time.Second)
+		}
+	}
+
+	// Watch per-order deposit addresses and sweep them to the treasury
+	if utils.DepositAddressMode() {
+		if err := utils.NewDepositWatcher().Start(); err != nil {
+			log.Fatal("Failed to start deposit watcher:", err)
+		}
+		if config.GetEnv("DEPOSIT_XPRV", "") != "" {
+			if err := utils.StartSweeper(10 * time.Minute); err != nil {
+				log.Fatal("Failed to start deposit sweeper:", err)
+			}
+		}
+	}
+
+	// Erase accounts whose deletion grace period has passed
+	go utils.StartAccountDeletionWorker(time.Hour)
+
 	// Setup routes
 	routes.SetupRoutes(e)
 
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/utils"
+	"github.com/labstack/echo/v4"
+)
+
+// APIKeyOrNextAuthMiddleware accepts an API key, sent as X-API-Key or as a Bearer token,
+// and passes every other request to NextAuthMiddleware. Requests made with a key carry
+// its scopes instead of a user, so it only belongs on routes guarded by RequirePermission
+// whose handlers don't need the user ID.
+func APIKeyOrNextAuthMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		withUser := NextAuthMiddleware()(next)
+
+		return func(c echo.Context) error {
+			raw := apiKeyFromRequest(c)
+			if raw == "" {
+				return withUser(c)
+			}
+
+			key, err := utils.AuthenticateAPIKey(c.Request().Context(), raw, c.RealIP())
+			if err == utils.ErrInvalidAPIKey {
+				return c.JSON(http.StatusUnauthorized, map[string]string{
+					"error": "Invalid API key",
+				})
+			}
+			if err != nil {
+				return c.JSON(http.StatusInternalServerError, map[string]string{
+					"error": "Failed to check API key",
+				})
+			}
+
+			if wait := utils.AllowAPIKeyRequest(key); wait > 0 {
+				c.Response().Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				return c.JSON(http.StatusTooManyRequests, map[string]string{
+					"error": "API key rate limit exceeded",
+				})
+			}
+
+			// Add the key and its scopes to the context
+			c.Set("apiKeyID", key.ID)
+			c.Set("scopes", key.Scopes)
+			return next(c)
+		}
+	}
+}
+
+func apiKeyFromRequest(c echo.Context) string {
+	if key := c.Request().Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+
+	parts := strings.Split(c.Request().Header.Get("Authorization"), " ")
+	if len(parts) == 2 && parts[0] == "Bearer" && utils.IsAPIKey(parts[1]) {
+		return parts[1]
+	}
+	return ""
+}
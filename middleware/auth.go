@@ -1,64 +0,0 @@
-package middleware
-
-import (
-	"fmt"
-	"net/http"
-	"os"
-	"strings"
-	"time"
-
-	"github.com/golang-jwt/jwt"
-	"github.com/labstack/echo/v4"
-	"go.mongodb.org/mongo-driver/bson/primitive"
-)
-
-type JWTCustomClaims struct {
-	UserID primitive.ObjectID `json:"userId"`
-	jwt.StandardClaims
-}
-
-func AuthMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
-	return func(c echo.Context) error {
-		authHeader := c.Request().Header.Get("Authorization")
-		if authHeader == "" {
-			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Missing authorization header"})
-		}
-
-		tokenParts := strings.Split(authHeader, " ")
-		if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
-			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid authorization header format"})
-		}
-
-		tokenString := tokenParts[1]
-		claims := &JWTCustomClaims{}
-
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return []byte(os.Getenv("JWT_SECRET")), nil
-		})
-
-		if err != nil || !token.Valid {
-			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid or expired token"})
-		}
-
-		// Add user ID to context
-		c.Set("userID", claims.UserID)
-		return next(c)
-	}
-}
-
-func GenerateToken(userID primitive.ObjectID) (string, error) {
-	claims := &JWTCustomClaims{
-		userID,
-		jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(time.Hour * 72).Unix(),
-		},
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(os.Getenv("JWT_SECRET")))
-}
-
-// Remove the JWT function and related imports
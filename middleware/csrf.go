@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/utils"
+	"github.com/labstack/echo/v4"
+)
+
+// CSRFMiddleware requires the token from /api/auth/csrf on state-changing requests that
+// authenticate with the access token cookie. Requests with an Authorization or X-API-Key
+// header are exempt: browsers never attach those to cross-site requests on their own.
+// The token must have been issued for the session in the cookie; an invalid cookie is
+// left for the auth middleware to reject.
+func CSRFMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			switch c.Request().Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				return next(c)
+			}
+
+			req := c.Request()
+			if req.Header.Get("Authorization") != "" || req.Header.Get("X-API-Key") != "" {
+				return next(c)
+			}
+			authCookie, err := req.Cookie(utils.AuthCookieName())
+			if err != nil {
+				return next(c)
+			}
+			claims, err := utils.ValidateJWT(authCookie.Value)
+			if err != nil {
+				return next(c)
+			}
+
+			token := req.Header.Get(utils.CSRFHeaderName)
+			if token == "" {
+				token = c.FormValue("csrfToken")
+			}
+
+			cookie, err := req.Cookie(utils.CSRFCookieName)
+			if err != nil || utils.ValidateCSRFToken(cookie.Value, token, claims.SessionID) != nil {
+				return c.JSON(http.StatusForbidden, map[string]string{
+					"error": "Invalid or missing CSRF token",
+				})
+			}
+			return next(c)
+		}
+	}
+}
@@ -9,27 +9,34 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// NextAuthMiddleware authenticates the access token from the Authorization header or,
+// for browsers, from the AUTH_COOKIE_NAME cookie. Cookie-authenticated routes must also
+// run CSRFMiddleware.
 func NextAuthMiddleware() echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			// Get the token from the Authorization header
+			var tokenString string
 			authHeader := c.Request().Header.Get("Authorization")
-			if authHeader == "" {
+			if authHeader != "" {
+				// Extract the token from the "Bearer" scheme
+				parts := strings.Split(authHeader, " ")
+				if len(parts) != 2 || parts[0] != "Bearer" {
+					return c.JSON(http.StatusUnauthorized, map[string]string{
+						"error": "Invalid authorization header format",
+					})
+				}
+				tokenString = parts[1]
+			} else if cookie, err := c.Cookie(utils.AuthCookieName()); err == nil && cookie.Value != "" {
+				tokenString = cookie.Value
+			} else {
 				return c.JSON(http.StatusUnauthorized, map[string]string{
 					"error": "No authorization header",
 				})
 			}
 
-			// Extract the token from the "Bearer" scheme
-			parts := strings.Split(authHeader, " ")
-			if len(parts) != 2 || parts[0] != "Bearer" {
-				return c.JSON(http.StatusUnauthorized, map[string]string{
-					"error": "Invalid authorization header format",
-				})
-			}
-
 			// Verify the JWT token
-			claims, err := utils.ValidateJWT(parts[1])
+			claims, err := utils.ValidateJWT(tokenString)
 			if err != nil {
 				return c.JSON(http.StatusUnauthorized, map[string]string{
 					"error": "Invalid token",
@@ -44,8 +51,25 @@ func NextAuthMiddleware() echo.MiddlewareFunc {
 				})
 			}
 
-			// Add the user ID to the context
+			// Reject tokens whose session was revoked before they expired
+			active, err := utils.SessionActive(c.Request().Context(), claims.SessionID)
+			if err != nil {
+				return c.JSON(http.StatusInternalServerError, map[string]string{
+					"error": "Failed to check session",
+				})
+			}
+			if !active {
+				return c.JSON(http.StatusUnauthorized, map[string]string{
+					"error": "Session has been revoked",
+				})
+			}
+			sessionID, _ := primitive.ObjectIDFromHex(claims.SessionID)
+
+			// Add the user ID, session, roles and two-factor status to the context
 			c.Set("userID", userID)
+			c.Set("sessionID", sessionID)
+			c.Set("roles", claims.Roles)
+			c.Set("mfa", claims.MFA)
 			return next(c)
 		}
 	}
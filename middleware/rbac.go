@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/utils"
+	"github.com/labstack/echo/v4"
+)
+
+// RequirePermission allows the request only if the caller's roles, or its API key's
+// scopes, grant the permission. Roles listed in REQUIRE_2FA_ROLES only count for sessions
+// that passed two-factor authentication. It must run after NextAuthMiddleware or
+// APIKeyOrNextAuthMiddleware, which put the roles or scopes in the context.
+func RequirePermission(permission string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if scopes, ok := c.Get("scopes").([]string); ok {
+				for _, scope := range scopes {
+					if scope == permission {
+						return next(c)
+					}
+				}
+				return c.JSON(http.StatusForbidden, map[string]string{
+					"error": "API key is missing scope " + permission,
+				})
+			}
+
+			roles, _ := c.Get("roles").([]string)
+			mfa, _ := c.Get("mfa").(bool)
+
+			if !models.HasPermission(utils.EffectiveRoles(roles, mfa), permission) {
+				// Tell users who only lack the second factor what to do about it
+				if models.HasPermission(roles, permission) {
+					return c.JSON(http.StatusForbidden, map[string]string{
+						"error": "Two-factor authentication is required for " + permission,
+					})
+				}
+				return c.JSON(http.StatusForbidden, map[string]string{
+					"error": "Missing permission " + permission,
+				})
+			}
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// APIKey authenticates a server-to-server integration. Only the hash of the key is
+// stored; Prefix is the non-secret start of the key, shown to tell keys apart.
+type APIKey struct {
+	ID         primitive.ObjectID `bson:"_id" json:"id"`
+	Name       string             `bson:"name" json:"name"`
+	Prefix     string             `bson:"prefix" json:"prefix"`
+	KeyHash    string             `bson:"keyHash" json:"-"`
+	Scopes     []string           `bson:"scopes" json:"scopes"`
+	RateLimit  int                `bson:"rateLimit" json:"rateLimit"` // Requests per minute
+	CreatedBy  primitive.ObjectID `bson:"createdBy" json:"createdBy"`
+	CreatedAt  time.Time          `bson:"createdAt" json:"createdAt"`
+	ExpiresAt  *time.Time         `bson:"expiresAt,omitempty" json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time         `bson:"lastUsedAt,omitempty" json:"lastUsedAt,omitempty"`
+	LastUsedIP string             `bson:"lastUsedIp,omitempty" json:"lastUsedIp,omitempty"`
+	RevokedAt  *time.Time         `bson:"revokedAt,omitempty" json:"revokedAt,omitempty"`
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	AuditActorUser      = "user"
+	AuditActorAnonymous = "anonymous"
+	AuditActorSystem    = "system"
+	AuditActorAPIKey    = "api_key"
+)
+
+// Audit target types
+const (
+	AuditTargetUser     = "user"
+	AuditTargetAccount  = "account" // A login name that may not belong to any user
+	AuditTargetIP       = "ip"
+	AuditTargetSession  = "session"
+	AuditTargetProduct  = "product"
+	AuditTargetOrder    = "order"
+	AuditTargetListener = "listener"
+	AuditTargetAPIKey   = "api_key"
+	AuditTargetCategory = "category"
+)
+
+// Audited actions
+const (
+	AuditSignUp             = "auth.signup"
+	AuditLogin              = "auth.login"
+	AuditLoginFailed        = "auth.login_failed"
+	AuditLoginLocked        = "auth.lockout"
+	AuditTwoFactorChallenge = "auth.2fa_challenge"
+	AuditLogout             = "auth.logout"
+	AuditRefreshTokenReuse  = "auth.refresh_reuse"
+	AuditEmailVerified      = "user.email_verified"
+	AuditPasswordResetSent  = "user.password_reset_requested"
+	AuditPasswordReset      = "user.password_reset"
+	AuditPasswordChanged    = "user.password_changed"
+	AuditTwoFactorEnabled   = "user.2fa_enabled"
+	AuditTwoFactorDisabled  = "user.2fa_disabled"
+	AuditRecoveryCodesReset = "user.recovery_codes_regenerated"
+	AuditIdentityLinked     = "user.identity_linked"
+	AuditIdentityUnlinked   = "user.identity_unlinked"
+	AuditSessionRevoked     = "session.revoked"
+	AuditSessionsRevoked    = "session.revoked_all"
+	AuditDeletionRequested  = "account.deletion_requested"
+	AuditDeletionCancelled  = "account.deletion_cancelled"
+	AuditAccountDeleted     = "account.deleted"
+	AuditRoleGranted        = "admin.role_granted"
+	AuditRoleRevoked        = "admin.role_revoked"
+	AuditProductCreated     = "admin.product_created"
+	AuditProductUpdated     = "admin.product_updated"
+	AuditProductArchived    = "admin.product_archived"
+	AuditProductRestored    = "admin.product_restored"
+	AuditProductDeleted     = "admin.product_deleted"
+	AuditCategoryCreated    = "admin.category_created"
+	AuditCategoryUpdated    = "admin.category_updated"
+	AuditCategoryDeleted    = "admin.category_deleted"
+	AuditFulfillmentUpdated = "admin.order_fulfillment_updated"
+	AuditListenerStarted    = "admin.listener_started"
+	AuditListenerRestarted  = "admin.listener_restarted"
+	AuditAPIKeyCreated      = "admin.api_key_created"
+	AuditAPIKeyRevoked      = "admin.api_key_revoked"
+)
+
+// AuditChange is one changed field; values are JSON encoded so they hash the same
+// after a round trip through the database
+type AuditChange struct {
+	Before string `bson:"before,omitempty" json:"before,omitempty"`
+	After  string `bson:"after,omitempty" json:"after,omitempty"`
+}
+
+// AuditEntry is an append-only record of a security or admin action. Each entry
+// stores the hash of the previous one, so edits and deletions break the chain.
+type AuditEntry struct {
+	ID         primitive.ObjectID     `bson:"_id" json:"id"`
+	Seq        uint64                 `bson:"seq" json:"seq"`
+	Timestamp  time.Time              `bson:"timestamp" json:"timestamp"`
+	ActorType  string                 `bson:"actorType" json:"actorType"`
+	ActorID    string                 `bson:"actorId,omitempty" json:"actorId,omitempty"`
+	Action     string                 `bson:"action" json:"action"`
+	TargetType string                 `bson:"targetType,omitempty" json:"targetType,omitempty"`
+	TargetID   string                 `bson:"targetId,omitempty" json:"targetId,omitempty"`
+	Changes    map[string]AuditChange `bson:"changes,omitempty" json:"changes,omitempty"`
+	IP         string                 `bson:"ip,omitempty" json:"ip,omitempty"`
+	UserAgent  string                 `bson:"userAgent,omitempty" json:"userAgent,omitempty"`
+	RequestID  string                 `bson:"requestId,omitempty" json:"requestId,omitempty"`
+	Metadata   map[string]string      `bson:"metadata,omitempty" json:"metadata,omitempty"`
+	PrevHash   string                 `bson:"prevHash" json:"prevHash"`
+	Hash       string                 `bson:"hash" json:"hash"`
+}
@@ -8,7 +8,7 @@ import (
 
 type CartItem struct {
 	ProductID primitive.ObjectID `bson:"productId" json:"productId"`
-	Size      string             `bson:"size" json:"size"`
+	SKU       string             `bson:"sku" json:"sku"`
 	Quantity  int                `bson:"quantity" json:"quantity"`
 }
 
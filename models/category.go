@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ProductCategory struct {
+	ID        primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	Name      string              `bson:"name" json:"name"`
+	Slug      string              `bson:"slug" json:"slug"`                             // Unique, used in URLs and search filters
+	ParentID  *primitive.ObjectID `bson:"parentId,omitempty" json:"parentId,omitempty"` // Nil for top-level categories
+	CreatedAt time.Time           `bson:"createdAt" json:"createdAt"`
+	UpdatedAt time.Time           `bson:"updatedAt" json:"updatedAt"`
+}
+
+// CategoryNode is a category in the category tree
+type CategoryNode struct {
+	ProductCategory
+	ProductCount      int64           `json:"productCount"`      // Products listed directly in the category
+	TotalProductCount int64           `json:"totalProductCount"` // Including subcategories, each product counted once
+	Children          []*CategoryNode `json:"children"`
+}
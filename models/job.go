@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type JobStatus string
+
+const (
+	JobStatusPending JobStatus = "PENDING"
+	JobStatusRunning JobStatus = "RUNNING"
+	JobStatusDone    JobStatus = "DONE"
+	JobStatusFailed  JobStatus = "FAILED"
+)
+
+// Job is a unit of background work persisted so it survives restarts
+type Job struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Type        string             `bson:"type" json:"type"`
+	Key         string             `bson:"key,omitempty" json:"key,omitempty"` // Set on jobs that may only be queued once
+	Payload     map[string]string  `bson:"payload" json:"payload"`
+	Status      JobStatus          `bson:"status" json:"status"`
+	Attempts    int                `bson:"attempts" json:"attempts"`
+	MaxAttempts int                `bson:"maxAttempts" json:"maxAttempts"`
+	RunAt       time.Time          `bson:"runAt" json:"runAt"`
+	LockedUntil time.Time          `bson:"lockedUntil,omitempty" json:"lockedUntil,omitempty"`
+	LastError   string             `bson:"lastError,omitempty" json:"lastError,omitempty"`
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt   time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LoginLockout records an IP or account being locked out after repeated failed logins,
+// or a user's two-factor code checks being locked after repeated wrong codes
+type LoginLockout struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Scope       string             `bson:"scope" json:"scope"` // "ip", "account" or "user"
+	IP          string             `bson:"ip" json:"ip"`
+	Email       string             `bson:"email,omitempty" json:"email,omitempty"`
+	UserID      string             `bson:"userId,omitempty" json:"userId,omitempty"`
+	Failures    int                `bson:"failures" json:"failures"`
+	LockedUntil time.Time          `bson:"lockedUntil" json:"lockedUntil"`
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+}
@@ -9,9 +9,10 @@ import (
 type OrderStatus string
 
 const (
-	OrderStatusPending OrderStatus = "PENDING"
-	OrderStatusPaid    OrderStatus = "PAID"
-	OrderStatusFailed  OrderStatus = "FAILED"
+	OrderStatusPending       OrderStatus = "PENDING"
+	OrderStatusPartiallyPaid OrderStatus = "PARTIALLY_PAID"
+	OrderStatusPaid          OrderStatus = "PAID"
+	OrderStatusFailed        OrderStatus = "FAILED"
 )
 
 type FulfillmentStatus string
@@ -25,23 +26,78 @@ const (
 
 type OrderItem struct {
 	ProductID primitive.ObjectID `bson:"productId" json:"productId"`
-	Size      ProductSize        `bson:"size" json:"size"`
+	SKU       string             `bson:"sku,omitempty" json:"sku,omitempty"` // Missing on migrated orders whose variant couldn't be determined
+	Options   map[string]string  `bson:"options,omitempty" json:"options,omitempty"`
 	Quantity  int                `bson:"quantity" json:"quantity"`
 	Price     string             `bson:"price" json:"price"`
 }
 
 type Order struct {
 	ID                primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	OrderNumber       uint64             `bson:"orderNumber" json:"orderNumber"` // Order ID used by the payment contract
 	UserID            primitive.ObjectID `bson:"userId" json:"userId"`
 	Items             []OrderItem        `bson:"items" json:"items"`
 	TotalPrice        string             `bson:"totalPrice" json:"totalPrice"`
 	Status            OrderStatus        `bson:"status" json:"status"`
+	AmountPaid        string             `bson:"amountPaid,omitempty" json:"amountPaid,omitempty"`
+	BalanceDue        string             `bson:"balanceDue,omitempty" json:"balanceDue,omitempty"`
+	AmountRefunded    string             `bson:"amountRefunded,omitempty" json:"amountRefunded,omitempty"`
+	AppliedPayments   []string           `bson:"appliedPayments,omitempty" json:"-"` // Payment keys already counted in AmountPaid
+	OwedRefunds       []OwedRefund       `bson:"owedRefunds,omitempty" json:"-"`     // Refunds decided when applying payments
 	WalletAddress     string             `bson:"walletAddress" json:"walletAddress"`
+	ENSName           string             `bson:"-" json:"ensName,omitempty"`                               // Primary ENS name of WalletAddress
+	DepositAddress    string             `bson:"depositAddress,omitempty" json:"depositAddress,omitempty"` // Set in deposit address payment mode
+	DepositIndex      uint32             `bson:"depositIndex,omitempty" json:"-"`
+	SweepTxHash       string             `bson:"sweepTxHash,omitempty" json:"-"` // Latest sweep of the deposit address
+	Quote             *PaymentQuote      `bson:"quote,omitempty" json:"quote,omitempty"`
+	IssuedQuotes      []PaymentQuote     `bson:"issuedQuotes,omitempty" json:"-"` // Every quote a payment may still arrive for
 	TxHash            string             `bson:"txHash,omitempty" json:"txHash,omitempty"`
+	ReceiptTokenID    string             `bson:"receiptTokenId,omitempty" json:"receiptTokenId,omitempty"` // Receipt NFT minted on payment
+	ReceiptTxHash     string             `bson:"receiptTxHash,omitempty" json:"receiptTxHash,omitempty"`
+	ReceiptMint       *ReceiptMint       `bson:"receiptMint,omitempty" json:"-"` // Pending mint of the receipt NFT
 	CreatedAt         time.Time          `bson:"createdAt" json:"createdAt"`
 	UpdatedAt         time.Time          `bson:"updatedAt" json:"updatedAt"`
 	FulfillmentStatus FulfillmentStatus  `bson:"fulfillmentStatus" json:"fulfillmentStatus"`
 	ShippingAddress   *Address           `bson:"shippingAddress" json:"shippingAddress"`
 	TrackingNumber    string             `bson:"trackingNumber,omitempty" json:"trackingNumber,omitempty"`
 	EstimatedDelivery *time.Time         `bson:"estimatedDelivery,omitempty" json:"estimatedDelivery,omitempty"`
+	AnonymizedAt      *time.Time         `bson:"anonymizedAt,omitempty" json:"-"` // Set when the customer's account was deleted
+}
+
+// ReceiptMint tracks the transactions sent to mint an order's receipt NFT. A mint that
+// is not mined in time is replaced by one with the same nonce and a higher gas price.
+type ReceiptMint struct {
+	Nonce    uint64    `bson:"nonce"`
+	GasPrice string    `bson:"gasPrice"` // Of the latest transaction, in wei
+	TxHashes []string  `bson:"txHashes"` // Every transaction sent with the nonce, latest last
+	SentAt   time.Time `bson:"sentAt"`
+}
+
+// OwedRefund is a refund decided while applying a payment. It is saved with the payment
+// so that it can be queued again if queueing it failed.
+type OwedRefund struct {
+	PaymentKey string `bson:"paymentKey"`
+	ToAddress  string `bson:"toAddress"`
+	Amount     string `bson:"amount"` // Amount in Wei
+}
+
+// HasAppliedPayment reports whether the payment with the given key is already counted
+func (o *Order) HasAppliedPayment(key string) bool {
+	for _, applied := range o.AppliedPayments {
+		if applied == key {
+			return true
+		}
+	}
+	return false
+}
+
+// PaymentQuotes returns the quotes a payment for the order may have been made against
+func (o *Order) PaymentQuotes() []PaymentQuote {
+	if len(o.IssuedQuotes) > 0 {
+		return o.IssuedQuotes
+	}
+	if o.Quote != nil {
+		return []PaymentQuote{*o.Quote}
+	}
+	return nil
 }
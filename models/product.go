@@ -6,19 +6,40 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-type ProductSize string
+// ProductOption is a choice a product offers, such as Size or Color, and its values
+type ProductOption struct {
+	Name   string   `bson:"name" json:"name"`
+	Values []string `bson:"values" json:"values"`
+}
+
+// ProductVariant is one purchasable combination of option values with its own stock
+type ProductVariant struct {
+	SKU     string            `bson:"sku" json:"sku"`
+	Options map[string]string `bson:"options" json:"options"`                 // Option name to value, e.g. {"Size": "M", "Color": "Black"}
+	Price   string            `bson:"price,omitempty" json:"price,omitempty"` // Overrides the product price; in wei, sent as ETH like the product price
+	Stock   int               `bson:"stock" json:"stock"`
+	Images  []string          `bson:"images,omitempty" json:"images,omitempty"`
+}
+
+type TokenStandard string
 
 const (
-	SizeS  ProductSize = "S"
-	SizeM  ProductSize = "M"
-	SizeL  ProductSize = "L"
-	SizeXL ProductSize = "XL"
+	TokenStandardERC721  TokenStandard = "ERC721"
+	TokenStandardERC1155 TokenStandard = "ERC1155"
 )
 
-type ProductCategory struct {
-	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Name     string             `bson:"name" json:"name"`
-	ParentID primitive.ObjectID `bson:"parentId,omitempty" json:"parentId,omitempty"`
+// TokenRequirement is a minimum on-chain balance of an NFT collection
+type TokenRequirement struct {
+	Standard   TokenStandard `bson:"standard" json:"standard"`
+	Contract   string        `bson:"contract" json:"contract"`
+	TokenID    string        `bson:"tokenId,omitempty" json:"tokenId,omitempty"` // Required for ERC1155
+	MinBalance int64         `bson:"minBalance" json:"minBalance"`
+}
+
+// HolderDiscount reduces the price for holders of a token
+type HolderDiscount struct {
+	TokenRequirement `bson:",inline"`
+	DiscountBps      int64 `bson:"discountBps" json:"discountBps"` // 100 = 1%
 }
 
 type ProductRating struct {
@@ -32,16 +53,39 @@ type Product struct {
 	ID          primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
 	Name        string               `bson:"name" json:"name"`
 	Description string               `bson:"description" json:"description"`
-	Price       string               `bson:"price" json:"price"` // Price in ETH
+	Price       string               `bson:"price" json:"price"` // Price in wei; sent as a decimal ETH amount when creating or updating
 	PriceUSD    float64              `bson:"priceUSD" json:"priceUSD"`
-	Sizes       []ProductSize        `bson:"sizes" json:"sizes"`
-	Colors      []string             `bson:"colors" json:"colors"`
+	Options     []ProductOption      `bson:"options" json:"options"`
+	Variants    []ProductVariant     `bson:"variants" json:"variants"`
 	Images      []string             `bson:"images" json:"images"`
-	Stock       map[ProductSize]int  `bson:"stock" json:"stock"`
 	CreatedAt   time.Time            `bson:"createdAt" json:"createdAt"`
 	UpdatedAt   time.Time            `bson:"updatedAt" json:"updatedAt"`
 	Categories  []primitive.ObjectID `bson:"categories" json:"categories"`
 	Tags        []string             `bson:"tags" json:"tags"`
 	Ratings     []ProductRating      `bson:"ratings" json:"ratings"`
 	AvgRating   float64              `bson:"avgRating" json:"avgRating"`
+	RatingCount int                  `bson:"ratingCount" json:"ratingCount"`
+	Gate        *TokenRequirement    `bson:"gate,omitempty" json:"gate,omitempty"` // Only holders may buy
+	Discounts   []HolderDiscount     `bson:"discounts,omitempty" json:"discounts,omitempty"`
+	Version     int64                `bson:"version" json:"version"`                           // Incremented on every update, for optimistic concurrency
+	ArchivedAt  *time.Time           `bson:"archivedAt,omitempty" json:"archivedAt,omitempty"` // Hidden from listings and can't be bought, but can be restored
+	DeletedAt   *time.Time           `bson:"deletedAt,omitempty" json:"-"`                     // Kept so existing orders still resolve
+}
+
+// Variant returns the variant with the given SKU
+func (p Product) Variant(sku string) (ProductVariant, bool) {
+	for _, variant := range p.Variants {
+		if variant.SKU == sku {
+			return variant, true
+		}
+	}
+	return ProductVariant{}, false
+}
+
+// VariantPrice is the variant's price in wei, falling back to the product price
+func (p Product) VariantPrice(variant ProductVariant) string {
+	if variant.Price != "" {
+		return variant.Price
+	}
+	return p.Price
 }
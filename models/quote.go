@@ -0,0 +1,13 @@
+package models
+
+// PaymentQuote is an EIP-712 checkout quote signed by the backend for the payment contract
+type PaymentQuote struct {
+	OrderNumber       uint64 `bson:"orderNumber" json:"orderNumber"`
+	Amount            string `bson:"amount" json:"amount"` // Amount in Wei (or token base units)
+	Token             string `bson:"token" json:"token"`   // Zero address for native ETH
+	Payer             string `bson:"payer" json:"payer"`   // Zero address when any wallet may pay
+	Expiry            int64  `bson:"expiry" json:"expiry"` // Unix timestamp
+	ChainID           int64  `bson:"chainId" json:"chainId"`
+	VerifyingContract string `bson:"verifyingContract" json:"verifyingContract"`
+	Signature         string `bson:"signature" json:"signature"`
+}
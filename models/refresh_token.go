@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RefreshToken is a single-use token exchanged for a new access token.
+// Tokens issued by rotation share a FamilyID with the token they replaced,
+// which is also the ID of the login's Session.
+type RefreshToken struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID    primitive.ObjectID `bson:"userId" json:"userId"`
+	FamilyID  primitive.ObjectID `bson:"familyId" json:"familyId"`
+	TokenHash string             `bson:"tokenHash" json:"-"`
+	ExpiresAt time.Time          `bson:"expiresAt" json:"expiresAt"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+	RotatedAt *time.Time         `bson:"rotatedAt,omitempty" json:"rotatedAt,omitempty"`
+	RevokedAt *time.Time         `bson:"revokedAt,omitempty" json:"revokedAt,omitempty"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type RefundStatus string
+
+const (
+	RefundStatusPending RefundStatus = "PENDING"
+	RefundStatusSending RefundStatus = "SENDING" // Claimed by a worker; left here if it died mid-send
+	RefundStatusSent    RefundStatus = "SENT"
+	RefundStatusFailed  RefundStatus = "FAILED"
+)
+
+// Refund is a queued return of excess payment to the paying wallet
+type Refund struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	OrderID     primitive.ObjectID `bson:"orderId" json:"orderId"`
+	OrderNumber uint64             `bson:"orderNumber" json:"orderNumber"`
+	PaymentKey  string             `bson:"paymentKey,omitempty" json:"paymentKey,omitempty"` // Payment the refund is owed for; at most one refund each
+	ToAddress   string             `bson:"toAddress" json:"toAddress"`
+	Amount      string             `bson:"amount" json:"amount"` // Amount in Wei
+	Status      RefundStatus       `bson:"status" json:"status"`
+	TxHash      string             `bson:"txHash,omitempty" json:"txHash,omitempty"`
+	Attempts    int                `bson:"attempts" json:"attempts"`
+	LastError   string             `bson:"lastError,omitempty" json:"lastError,omitempty"`
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt   time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
@@ -0,0 +1,56 @@
+package models
+
+const (
+	RoleCustomer = "customer"
+	RoleMerchant = "merchant"
+	RoleAdmin    = "admin"
+)
+
+const (
+	PermissionCatalogWrite  = "catalog:write"
+	PermissionOrdersRead    = "orders:read"
+	PermissionOrdersFulfill = "orders:fulfill"
+	PermissionListenerAdmin = "listener:admin"
+	PermissionAuditRead     = "audit:read"
+	PermissionAPIKeysManage = "apikeys:manage"
+	PermissionRolesManage   = "roles:manage"
+)
+
+// RolePermissions maps each role to the permissions it grants
+var RolePermissions = map[string][]string{
+	RoleCustomer: {},
+	RoleMerchant: {PermissionCatalogWrite, PermissionOrdersRead, PermissionOrdersFulfill},
+	RoleAdmin:    {PermissionCatalogWrite, PermissionOrdersRead, PermissionOrdersFulfill, PermissionListenerAdmin, PermissionAuditRead, PermissionAPIKeysManage, PermissionRolesManage},
+}
+
+// APIKeyScopes are the permissions an API key may be granted. Managing API keys and
+// roles is left out so a leaked key cannot mint more keys or admins.
+var APIKeyScopes = []string{PermissionCatalogWrite, PermissionOrdersRead, PermissionOrdersFulfill, PermissionListenerAdmin, PermissionAuditRead}
+
+// IsValidAPIKeyScope reports whether scope can be granted to an API key
+func IsValidAPIKeyScope(scope string) bool {
+	for _, s := range APIKeyScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidRole reports whether role is a known role
+func IsValidRole(role string) bool {
+	_, ok := RolePermissions[role]
+	return ok
+}
+
+// HasPermission reports whether any of the roles grants permission
+func HasPermission(roles []string, permission string) bool {
+	for _, role := range roles {
+		for _, granted := range RolePermissions[role] {
+			if granted == permission {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Session is one login on one device. Its ID is the family ID of the refresh tokens
+// issued for the login and the "sid" claim of its access tokens.
+type Session struct {
+	ID         primitive.ObjectID `bson:"_id" json:"id"`
+	UserID     primitive.ObjectID `bson:"userId" json:"userId"`
+	Device     string             `bson:"device" json:"device"`
+	IP         string             `bson:"ip" json:"ip"`
+	UserAgent  string             `bson:"userAgent" json:"userAgent"`
+	MFA        bool               `bson:"mfa" json:"mfa"` // The login passed two-factor authentication
+	CreatedAt  time.Time          `bson:"createdAt" json:"createdAt"`
+	LastSeenAt time.Time          `bson:"lastSeenAt" json:"lastSeenAt"`
+	ExpiresAt  time.Time          `bson:"expiresAt" json:"expiresAt"`
+	RevokedAt  *time.Time         `bson:"revokedAt,omitempty" json:"revokedAt,omitempty"`
+	Current    bool               `bson:"-" json:"current,omitempty"` // Set when listing the caller's own sessions
+}
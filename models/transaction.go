@@ -1,6 +1,8 @@
 package models
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -11,6 +13,16 @@ type Transaction struct {
 	OrderID         uint64             `bson:"orderId"`
 	CustomerAddress string             `bson:"customerAddress"`
 	Amount          string             `bson:"amount"`
+	Token           string             `bson:"token,omitempty"` // ERC-20 paid in; empty for native ETH
+	TxHash          string             `bson:"txHash,omitempty"`
+	LogIndex        uint               `bson:"logIndex"` // Deposits are plain transfers without a log and use 0
+	BlockNumber     uint64             `bson:"blockNumber,omitempty"`
+	BlockTime       time.Time          `bson:"blockTime,omitempty"` // Timestamp of the block the payment was mined in
 	Timestamp       time.Time          `bson:"timestamp"`
 	Status          string             `bson:"status"`
 }
+
+// PaymentKey identifies the on-chain event a transaction was recorded from
+func (t *Transaction) PaymentKey() string {
+	return fmt.Sprintf("%s:%d", strings.ToLower(t.TxHash), t.LogIndex)
+}
@@ -17,6 +17,31 @@ type Address struct {
 	IsDefault  bool               `bson:"isDefault" json:"isDefault"`
 }
 
+// Wallet is an address the user proved control of by signing a nonce
+type Wallet struct {
+	Address    string    `bson:"address" json:"address"`
+	VerifiedAt time.Time `bson:"verifiedAt" json:"verifiedAt"`
+	ENSName    string    `bson:"-" json:"ensName,omitempty"` // Primary ENS name, looked up on read
+}
+
+// Identity is an external sign-in provider account linked to the user
+type Identity struct {
+	Provider   string    `bson:"provider" json:"provider"`
+	ProviderID string    `bson:"providerId" json:"providerId"`
+	Email      string    `bson:"email" json:"email"` // Email the provider reported when linking
+	LinkedAt   time.Time `bson:"linkedAt" json:"linkedAt"`
+}
+
+// TwoFactor is the user's TOTP enrolment
+type TwoFactor struct {
+	Enabled       bool       `bson:"enabled" json:"enabled"`
+	Secret        string     `bson:"secret,omitempty" json:"-"`        // Base32 TOTP secret
+	PendingSecret string     `bson:"pendingSecret,omitempty" json:"-"` // Secret awaiting its first code during setup
+	RecoveryCodes []string   `bson:"recoveryCodes,omitempty" json:"-"` // SHA-256 hashes of unused recovery codes
+	LastUsedStep  int64      `bson:"lastUsedStep,omitempty" json:"-"`  // Last accepted TOTP step, so a code works once
+	EnabledAt     *time.Time `bson:"enabledAt,omitempty" json:"enabledAt,omitempty"`
+}
+
 type User struct {
 	ID            primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
 	Name          string                 `bson:"name" json:"name"`
@@ -26,9 +51,32 @@ type User struct {
 	Image         string                 `bson:"image,omitempty" json:"image,omitempty"`
 	Provider      string                 `bson:"provider" json:"provider"` // "credentials", "google", etc.
 	ProviderId    string                 `bson:"providerId,omitempty" json:"providerId,omitempty"`
+	Identities    []Identity             `bson:"identities,omitempty" json:"identities,omitempty"`
+	Roles         []string               `bson:"roles,omitempty" json:"roles,omitempty"` // See models/role.go
 	PhoneNumber   string                 `bson:"phoneNumber,omitempty" json:"phoneNumber,omitempty"`
 	Addresses     []Address              `bson:"addresses" json:"addresses"`
+	Wallets       []Wallet               `bson:"wallets,omitempty" json:"wallets,omitempty"`
+	WalletNonce   string                 `bson:"walletNonce,omitempty" json:"-"`
+	WalletNonceAt *time.Time             `bson:"walletNonceAt,omitempty" json:"-"` // When WalletNonce was issued
+	TwoFactor     *TwoFactor             `bson:"twoFactor,omitempty" json:"twoFactor,omitempty"`
 	Preferences   map[string]interface{} `bson:"preferences" json:"preferences"`
+	DeleteAt      *time.Time             `bson:"deleteAt,omitempty" json:"deleteAt,omitempty"` // Scheduled account deletion
 	CreatedAt     time.Time              `bson:"createdAt" json:"createdAt"`
 	UpdatedAt     time.Time              `bson:"updatedAt" json:"updatedAt"`
 }
+
+// LinkedIdentities returns the user's provider identities, including the one recorded
+// in Provider/ProviderId by sign-ins made before identities could be linked
+func (u User) LinkedIdentities() []Identity {
+	identities := append([]Identity{}, u.Identities...)
+	if u.Provider == "" || u.Provider == "credentials" || u.ProviderId == "" {
+		return identities
+	}
+
+	for _, identity := range identities {
+		if identity.Provider == u.Provider && identity.ProviderID == u.ProviderId {
+			return identities
+		}
+	}
+	return append(identities, Identity{Provider: u.Provider, ProviderID: u.ProviderId, Email: u.Email, LinkedAt: u.CreatedAt})
+}
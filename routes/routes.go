@@ -5,6 +5,7 @@ import (
 
 	"github.com/Madhav-Gupta-28/0xmart-backend-go/handlers"
 	customMiddleware "github.com/Madhav-Gupta-28/0xmart-backend-go/middleware"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
 	"github.com/labstack/echo/v4"
 )
 
@@ -18,27 +19,80 @@ func SetupRoutes(e *echo.Echo) {
 	e.POST("/api/auth/signup", handlers.SignUp)
 	e.POST("/api/auth/signin", handlers.NextAuthSignIn)
 	e.GET("/api/auth/csrf", handlers.NextAuthCSRF)
+	e.POST("/api/auth/refresh", handlers.RefreshToken)
+	e.POST("/api/auth/logout", handlers.Logout)
+	e.POST("/api/auth/verify-email", handlers.VerifyEmail)
+	e.POST("/api/auth/password/forgot", handlers.ForgotPassword)
+	e.POST("/api/auth/password/reset", handlers.ResetPassword)
+	e.POST("/api/auth/2fa/verify", handlers.VerifyTwoFactorLogin)
+	e.GET("/.well-known/jwks.json", handlers.GetJWKS)
 
 	// Public Product routes
 	e.GET("/api/products", handlers.GetProducts)           // Make this public
 	e.GET("/api/products/:id", handlers.GetProduct)        // Make this public
 	e.GET("/api/products/search", handlers.SearchProducts) // Make this public
+	e.GET("/api/categories", handlers.GetCategories)
+	e.GET("/api/categories/tree", handlers.GetCategoryTree)
+	e.GET("/api/categories/:id", handlers.GetCategory) // ID or slug
+
+	// Integration routes, open to API keys as well as user tokens. Registered before the
+	// user-only group so that group's catch-all keeps answering unknown /api paths.
+	integrations := e.Group("/api", customMiddleware.CSRFMiddleware(), customMiddleware.APIKeyOrNextAuthMiddleware())
+	integrations.POST("/products", handlers.CreateProduct, customMiddleware.RequirePermission(models.PermissionCatalogWrite))
+	integrations.PUT("/products/:id", handlers.UpdateProduct, customMiddleware.RequirePermission(models.PermissionCatalogWrite))
+	integrations.PATCH("/products/:id", handlers.PatchProduct, customMiddleware.RequirePermission(models.PermissionCatalogWrite))
+	integrations.DELETE("/products/:id", handlers.DeleteProduct, customMiddleware.RequirePermission(models.PermissionCatalogWrite))
+	integrations.POST("/products/:id/archive", handlers.ArchiveProduct, customMiddleware.RequirePermission(models.PermissionCatalogWrite))
+	integrations.POST("/products/:id/unarchive", handlers.UnarchiveProduct, customMiddleware.RequirePermission(models.PermissionCatalogWrite))
+	integrations.POST("/categories", handlers.CreateCategory, customMiddleware.RequirePermission(models.PermissionCatalogWrite))
+	integrations.PUT("/categories/:id", handlers.UpdateCategory, customMiddleware.RequirePermission(models.PermissionCatalogWrite))
+	integrations.DELETE("/categories/:id", handlers.DeleteCategory, customMiddleware.RequirePermission(models.PermissionCatalogWrite))
+	integrations.GET("/fulfillment/orders", handlers.GetFulfillmentOrders, customMiddleware.RequirePermission(models.PermissionOrdersRead))
+	integrations.PUT("/orders/:orderId/fulfillment", handlers.UpdateOrderFulfillment, customMiddleware.RequirePermission(models.PermissionOrdersFulfill))
+
+	// Contract listener admin routes
+	listener := integrations.Group("/admin/listener", customMiddleware.RequirePermission(models.PermissionListenerAdmin))
+	listener.GET("/health", handlers.HealthCheck)
+	listener.GET("/metrics", handlers.GetMetrics)
+	listener.POST("/start", handlers.StartListener)
+	listener.POST("/restart", handlers.RestartListener)
+
+	// Audit log routes
+	auditLog := integrations.Group("/admin/audit", customMiddleware.RequirePermission(models.PermissionAuditRead))
+	auditLog.GET("", handlers.GetAuditLog)
+	auditLog.GET("/verify", handlers.VerifyAuditLog)
 
 	// Protected API routes (require authentication)
 	api := e.Group("/api")
-	api.Use(customMiddleware.NextAuthMiddleware())
+	api.Use(customMiddleware.CSRFMiddleware(), customMiddleware.NextAuthMiddleware())
 
 	// Protected Product routes
-	api.POST("/products", handlers.CreateProduct) // Only creating products needs auth
 	api.POST("/products/:productId/ratings", handlers.RateProduct)
 
 	// Protected User routes
 	api.GET("/users/me", handlers.GetUserProfile)
 	api.PUT("/users/me", handlers.UpdateUserProfile)
+	api.POST("/users/me/email/verification", handlers.ResendVerificationEmail)
+	api.PUT("/users/me/password", handlers.ChangePassword)
+	api.POST("/users/me/2fa/setup", handlers.SetupTwoFactor)
+	api.POST("/users/me/2fa/enable", handlers.EnableTwoFactor)
+	api.POST("/users/me/2fa/disable", handlers.DisableTwoFactor)
+	api.POST("/users/me/2fa/recovery-codes", handlers.RegenerateRecoveryCodes)
+	api.GET("/users/me/identities", handlers.GetIdentities)
+	api.POST("/users/me/identities", handlers.LinkIdentity)
+	api.DELETE("/users/me/identities/:provider/:providerId", handlers.UnlinkIdentity)
+	api.GET("/users/me/export", handlers.ExportUserData)
+	api.POST("/users/me/deletion", handlers.RequestAccountDeletion)
+	api.DELETE("/users/me/deletion", handlers.CancelAccountDeletion)
+	api.GET("/users/me/sessions", handlers.GetSessions)
+	api.DELETE("/users/me/sessions", handlers.RevokeAllSessions)
+	api.DELETE("/users/me/sessions/:id", handlers.RevokeSession)
 	api.GET("/users/me/addresses", handlers.GetUserAddresses)
 	api.POST("/users/me/addresses", handlers.AddUserAddress)
 	api.PUT("/users/me/addresses/:id", handlers.UpdateUserAddress)
 	api.DELETE("/users/me/addresses/:id", handlers.DeleteUserAddress)
+	api.GET("/users/me/wallets/nonce", handlers.GetWalletNonce)
+	api.POST("/users/me/wallets", handlers.LinkWallet)
 
 	// Cart routes
 	api.GET("/cart", handlers.GetCart)
@@ -48,11 +102,23 @@ func SetupRoutes(e *echo.Echo) {
 
 	// Order routes
 	api.GET("/orders", handlers.GetOrders)                        // Get all orders
+	api.GET("/orders/events", handlers.StreamOrderEvents)         // Stream order status changes (SSE)
 	api.GET("/orders/:orderId", handlers.GetOrder)                // Get single order
 	api.GET("/orders/:orderId/status", handlers.GetOrderStatus)   // Get order status
 	api.POST("/orders", handlers.CreateOrder)                     // Create order
 	api.POST("/orders/:orderId/payment", handlers.ProcessPayment) // Process payment
 
+	// API key admin routes
+	apiKeys := api.Group("/admin/api-keys", customMiddleware.RequirePermission(models.PermissionAPIKeysManage))
+	apiKeys.GET("", handlers.GetAPIKeys)
+	apiKeys.POST("", handlers.CreateAPIKey)
+	apiKeys.DELETE("/:id", handlers.RevokeAPIKey)
+
+	// Role admin routes
+	roles := api.Group("/admin/users/:id/roles", customMiddleware.RequirePermission(models.PermissionRolesManage))
+	roles.PUT("/:role", handlers.GrantRole)
+	roles.DELETE("/:role", handlers.RevokeRole)
+
 	// Add this line in SetupRoutes
 	e.GET("/health", func(c echo.Context) error {
 		return c.JSON(200, map[string]string{"status": "ok"})
@@ -0,0 +1,284 @@
+package utils
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/config"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/database"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ExportedRating is one of the user's product ratings
+type ExportedRating struct {
+	ProductID   primitive.ObjectID `json:"productId"`
+	ProductName string             `json:"productName"`
+	Rating      float64            `json:"rating"`
+	Comment     string             `json:"comment"`
+	CreatedAt   time.Time          `json:"createdAt"`
+}
+
+// UserExport is everything stored about a user, as returned for a data access request
+type UserExport struct {
+	ExportedAt time.Time             `json:"exportedAt"`
+	Profile    models.User           `json:"profile"` // Includes addresses, linked wallets and identities
+	Carts      []models.Cart         `json:"carts"`
+	Orders     []models.Order        `json:"orders"`
+	Ratings    []ExportedRating      `json:"ratings"`
+	Sessions   []models.Session      `json:"sessions"`
+	Lockouts   []models.LoginLockout `json:"lockouts"`
+	AuditLog   []models.AuditEntry   `json:"auditLog"` // Entries the user made or that concern them
+}
+
+// AccountDeletionGracePeriod is how long a deletion request can be cancelled,
+// configurable with ACCOUNT_DELETION_GRACE_PERIOD
+func AccountDeletionGracePeriod() time.Duration {
+	grace, err := time.ParseDuration(config.GetEnv("ACCOUNT_DELETION_GRACE_PERIOD", "720h"))
+	if err != nil || grace < 0 {
+		return 30 * 24 * time.Hour
+	}
+	return grace
+}
+
+// BuildUserExport gathers the user's data from every collection that holds it
+func BuildUserExport(ctx context.Context, userID primitive.ObjectID) (UserExport, error) {
+	export := UserExport{
+		ExportedAt: time.Now(),
+		Carts:      []models.Cart{},
+		Orders:     []models.Order{},
+		Ratings:    []ExportedRating{},
+		Sessions:   []models.Session{},
+		Lockouts:   []models.LoginLockout{},
+		AuditLog:   []models.AuditEntry{},
+	}
+
+	if err := database.DB.Collection("users").FindOne(ctx, bson.M{"_id": userID}).Decode(&export.Profile); err != nil {
+		return UserExport{}, err
+	}
+
+	if err := findAll(ctx, "carts", bson.M{"userId": userID}, &export.Carts); err != nil {
+		return UserExport{}, err
+	}
+	if err := findAll(ctx, "orders", bson.M{"userId": userID}, &export.Orders); err != nil {
+		return UserExport{}, err
+	}
+	if err := findAll(ctx, "sessions", bson.M{"userId": userID}, &export.Sessions); err != nil {
+		return UserExport{}, err
+	}
+	if err := findAll(ctx, "login_lockouts", loginLockoutFilter(export.Profile.Email, userID.Hex()), &export.Lockouts); err != nil {
+		return UserExport{}, err
+	}
+	if err := findAll(ctx, "audit_log", userAuditFilter(export.Profile.Email, userID.Hex()), &export.AuditLog); err != nil {
+		return UserExport{}, err
+	}
+
+	var products []models.Product
+	if err := findAll(ctx, "products", bson.M{"ratings.userId": userID}, &products); err != nil {
+		return UserExport{}, err
+	}
+	for _, product := range products {
+		for _, rating := range product.Ratings {
+			if rating.UserID != userID {
+				continue
+			}
+			export.Ratings = append(export.Ratings, ExportedRating{
+				ProductID:   product.ID,
+				ProductName: product.Name,
+				Rating:      rating.Rating,
+				Comment:     rating.Comment,
+				CreatedAt:   rating.CreatedAt,
+			})
+		}
+	}
+
+	return export, nil
+}
+
+// WriteExportZip writes the export as a ZIP archive with one JSON file per section
+func WriteExportZip(w io.Writer, export UserExport) error {
+	archive := zip.NewWriter(w)
+
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", export.Profile},
+		{"carts.json", export.Carts},
+		{"orders.json", export.Orders},
+		{"ratings.json", export.Ratings},
+		{"sessions.json", export.Sessions},
+		{"lockouts.json", export.Lockouts},
+		{"audit_log.json", export.AuditLog},
+	}
+
+	for _, file := range files {
+		f, err := archive.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: export.ExportedAt})
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(f)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.data); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+// ScheduleAccountDeletion marks the account for deletion once the grace period has passed
+func ScheduleAccountDeletion(ctx context.Context, user models.User) (time.Time, error) {
+	deleteAt := time.Now().Add(AccountDeletionGracePeriod())
+
+	_, err := database.DB.Collection("users").UpdateOne(
+		ctx,
+		bson.M{"_id": user.ID},
+		bson.M{"$set": bson.M{"deleteAt": deleteAt, "updatedAt": time.Now()}},
+	)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	err = Mail().Send(ctx, Email{
+		To:      user.Email,
+		Subject: "Your 0xmart account will be deleted",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nYour 0xmart account is scheduled for deletion on %s. Sign in and cancel the request before then to keep it.\n\nAfter deletion your orders are kept for accounting, without your name, email, shipping or wallet address. The security log of sign-ins and account changes is kept as a tamper-evident record.\n",
+			user.Name, deleteAt.Format("2 January 2006"),
+		),
+	})
+	if err != nil {
+		log.Printf("❌ Failed to send deletion notice to %s: %v", user.Email, err)
+	}
+
+	return deleteAt, nil
+}
+
+// CancelAccountDeletion clears a pending deletion request
+func CancelAccountDeletion(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := database.DB.Collection("users").UpdateOne(
+		ctx,
+		bson.M{"_id": userID},
+		bson.M{"$unset": bson.M{"deleteAt": ""}, "$set": bson.M{"updatedAt": time.Now()}},
+	)
+	return err
+}
+
+// userAuditFilter matches audit entries made by the user or about their account
+func userAuditFilter(email, userID string) bson.M {
+	return bson.M{"$or": []bson.M{
+		{"actorId": userID},
+		{"targetId": userID},
+		{"targetType": models.AuditTargetAccount, "targetId": strings.ToLower(strings.TrimSpace(email))},
+	}}
+}
+
+// DeleteAccount erases a user. Orders stay for accounting but move to a random
+// pseudonymous ID and lose their shipping and wallet address; ratings keep their
+// score under the same pseudonym without the comment. Everything else about the user
+// is removed, except the audit log: it is hash-chained, so its entries, IP and user
+// agent included, are kept unchanged as a security record.
+func DeleteAccount(ctx context.Context, userID primitive.ObjectID) error {
+	anonymousID := primitive.NewObjectID()
+	now := time.Now()
+
+	var user models.User
+	if err := database.DB.Collection("users").FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		return err
+	}
+
+	_, err := database.DB.Collection("orders").UpdateMany(
+		ctx,
+		bson.M{"userId": userID},
+		bson.M{
+			"$set":   bson.M{"userId": anonymousID, "anonymizedAt": now, "walletAddress": ""},
+			"$unset": bson.M{"shippingAddress": ""},
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = database.DB.Collection("products").UpdateMany(
+		ctx,
+		bson.M{"ratings.userId": userID},
+		bson.M{"$set": bson.M{"ratings.$[rating].userId": anonymousID, "ratings.$[rating].comment": ""}},
+		options.Update().SetArrayFilters(options.ArrayFilters{Filters: []interface{}{bson.M{"rating.userId": userID}}}),
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := RevokeUserSessions(ctx, userID, primitive.NilObjectID); err != nil {
+		return err
+	}
+	if err := ForgetLoginHistory(ctx, user.Email, userID.Hex()); err != nil {
+		return err
+	}
+
+	for _, collection := range []string{"carts", "sessions", "refresh_tokens", "user_tokens"} {
+		if _, err := database.DB.Collection(collection).DeleteMany(ctx, bson.M{"userId": userID}); err != nil {
+			return err
+		}
+	}
+
+	_, err = database.DB.Collection("users").DeleteOne(ctx, bson.M{"_id": userID})
+	return err
+}
+
+// StartAccountDeletionWorker deletes accounts whose grace period has passed
+func StartAccountDeletionWorker(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		if err := deleteDueAccounts(ctx); err != nil {
+			log.Printf("❌ Account deletion worker error: %v", err)
+		}
+		cancel()
+	}
+}
+
+func deleteDueAccounts(ctx context.Context) error {
+	var users []models.User
+	err := findAll(ctx, "users", bson.M{"deleteAt": bson.M{"$lte": time.Now()}}, &users)
+	if err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		if err := DeleteAccount(ctx, user.ID); err != nil {
+			log.Printf("❌ Failed to delete account %s: %v", user.ID.Hex(), err)
+			continue
+		}
+		log.Printf("🗑️ Deleted account %s", user.ID.Hex())
+		RecordAudit(models.AuditEntry{
+			ActorType:  models.AuditActorSystem,
+			Action:     models.AuditAccountDeleted,
+			TargetType: models.AuditTargetUser,
+			TargetID:   user.ID.Hex(),
+			Metadata:   map[string]string{"requestedDeleteAt": user.DeleteAt.UTC().Format(time.RFC3339)},
+		})
+	}
+	return nil
+}
+
+func findAll(ctx context.Context, collection string, filter bson.M, results interface{}) error {
+	cursor, err := database.DB.Collection(collection).Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	return cursor.All(ctx, results)
+}
@@ -0,0 +1,178 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/config"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/database"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/time/rate"
+)
+
+// APIKeyPrefix starts every API key, which tells them apart from JWTs
+const APIKeyPrefix = "oxm_"
+
+var ErrInvalidAPIKey = errors.New("invalid, expired or revoked API key")
+
+// apiKeyLimiters holds a token bucket per key. Limits apply per replica.
+var apiKeyLimiters = struct {
+	mu       sync.Mutex
+	limiters map[primitive.ObjectID]*rate.Limiter
+}{limiters: make(map[primitive.ObjectID]*rate.Limiter)}
+
+// DefaultAPIKeyRateLimit is the requests per minute of keys created without a limit,
+// configurable with API_KEY_RATE_LIMIT
+func DefaultAPIKeyRateLimit() int {
+	n, err := strconv.Atoi(config.GetEnv("API_KEY_RATE_LIMIT", "60"))
+	if err != nil || n <= 0 {
+		return 60
+	}
+	return n
+}
+
+// IsAPIKey reports whether a credential is an API key rather than a JWT
+func IsAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, APIKeyPrefix)
+}
+
+// EnsureAPIKeyIndexes creates the index keys are looked up by
+func EnsureAPIKeyIndexes(ctx context.Context) error {
+	_, err := database.DB.Collection("api_keys").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "keyHash", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// CreateAPIKey stores a new key and returns it with the raw key, which is not kept
+// anywhere and cannot be shown again
+func CreateAPIKey(ctx context.Context, key models.APIKey) (models.APIKey, string, error) {
+	id := make([]byte, 4)
+	secret := make([]byte, 24)
+	if _, err := rand.Read(id); err != nil {
+		return models.APIKey{}, "", err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return models.APIKey{}, "", err
+	}
+
+	key.Prefix = APIKeyPrefix + hex.EncodeToString(id)
+	raw := key.Prefix + "_" + hex.EncodeToString(secret)
+
+	key.ID = primitive.NewObjectID()
+	key.KeyHash = HashToken(raw)
+	key.CreatedAt = time.Now()
+	if key.RateLimit <= 0 {
+		key.RateLimit = DefaultAPIKeyRateLimit()
+	}
+
+	if _, err := database.DB.Collection("api_keys").InsertOne(ctx, key); err != nil {
+		return models.APIKey{}, "", err
+	}
+	return key, raw, nil
+}
+
+// AuthenticateAPIKey returns the active key matching raw and records it as used from ip
+func AuthenticateAPIKey(ctx context.Context, raw, ip string) (models.APIKey, error) {
+	collection := database.DB.Collection("api_keys")
+	now := time.Now()
+
+	var key models.APIKey
+	err := collection.FindOne(ctx, bson.M{
+		"keyHash":   HashToken(raw),
+		"revokedAt": bson.M{"$exists": false},
+	}).Decode(&key)
+	if err == mongo.ErrNoDocuments {
+		return models.APIKey{}, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return models.APIKey{}, err
+	}
+
+	if key.ExpiresAt != nil && !key.ExpiresAt.After(now) {
+		return models.APIKey{}, ErrInvalidAPIKey
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > lastSeenResolution || key.LastUsedIP != ip {
+		_, err := collection.UpdateOne(ctx, bson.M{"_id": key.ID}, bson.M{"$set": bson.M{"lastUsedAt": now, "lastUsedIp": ip}})
+		if err != nil {
+			return models.APIKey{}, err
+		}
+		key.LastUsedAt = &now
+		key.LastUsedIP = ip
+	}
+	return key, nil
+}
+
+// AllowAPIKeyRequest takes one request from the key's rate limit and returns how long
+// to wait when none is left
+func AllowAPIKeyRequest(key models.APIKey) time.Duration {
+	perMinute := key.RateLimit
+	if perMinute <= 0 {
+		perMinute = DefaultAPIKeyRateLimit()
+	}
+
+	apiKeyLimiters.mu.Lock()
+	limiter, ok := apiKeyLimiters.limiters[key.ID]
+	if !ok {
+		limiter = rate.NewLimiter(rate.Limit(float64(perMinute)/60), perMinute)
+		apiKeyLimiters.limiters[key.ID] = limiter
+	}
+	apiKeyLimiters.mu.Unlock()
+
+	reservation := limiter.Reserve()
+	if delay := reservation.Delay(); delay > 0 {
+		reservation.Cancel()
+		return delay
+	}
+	return 0
+}
+
+// ListAPIKeys returns every key, newest first
+func ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	cursor, err := database.DB.Collection("api_keys").Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"createdAt": -1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	keys := []models.APIKey{}
+	if err := cursor.All(ctx, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey disables a key immediately
+func RevokeAPIKey(ctx context.Context, id primitive.ObjectID) (models.APIKey, error) {
+	var key models.APIKey
+	err := database.DB.Collection("api_keys").FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": id, "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revokedAt": time.Now()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&key)
+	if err == mongo.ErrNoDocuments {
+		return models.APIKey{}, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return models.APIKey{}, err
+	}
+
+	apiKeyLimiters.mu.Lock()
+	delete(apiKeyLimiters.limiters, id)
+	apiKeyLimiters.mu.Unlock()
+
+	return key, nil
+}
@@ -0,0 +1,266 @@
+package utils
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/database"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// auditCollection is append-only: nothing in the application updates or deletes it.
+// Give the service's database user insert and find rights only on it so that
+// tampering requires other credentials, which the hash chain then exposes.
+const auditCollection = "audit_log"
+
+// Retries when another replica appends the same sequence number first
+const auditInsertAttempts = 5
+
+const (
+	// Entries waiting for the writer; past this, appends happen on the caller
+	auditQueueSize = 1024
+	// Limit on a single append, which no request waits for
+	auditWriteTimeout = 10 * time.Second
+)
+
+var (
+	// auditMu serializes appends from this replica; the unique index on seq covers others
+	auditMu sync.Mutex
+
+	auditQueue     = make(chan models.AuditEntry, auditQueueSize)
+	auditQueueOnce sync.Once
+)
+
+// AuditChainResult is the outcome of checking the audit log's hash chain
+type AuditChainResult struct {
+	Entries  int64  `json:"entries"`
+	Valid    bool   `json:"valid"`
+	BrokenAt uint64 `json:"brokenAt,omitempty"` // Sequence number of the first bad entry
+	Reason   string `json:"reason,omitempty"`
+}
+
+// EnsureAuditIndexes creates the indexes the audit log relies on
+func EnsureAuditIndexes(ctx context.Context) error {
+	_, err := database.DB.Collection(auditCollection).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "seq", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "timestamp", Value: -1}}},
+		{Keys: bson.D{{Key: "action", Value: 1}, {Key: "timestamp", Value: -1}}},
+		{Keys: bson.D{{Key: "actorId", Value: 1}, {Key: "timestamp", Value: -1}}},
+		{Keys: bson.D{{Key: "targetType", Value: 1}, {Key: "targetId", Value: 1}}},
+	})
+	return err
+}
+
+// RecordAudit queues an entry for the audit writer, which appends it to the audit log
+// and chains it to the previous entry. It returns without waiting, so a slow write
+// never holds up the audited request and a cancelled request doesn't lose the entry.
+// Failures are logged; callers do not fail the audited action over them.
+func RecordAudit(entry models.AuditEntry) {
+	entry = newAuditEntry(entry)
+	auditQueueOnce.Do(func() { go runAuditWriter() })
+
+	select {
+	case auditQueue <- entry:
+	default:
+		// The writer is behind, so append here rather than drop the entry
+		ctx, cancel := context.WithTimeout(context.Background(), auditWriteTimeout)
+		defer cancel()
+		appendAudit(ctx, entry)
+	}
+}
+
+// AppendAudit writes an entry to the audit log before returning, for callers such as
+// command-line tools that exit straight after
+func AppendAudit(ctx context.Context, entry models.AuditEntry) error {
+	return appendAudit(ctx, newAuditEntry(entry))
+}
+
+func newAuditEntry(entry models.AuditEntry) models.AuditEntry {
+	if entry.ActorType == "" {
+		entry.ActorType = models.AuditActorAnonymous
+	}
+	entry.ID = primitive.NewObjectID()
+	// Mongo keeps milliseconds, so the hash is taken over what will be read back
+	entry.Timestamp = time.Now().UTC().Truncate(time.Millisecond)
+	return entry
+}
+
+// runAuditWriter appends queued entries one at a time
+func runAuditWriter() {
+	for entry := range auditQueue {
+		ctx, cancel := context.WithTimeout(context.Background(), auditWriteTimeout)
+		appendAudit(ctx, entry)
+		cancel()
+	}
+}
+
+func appendAudit(ctx context.Context, entry models.AuditEntry) error {
+	auditMu.Lock()
+	defer auditMu.Unlock()
+
+	collection := database.DB.Collection(auditCollection)
+
+	var err error
+	for attempt := 0; attempt < auditInsertAttempts; attempt++ {
+		var last models.AuditEntry
+		err = collection.FindOne(ctx, bson.M{}, options.FindOne().SetSort(bson.M{"seq": -1})).Decode(&last)
+		if err != nil && err != mongo.ErrNoDocuments {
+			break
+		}
+
+		entry.Seq = last.Seq + 1
+		entry.PrevHash = last.Hash
+		entry.Hash = auditHash(entry)
+
+		if _, err = collection.InsertOne(ctx, entry); !mongo.IsDuplicateKeyError(err) {
+			break
+		}
+	}
+
+	if err != nil {
+		log.Printf("❌ Failed to record audit entry %s: %v", entry.Action, err)
+	}
+	return err
+}
+
+// VerifyAuditChain walks the audit log in order and checks that every entry's hash
+// matches its contents and links to the entry before it
+func VerifyAuditChain(ctx context.Context) (AuditChainResult, error) {
+	cursor, err := database.DB.Collection(auditCollection).Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"seq": 1}))
+	if err != nil {
+		return AuditChainResult{}, err
+	}
+	defer cursor.Close(ctx)
+
+	result := AuditChainResult{Valid: true}
+	var prev models.AuditEntry
+
+	for cursor.Next(ctx) {
+		var entry models.AuditEntry
+		if err := cursor.Decode(&entry); err != nil {
+			return AuditChainResult{}, err
+		}
+		result.Entries++
+
+		reason := ""
+		switch {
+		case entry.Seq != prev.Seq+1:
+			reason = "missing entries before this one"
+		case entry.PrevHash != prev.Hash:
+			reason = "previous hash does not match"
+		case entry.Hash != auditHash(entry):
+			reason = "contents do not match hash"
+		}
+		if reason != "" {
+			result.Valid = false
+			result.BrokenAt = entry.Seq
+			result.Reason = reason
+			return result, nil
+		}
+		prev = entry
+	}
+
+	return result, cursor.Err()
+}
+
+// AuditDiff compares two values field by field through their JSON form and returns
+// the fields that differ. Either side may be nil for creations and deletions.
+func AuditDiff(before, after interface{}) map[string]models.AuditChange {
+	beforeFields := auditFields(before)
+	afterFields := auditFields(after)
+
+	changes := make(map[string]models.AuditChange)
+	for field, value := range beforeFields {
+		if other, ok := afterFields[field]; !ok || !reflect.DeepEqual(value, other) {
+			changes[field] = models.AuditChange{Before: auditValue(value), After: auditValue(afterFields[field])}
+		}
+	}
+	for field, value := range afterFields {
+		if _, ok := beforeFields[field]; !ok {
+			changes[field] = models.AuditChange{After: auditValue(value)}
+		}
+	}
+
+	if len(changes) == 0 {
+		return nil
+	}
+	return changes
+}
+
+func auditFields(v interface{}) map[string]interface{} {
+	if v == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		// Not an object, so record it as a single value
+		return map[string]interface{}{"value": v}
+	}
+	return fields
+}
+
+func auditValue(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// auditHash is the SHA-256 of the entry's canonical JSON form, excluding the hash itself.
+// Map keys are sorted by encoding/json and empty maps are omitted, so an entry hashes
+// the same before insertion and after being read back.
+func auditHash(entry models.AuditEntry) string {
+	data, _ := json.Marshal(struct {
+		ID         string                        `json:"id"`
+		Seq        uint64                        `json:"seq"`
+		Timestamp  int64                         `json:"timestamp"`
+		ActorType  string                        `json:"actorType"`
+		ActorID    string                        `json:"actorId"`
+		Action     string                        `json:"action"`
+		TargetType string                        `json:"targetType"`
+		TargetID   string                        `json:"targetId"`
+		Changes    map[string]models.AuditChange `json:"changes,omitempty"`
+		IP         string                        `json:"ip"`
+		UserAgent  string                        `json:"userAgent"`
+		RequestID  string                        `json:"requestId"`
+		Metadata   map[string]string             `json:"metadata,omitempty"`
+		PrevHash   string                        `json:"prevHash"`
+	}{
+		ID:         entry.ID.Hex(),
+		Seq:        entry.Seq,
+		Timestamp:  entry.Timestamp.UnixMilli(),
+		ActorType:  entry.ActorType,
+		ActorID:    entry.ActorID,
+		Action:     entry.Action,
+		TargetType: entry.TargetType,
+		TargetID:   entry.TargetID,
+		Changes:    entry.Changes,
+		IP:         entry.IP,
+		UserAgent:  entry.UserAgent,
+		RequestID:  entry.RequestID,
+		Metadata:   entry.Metadata,
+		PrevHash:   entry.PrevHash,
+	})
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type auditedProduct struct {
+	Name  string   `json:"name"`
+	Price string   `json:"price"`
+	Tags  []string `json:"tags,omitempty"`
+}
+
+func TestAuditDiffRecordsOnlyChangedFields(t *testing.T) {
+	got := AuditDiff(
+		auditedProduct{Name: "Hoodie", Price: "10", Tags: []string{"merch"}},
+		auditedProduct{Name: "Hoodie", Price: "12"},
+	)
+	want := map[string]models.AuditChange{
+		"price": {Before: `"10"`, After: `"12"`},
+		"tags":  {Before: `["merch"]`},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("AuditDiff = %v, want %v", got, want)
+	}
+
+	if got := AuditDiff(auditedProduct{Name: "Hoodie"}, auditedProduct{Name: "Hoodie"}); got != nil {
+		t.Errorf("unchanged product produced changes %v", got)
+	}
+}
+
+func TestAuditDiffCreationAndDeletion(t *testing.T) {
+	product := auditedProduct{Name: "Hoodie", Price: "10"}
+
+	created := AuditDiff(nil, product)
+	if created["name"] != (models.AuditChange{After: `"Hoodie"`}) || len(created) != 2 {
+		t.Errorf("creation diff = %v", created)
+	}
+
+	deleted := AuditDiff(product, nil)
+	if deleted["price"] != (models.AuditChange{Before: `"10"`}) || len(deleted) != 2 {
+		t.Errorf("deletion diff = %v", deleted)
+	}
+}
+
+// Mongo keeps milliseconds and drops empty maps, so a stored entry must hash the
+// same as the one that was inserted or every chain check would fail
+func TestAuditHashMatchesStoredEntry(t *testing.T) {
+	entry := models.AuditEntry{
+		ID:         primitive.NewObjectID(),
+		Seq:        42,
+		Timestamp:  time.Date(2026, 3, 1, 12, 30, 0, 123456789, time.UTC),
+		ActorType:  models.AuditActorUser,
+		ActorID:    primitive.NewObjectID().Hex(),
+		Action:     models.AuditProductUpdated,
+		TargetType: models.AuditTargetProduct,
+		TargetID:   primitive.NewObjectID().Hex(),
+		Changes:    map[string]models.AuditChange{"price": {Before: `"10"`, After: `"12"`}},
+		IP:         "203.0.113.7",
+		Metadata:   map[string]string{},
+		PrevHash:   "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+	}
+	entry.Hash = auditHash(entry)
+
+	data, err := bson.Marshal(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored models.AuditEntry
+	if err := bson.Unmarshal(data, &stored); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := auditHash(stored); got != stored.Hash {
+		t.Fatalf("stored entry hashes to %s, recorded %s", got, stored.Hash)
+	}
+
+	stored.Changes["price"] = models.AuditChange{Before: `"10"`, After: `"1"`}
+	if auditHash(stored) == stored.Hash {
+		t.Error("tampered changes kept the same hash")
+	}
+}
@@ -0,0 +1,241 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/database"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const maxSlugLength = 100
+
+var ErrCategoryNotFound = errors.New("category not found")
+
+// EnsureCategoryIndexes makes category slugs unique
+func EnsureCategoryIndexes(ctx context.Context) error {
+	_, err := database.DB.Collection("categories").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "slug", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// Slugify turns a name into a lowercase URL slug, e.g. "Hoodies & Sweaters" becomes
+// "hoodies-sweaters"
+func Slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+
+	slug := b.String()
+	if len(slug) > maxSlugLength {
+		slug = strings.TrimRight(slug[:maxSlugLength], "-")
+	}
+	return slug
+}
+
+// IsValidSlug reports whether slug is lowercase words joined by single dashes
+func IsValidSlug(slug string) bool {
+	return slug != "" && Slugify(slug) == slug
+}
+
+// ListCategories returns every category sorted by name
+func ListCategories(ctx context.Context) ([]models.ProductCategory, error) {
+	cursor, err := database.DB.Collection("categories").Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"name": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	categories := []models.ProductCategory{}
+	if err := cursor.All(ctx, &categories); err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+// FindCategory looks a category up by ID or by slug
+func FindCategory(ctx context.Context, idOrSlug string) (models.ProductCategory, error) {
+	filter := bson.M{"slug": idOrSlug}
+	if id, err := primitive.ObjectIDFromHex(idOrSlug); err == nil {
+		filter = bson.M{"_id": id}
+	}
+
+	var category models.ProductCategory
+	err := database.DB.Collection("categories").FindOne(ctx, filter).Decode(&category)
+	if err == mongo.ErrNoDocuments {
+		return models.ProductCategory{}, ErrCategoryNotFound
+	}
+	return category, err
+}
+
+// CategoryDescendants returns the category's ID followed by the IDs of every
+// category below it
+func CategoryDescendants(ctx context.Context, id primitive.ObjectID) ([]primitive.ObjectID, error) {
+	categories, err := ListCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return descendants(categories, id), nil
+}
+
+// CategoryTree returns the top-level categories with their subcategories and the
+// number of products matching productFilter in each
+func CategoryTree(ctx context.Context, productFilter bson.M) ([]*models.CategoryNode, error) {
+	categories, err := ListCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make(map[primitive.ObjectID]*models.CategoryNode, len(categories))
+	for _, category := range categories {
+		nodes[category.ID] = &models.CategoryNode{ProductCategory: category, Children: []*models.CategoryNode{}}
+	}
+
+	// Categories are sorted by name, so children are too. Categories whose parent is
+	// gone are shown at the top rather than lost.
+	roots := []*models.CategoryNode{}
+	for _, category := range categories {
+		node := nodes[category.ID]
+		if category.ParentID != nil {
+			if parent, ok := nodes[*category.ParentID]; ok {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+
+	// A cycle left by concurrent moves can't be reached from the top, so its first
+	// category by name is shown there instead
+	reached := map[primitive.ObjectID]bool{}
+	for _, root := range roots {
+		markCategoryNodes(root, reached)
+	}
+	for _, category := range categories {
+		if reached[category.ID] {
+			continue
+		}
+		node := nodes[category.ID]
+		parent := nodes[*category.ParentID]
+		for i, child := range parent.Children {
+			if child == node {
+				parent.Children = append(parent.Children[:i], parent.Children[i+1:]...)
+				break
+			}
+		}
+		roots = append(roots, node)
+		markCategoryNodes(node, reached)
+	}
+
+	cursor, err := database.DB.Collection("products").Find(ctx, productFilter, options.Find().SetProjection(bson.M{"categories": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var product models.Product
+		if err := cursor.Decode(&product); err != nil {
+			return nil, err
+		}
+
+		direct := map[primitive.ObjectID]bool{}
+		total := map[primitive.ObjectID]bool{}
+		for _, id := range product.Categories {
+			if _, ok := nodes[id]; !ok {
+				continue
+			}
+			direct[id] = true
+			for node := nodes[id]; node != nil && !total[node.ID]; {
+				total[node.ID] = true
+				if node.ParentID == nil {
+					break
+				}
+				node = nodes[*node.ParentID]
+			}
+		}
+		for id := range direct {
+			nodes[id].ProductCount++
+		}
+		for id := range total {
+			nodes[id].TotalProductCount++
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	return roots, nil
+}
+
+func markCategoryNodes(node *models.CategoryNode, reached map[primitive.ObjectID]bool) {
+	if reached[node.ID] {
+		return
+	}
+	reached[node.ID] = true
+	for _, child := range node.Children {
+		markCategoryNodes(child, reached)
+	}
+}
+
+// CategoryInCycle reports whether following the parent links up from the category
+// leads back to it, which two concurrent moves can cause
+func CategoryInCycle(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	categories, err := ListCategories(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	parents := make(map[primitive.ObjectID]*primitive.ObjectID, len(categories))
+	for _, category := range categories {
+		parents[category.ID] = category.ParentID
+	}
+
+	seen := map[primitive.ObjectID]bool{}
+	for parent := parents[id]; parent != nil && !seen[*parent]; parent = parents[*parent] {
+		if *parent == id {
+			return true, nil
+		}
+		seen[*parent] = true
+	}
+	return false, nil
+}
+
+// descendants walks the parent links down from id, ignoring any cycle in the data
+func descendants(categories []models.ProductCategory, id primitive.ObjectID) []primitive.ObjectID {
+	children := make(map[primitive.ObjectID][]primitive.ObjectID)
+	for _, category := range categories {
+		if category.ParentID != nil {
+			children[*category.ParentID] = append(children[*category.ParentID], category.ID)
+		}
+	}
+
+	ids := []primitive.ObjectID{id}
+	seen := map[primitive.ObjectID]bool{id: true}
+	for i := 0; i < len(ids); i++ {
+		for _, child := range children[ids[i]] {
+			if !seen[child] {
+				seen[child] = true
+				ids = append(ids, child)
+			}
+		}
+	}
+	return ids
+}
@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSlugify(t *testing.T) {
+	for name, want := range map[string]string{
+		"Hoodies & Sweaters": "hoodies-sweaters",
+		"  T-Shirts  ":       "t-shirts",
+		"NFT Drops 2026":     "nft-drops-2026",
+		"!!!":                "",
+	} {
+		if got := Slugify(name); got != want {
+			t.Errorf("Slugify(%q) = %q, want %q", name, got, want)
+		}
+	}
+
+	if IsValidSlug("Hoodies") || IsValidSlug("hoodies--sweaters") || IsValidSlug("") {
+		t.Error("IsValidSlug accepted a slug Slugify would change")
+	}
+}
+
+func categoryUnder(parent *primitive.ObjectID) models.ProductCategory {
+	return models.ProductCategory{ID: primitive.NewObjectID(), ParentID: parent}
+}
+
+func TestDescendantsWalksTheWholeSubtree(t *testing.T) {
+	apparel := categoryUnder(nil)
+	tops := categoryUnder(&apparel.ID)
+	hoodies := categoryUnder(&tops.ID)
+	hats := categoryUnder(&apparel.ID)
+	collectibles := categoryUnder(nil)
+	categories := []models.ProductCategory{collectibles, hoodies, hats, tops, apparel}
+
+	got := descendants(categories, apparel.ID)
+	want := []primitive.ObjectID{apparel.ID, hats.ID, tops.ID, hoodies.ID}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("descendants of apparel = %v, want %v", got, want)
+	}
+
+	if got := descendants(categories, hoodies.ID); !reflect.DeepEqual(got, []primitive.ObjectID{hoodies.ID}) {
+		t.Errorf("descendants of a leaf = %v", got)
+	}
+}
+
+// Two concurrent moves can leave a -> b -> a; searching must still terminate
+func TestDescendantsStopsAtCycles(t *testing.T) {
+	a := categoryUnder(nil)
+	b := categoryUnder(&a.ID)
+	a.ParentID = &b.ID
+	c := categoryUnder(&b.ID)
+
+	got := descendants([]models.ProductCategory{a, b, c}, a.ID)
+	want := []primitive.ObjectID{a.ID, b.ID, c.ID}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("descendants = %v, want %v", got, want)
+	}
+}
+
+func TestMarkCategoryNodesVisitsCyclesOnce(t *testing.T) {
+	a := &models.CategoryNode{ProductCategory: categoryUnder(nil)}
+	b := &models.CategoryNode{ProductCategory: categoryUnder(&a.ID)}
+	a.Children = []*models.CategoryNode{b}
+	b.Children = []*models.CategoryNode{a}
+
+	reached := map[primitive.ObjectID]bool{}
+	markCategoryNodes(a, reached)
+	if len(reached) != 2 || !reached[a.ID] || !reached[b.ID] {
+		t.Errorf("reached = %v, want both categories", reached)
+	}
+}
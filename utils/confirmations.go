@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/config"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+)
+
+type trackedPayment struct {
+	order         models.Order
+	txHash        string
+	blockNumber   uint64
+	confirmations uint64
+}
+
+// confirmationTracker publishes confirmation counts for recent payments as blocks arrive
+type confirmationTracker struct {
+	mu      sync.Mutex
+	pending map[string]*trackedPayment
+}
+
+var paymentConfirmations = &confirmationTracker{pending: make(map[string]*trackedPayment)}
+
+// confirmationTarget is the number of confirmations after which a payment is no longer tracked
+func confirmationTarget() uint64 {
+	target, err := strconv.ParseUint(config.GetEnv("PAYMENT_CONFIRMATIONS", "12"), 10, 64)
+	if err != nil || target == 0 {
+		return 12
+	}
+	return target
+}
+
+// TrackPaymentConfirmations starts publishing confirmation counts for a payment
+func TrackPaymentConfirmations(order models.Order, txHash string, blockNumber uint64) {
+	if txHash == "" || blockNumber == 0 {
+		return
+	}
+
+	paymentConfirmations.mu.Lock()
+	defer paymentConfirmations.mu.Unlock()
+
+	paymentConfirmations.pending[txHash] = &trackedPayment{
+		order:         order,
+		txHash:        txHash,
+		blockNumber:   blockNumber,
+		confirmations: 1,
+	}
+}
+
+// UntrackPaymentConfirmations stops publishing confirmation counts for a payment
+func UntrackPaymentConfirmations(txHash string) {
+	paymentConfirmations.mu.Lock()
+	defer paymentConfirmations.mu.Unlock()
+
+	delete(paymentConfirmations.pending, txHash)
+}
+
+// AdvanceConfirmations publishes updated counts for a new chain head
+func AdvanceConfirmations(head uint64) {
+	target := confirmationTarget()
+
+	paymentConfirmations.mu.Lock()
+	defer paymentConfirmations.mu.Unlock()
+
+	for txHash, payment := range paymentConfirmations.pending {
+		if head < payment.blockNumber {
+			continue
+		}
+
+		count := head - payment.blockNumber + 1
+		if count <= payment.confirmations {
+			continue
+		}
+		payment.confirmations = count
+
+		PublishOrderEvent(OrderEventConfirmations, payment.order, func(e *OrderEvent) {
+			e.TxHash = txHash
+			e.Confirmations = count
+		})
+
+		if count >= target {
+			delete(paymentConfirmations.pending, txHash)
+		}
+	}
+}
@@ -1,8 +1,33 @@
 package utils
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/config"
+)
+
+const (
+	// CSRFCookieName holds the signed copy of the token
+	CSRFCookieName = "csrf_token"
+	// CSRFHeaderName carries the token on state-changing requests
+	CSRFHeaderName = "X-CSRF-Token"
+)
+
+var ErrInvalidCSRFToken = errors.New("invalid or expired CSRF token")
+
+var (
+	csrfSecret     []byte
+	csrfSecretOnce sync.Once
 )
 
 func GenerateCSRFToken() string {
@@ -10,3 +35,79 @@ func GenerateCSRFToken() string {
 	rand.Read(b)
 	return base64.StdEncoding.EncodeToString(b)
 }
+
+// CSRFTokenTTL is how long an issued token stays valid, configurable with CSRF_TOKEN_TTL
+func CSRFTokenTTL() time.Duration {
+	ttl, err := time.ParseDuration(config.GetEnv("CSRF_TOKEN_TTL", "12h"))
+	if err != nil || ttl <= 0 {
+		return 12 * time.Hour
+	}
+	return ttl
+}
+
+// AuthCookieName is the cookie browsers may send the access token in instead of an
+// Authorization header, configurable with AUTH_COOKIE_NAME
+func AuthCookieName() string {
+	return config.GetEnv("AUTH_COOKIE_NAME", "access_token")
+}
+
+// CookieSecure reports whether cookies are limited to HTTPS; set COOKIE_SECURE=false
+// for local development over plain HTTP
+func CookieSecure() bool {
+	return config.GetEnv("COOKIE_SECURE", "true") != "false"
+}
+
+// IssueCSRFToken returns a token for the client to echo in the X-CSRF-Token header and
+// the cookie value that vouches for it. The signature covers the session ID, so a
+// pair fetched under another session, or none, is rejected even if a sibling
+// subdomain plants both.
+func IssueCSRFToken(sessionID string) (token, cookieValue string, expiresAt time.Time) {
+	token = GenerateCSRFToken()
+	expiresAt = time.Now().Add(CSRFTokenTTL())
+
+	payload := token + "." + strconv.FormatInt(expiresAt.Unix(), 10)
+	return token, payload + "." + signCSRF(sessionID, payload), expiresAt
+}
+
+// ValidateCSRFToken checks that the cookie is ours, unexpired and issued for the
+// session, and that the token sent with the request is the one it was issued with
+func ValidateCSRFToken(cookieValue, token, sessionID string) error {
+	parts := strings.Split(cookieValue, ".")
+	if len(parts) != 3 || token == "" {
+		return ErrInvalidCSRFToken
+	}
+
+	payload := parts[0] + "." + parts[1]
+	if sessionID == "" || !hmac.Equal([]byte(signCSRF(sessionID, payload)), []byte(parts[2])) {
+		return ErrInvalidCSRFToken
+	}
+
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return ErrInvalidCSRFToken
+	}
+
+	if subtle.ConstantTimeCompare([]byte(parts[0]), []byte(token)) != 1 {
+		return ErrInvalidCSRFToken
+	}
+	return nil
+}
+
+// signCSRF returns the base64url HMAC-SHA256 of the session ID and payload under
+// CSRF_SECRET. Without the setting a random secret is used, which only works with a
+// single replica and invalidates tokens on restart.
+func signCSRF(sessionID, payload string) string {
+	csrfSecretOnce.Do(func() {
+		if secret := config.GetEnv("CSRF_SECRET", ""); secret != "" {
+			csrfSecret = []byte(secret)
+			return
+		}
+		log.Println("⚠️ CSRF_SECRET is not set, using a random secret for this process")
+		csrfSecret = make([]byte, 32)
+		rand.Read(csrfSecret)
+	})
+
+	mac := hmac.New(sha256.New, csrfSecret)
+	mac.Write([]byte(sessionID + "." + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
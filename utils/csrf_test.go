@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const csrfSession = "6650f1c2a1b2c3d4e5f60718"
+
+func TestCSRFTokenValidForIssuingSession(t *testing.T) {
+	token, cookie, _ := IssueCSRFToken(csrfSession)
+
+	if err := ValidateCSRFToken(cookie, token, csrfSession); err != nil {
+		t.Fatalf("freshly issued token rejected: %v", err)
+	}
+	if err := ValidateCSRFToken(cookie, GenerateCSRFToken(), csrfSession); err == nil {
+		t.Error("token that does not match the cookie accepted")
+	}
+	if err := ValidateCSRFToken(cookie, "", csrfSession); err == nil {
+		t.Error("missing token accepted")
+	}
+}
+
+// A sibling subdomain can plant a genuine pair fetched with the attacker's own
+// session; it must not work for the victim's
+func TestCSRFTokenRejectedForOtherSession(t *testing.T) {
+	token, cookie, _ := IssueCSRFToken("attacker-session")
+
+	if err := ValidateCSRFToken(cookie, token, csrfSession); err == nil {
+		t.Error("pair issued for another session accepted")
+	}
+	if err := ValidateCSRFToken(cookie, token, ""); err == nil {
+		t.Error("pair accepted without a session")
+	}
+}
+
+func TestCSRFCookieCannotBeAltered(t *testing.T) {
+	token, cookie, _ := IssueCSRFToken(csrfSession)
+	parts := strings.Split(cookie, ".")
+
+	extended := parts[0] + ".9999999999." + parts[2]
+	if err := ValidateCSRFToken(extended, token, csrfSession); err == nil {
+		t.Error("cookie with an extended expiry accepted")
+	}
+
+	if err := ValidateCSRFToken(token, token, csrfSession); err == nil {
+		t.Error("unsigned cookie accepted")
+	}
+
+	expired := token + "." + strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)
+	if err := ValidateCSRFToken(expired+"."+signCSRF(csrfSession, expired), token, csrfSession); err == nil {
+		t.Error("expired cookie accepted")
+	}
+}
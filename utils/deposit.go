@@ -0,0 +1,396 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/config"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/database"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	PaymentModeContract       = "contract"
+	PaymentModeDepositAddress = "deposit_address"
+
+	// Deposit addresses use the external chain of the account key: <xpub>/0/<index>
+	depositChain = uint32(0)
+)
+
+// DepositAddressMode reports whether orders are paid to per-order deposit addresses
+func DepositAddressMode() bool {
+	return config.GetEnv("PAYMENT_MODE", PaymentModeContract) == PaymentModeDepositAddress
+}
+
+// DeriveDepositAddress derives the deposit address for an index from DEPOSIT_XPUB
+func DeriveDepositAddress(index uint32) (string, error) {
+	xpub := config.GetEnv("DEPOSIT_XPUB", "")
+	if xpub == "" {
+		return "", errors.New("DEPOSIT_XPUB is not configured")
+	}
+
+	accountKey, err := ParseExtendedKey(xpub)
+	if err != nil {
+		return "", fmt.Errorf("invalid DEPOSIT_XPUB: %v", err)
+	}
+
+	key, err := accountKey.Derive(depositChain, index)
+	if err != nil {
+		return "", err
+	}
+
+	address, err := key.Address()
+	if err != nil {
+		return "", err
+	}
+	return address.Hex(), nil
+}
+
+// DepositWatcher watches new blocks for value transfers to open deposit addresses.
+// A block is scanned once it has DEPOSIT_CONFIRMATIONS confirmations, continuing from
+// the last block scanned so blocks missed while disconnected are caught up.
+type DepositWatcher struct {
+	client        *ethclient.Client
+	isListening   bool
+	confirmations uint64
+}
+
+func NewDepositWatcher() *DepositWatcher {
+	confirmations, err := strconv.ParseUint(config.GetEnv("DEPOSIT_CONFIRMATIONS", "12"), 10, 64)
+	if err != nil || confirmations == 0 {
+		confirmations = 12
+	}
+	return &DepositWatcher{confirmations: confirmations}
+}
+
+func (w *DepositWatcher) Start() error {
+	if w.isListening {
+		return errors.New("already listening")
+	}
+
+	client, err := ethclient.Dial(os.Getenv("WEB3_WEBSOCKET_URL"))
+	if err != nil {
+		log.Printf("❌ Failed to connect to Ethereum client: %v", err)
+		return err
+	}
+
+	headers := make(chan *types.Header)
+	sub, err := client.SubscribeNewHead(context.Background(), headers)
+	if err != nil {
+		client.Close()
+		log.Printf("❌ Failed to subscribe to new blocks: %v", err)
+		return err
+	}
+
+	w.client = client
+	w.isListening = true
+	log.Println("👂 Watching deposit addresses...")
+
+	go func() {
+		for {
+			select {
+			case err := <-sub.Err():
+				log.Printf("❌ Deposit subscription error: %v", err)
+				w.isListening = false
+				client.Close()
+				time.Sleep(5 * time.Second)
+				if err := w.Start(); err != nil {
+					log.Printf("❌ Failed to restart deposit watcher: %v", err)
+				}
+				return
+			case header := <-headers:
+				if err := w.catchUp(header.Number.Uint64()); err != nil {
+					log.Printf("❌ Failed to scan blocks up to %s: %v", header.Number, err)
+				}
+				AdvanceConfirmations(header.Number.Uint64())
+			}
+		}
+	}()
+
+	return nil
+}
+
+// catchUp scans every block after the last scanned one that is confirmed at head.
+// Progress is saved per block, so a scan cut short continues on the next head.
+func (w *DepositWatcher) catchUp(head uint64) error {
+	if head+1 <= w.confirmations {
+		return nil
+	}
+	confirmed := head + 1 - w.confirmations
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	last, err := lastScannedBlock(ctx)
+	if err == mongo.ErrNoDocuments {
+		// Nothing to catch up with on the first run
+		last = confirmed - 1
+	} else if err != nil {
+		return err
+	}
+	if last >= confirmed {
+		return nil
+	}
+
+	openOrders, err := openDepositOrders(ctx)
+	if err != nil {
+		return err
+	}
+
+	for number := last + 1; number <= confirmed; number++ {
+		if len(openOrders) > 0 {
+			if err := w.processBlock(ctx, number, openOrders); err != nil {
+				return fmt.Errorf("block %d: %v", number, err)
+			}
+		}
+		if err := saveScannedBlock(ctx, number); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// processBlock records every transfer in the block that pays an open order's deposit
+// address. An error leaves the block unscanned so that it is processed again; deposits
+// already recorded are recognised then.
+func (w *DepositWatcher) processBlock(ctx context.Context, number uint64, openOrders map[string]models.Order) error {
+	block, err := w.client.BlockByNumber(ctx, new(big.Int).SetUint64(number))
+	if err != nil {
+		return err
+	}
+
+	for _, tx := range block.Transactions() {
+		if tx.To() == nil || tx.Value().Sign() <= 0 {
+			continue
+		}
+
+		order, ok := openOrders[strings.ToLower(tx.To().Hex())]
+		if !ok {
+			continue
+		}
+
+		// A failed lookup is retried with the block; only a reverted transfer is skipped
+		receipt, err := w.client.TransactionReceipt(ctx, tx.Hash())
+		if err != nil {
+			return fmt.Errorf("receipt of %s: %v", tx.Hash().Hex(), err)
+		}
+		if receipt.Status != types.ReceiptStatusSuccessful {
+			continue
+		}
+
+		sender, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+		if err != nil {
+			log.Printf("⚠️ Failed to recover sender of %s: %v", tx.Hash().Hex(), err)
+			continue
+		}
+
+		if err := recordDeposit(ctx, order, tx, sender.Hex(), block); err != nil {
+			return fmt.Errorf("deposit %s: %v", tx.Hash().Hex(), err)
+		}
+	}
+
+	return nil
+}
+
+const depositWatcherState = "depositWatcher"
+
+// lastScannedBlock returns the height of the last block the deposit watcher scanned
+func lastScannedBlock(ctx context.Context) (uint64, error) {
+	var state struct {
+		Block int64 `bson:"block"`
+	}
+	err := database.DB.Collection("sync_state").FindOne(ctx, bson.M{"_id": depositWatcherState}).Decode(&state)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(state.Block), nil
+}
+
+func saveScannedBlock(ctx context.Context, number uint64) error {
+	_, err := database.DB.Collection("sync_state").UpdateOne(
+		ctx,
+		bson.M{"_id": depositWatcherState},
+		bson.M{"$set": bson.M{"block": int64(number), "updatedAt": time.Now()}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// openDepositOrders returns the orders whose deposit addresses are watched, keyed by
+// lower-cased address. Paid orders are included so that a late payment or overpayment
+// is credited and refunded rather than only swept.
+func openDepositOrders(ctx context.Context) (map[string]models.Order, error) {
+	cursor, err := database.DB.Collection("orders").Find(ctx, bson.M{
+		"depositAddress": bson.M{"$exists": true, "$ne": ""},
+		"status": bson.M{"$in": []models.OrderStatus{
+			models.OrderStatusPending,
+			models.OrderStatusPartiallyPaid,
+			models.OrderStatusPaid,
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var orders []models.Order
+	if err := cursor.All(ctx, &orders); err != nil {
+		return nil, err
+	}
+
+	byAddress := make(map[string]models.Order, len(orders))
+	for _, order := range orders {
+		byAddress[strings.ToLower(order.DepositAddress)] = order
+	}
+	return byAddress, nil
+}
+
+func recordDeposit(ctx context.Context, order models.Order, tx *types.Transaction, sender string, block *types.Block) error {
+	transaction := &models.Transaction{
+		OrderID:         order.OrderNumber,
+		CustomerAddress: sender,
+		Amount:          tx.Value().String(),
+		TxHash:          tx.Hash().Hex(),
+		BlockNumber:     block.NumberU64(),
+		BlockTime:       time.Unix(int64(block.Time()), 0),
+		Timestamp:       time.Now(),
+		Status:          "completed",
+	}
+
+	// Blocks can be delivered twice after a reconnect or reorg
+	transactionID, err := StoreTransaction(ctx, transaction)
+	if err != nil || transactionID == nil {
+		return err
+	}
+
+	log.Printf("📥 Deposit of %s Wei to %s for order %d", transaction.Amount, order.DepositAddress, order.OrderNumber)
+
+	status := "reconciled"
+	if err := ReconcilePayment(ctx, transaction); err != nil {
+		log.Printf("❌ Failed to reconcile order %d: %v", order.OrderNumber, err)
+		status = "unmatched"
+	}
+
+	_, err = database.DB.Collection("transactions").UpdateOne(ctx, bson.M{"_id": transactionID}, bson.M{"$set": bson.M{"status": status}})
+	return err
+}
+
+// SweepDeposits moves the funds held by paid orders' deposit addresses to the treasury.
+// Addresses are checked by balance on every run, so anything sent after an earlier
+// sweep is collected too.
+func SweepDeposits(ctx context.Context, client *ethclient.Client, accountKey *ExtendedKey, treasury string) error {
+	collection := database.DB.Collection("orders")
+	cursor, err := collection.Find(ctx, bson.M{
+		"depositAddress": bson.M{"$exists": true, "$ne": ""},
+		"status":         models.OrderStatusPaid,
+	})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var orders []models.Order
+	if err := cursor.All(ctx, &orders); err != nil {
+		return err
+	}
+
+	for _, order := range orders {
+		balance, err := client.BalanceAt(ctx, common.HexToAddress(order.DepositAddress), nil)
+		if err != nil {
+			log.Printf("❌ Failed to get deposit balance of order %d: %v", order.OrderNumber, err)
+			continue
+		}
+		if balance.Sign() == 0 {
+			continue
+		}
+
+		key, err := accountKey.Derive(depositChain, order.DepositIndex)
+		if err != nil {
+			log.Printf("❌ Failed to derive deposit key for order %d: %v", order.OrderNumber, err)
+			continue
+		}
+
+		privateKey, err := key.PrivateKey()
+		if err != nil {
+			return err
+		}
+
+		processor := NewPaymentProcessorWithKey(client, privateKey)
+		if !strings.EqualFold(processor.Address().Hex(), order.DepositAddress) {
+			log.Printf("❌ DEPOSIT_XPRV does not match deposit address of order %d", order.OrderNumber)
+			continue
+		}
+
+		signedTx, err := processor.Sweep(treasury)
+		if errors.Is(err, ErrBalanceBelowFee) {
+			continue
+		}
+		if err != nil {
+			log.Printf("❌ Failed to sweep order %d: %v", order.OrderNumber, err)
+			continue
+		}
+
+		log.Printf("🧹 Swept order %d deposit to treasury: %s", order.OrderNumber, signedTx.Hash().Hex())
+
+		_, err = collection.UpdateOne(
+			ctx,
+			bson.M{"_id": order.ID},
+			bson.M{"$set": bson.M{"sweepTxHash": signedTx.Hash().Hex(), "updatedAt": time.Now()}},
+		)
+		if err != nil {
+			log.Printf("❌ Failed to record sweep for order %d: %v", order.OrderNumber, err)
+		}
+	}
+
+	return nil
+}
+
+// StartSweeper periodically sweeps paid deposit addresses using DEPOSIT_XPRV
+func StartSweeper(interval time.Duration) error {
+	accountKey, err := ParseExtendedKey(config.GetEnv("DEPOSIT_XPRV", ""))
+	if err != nil {
+		return fmt.Errorf("invalid DEPOSIT_XPRV: %v", err)
+	}
+	if !accountKey.IsPrivate() {
+		return errors.New("DEPOSIT_XPRV must be a private extended key")
+	}
+
+	treasury := config.GetEnv("TREASURY_ADDRESS", "")
+	if treasury == "" {
+		return errors.New("TREASURY_ADDRESS is not configured")
+	}
+
+	client, err := ethclient.Dial(config.GetEnv("WEB3_RPC_URL", ""))
+	if err != nil {
+		return fmt.Errorf("failed to connect to Ethereum client: %v", err)
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			if err := SweepDeposits(ctx, client, accountKey, treasury); err != nil {
+				log.Printf("❌ Sweeper error: %v", err)
+			}
+			cancel()
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/config"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+)
+
+// EmailVerificationTTL is how long a verification link works, configurable with EMAIL_VERIFICATION_TTL
+func EmailVerificationTTL() time.Duration {
+	ttl, err := time.ParseDuration(config.GetEnv("EMAIL_VERIFICATION_TTL", "24h"))
+	if err != nil || ttl <= 0 {
+		return 24 * time.Hour
+	}
+	return ttl
+}
+
+// RequireVerifiedEmail reports whether unverified users are blocked from placing orders
+func RequireVerifiedEmail() bool {
+	return config.GetEnv("REQUIRE_VERIFIED_EMAIL", "false") == "true"
+}
+
+// SendVerificationEmail mails the user a fresh verification link
+func SendVerificationEmail(ctx context.Context, user models.User) error {
+	token, err := IssueUserToken(ctx, user.ID, models.UserTokenEmailVerification, user.Email, EmailVerificationTTL())
+	if err != nil {
+		return err
+	}
+
+	link := AppURL() + "/verify-email?token=" + url.QueryEscape(token)
+	return Mail().Send(ctx, Email{
+		To:      user.Email,
+		Subject: "Verify your 0xmart email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nConfirm your email address by opening the link below:\n\n%s\n\nThe link expires in %s. If you did not sign up for 0xmart, ignore this email.\n",
+			user.Name, link, EmailVerificationTTL(),
+		),
+	})
+}
@@ -0,0 +1,314 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/config"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const ensABI = `[
+	{"name":"resolver","type":"function","stateMutability":"view","inputs":[{"name":"node","type":"bytes32"}],"outputs":[{"name":"","type":"address"}]},
+	{"name":"addr","type":"function","stateMutability":"view","inputs":[{"name":"node","type":"bytes32"}],"outputs":[{"name":"","type":"address"}]},
+	{"name":"name","type":"function","stateMutability":"view","inputs":[{"name":"node","type":"bytes32"}],"outputs":[{"name":"","type":"string"}]}
+]`
+
+// Default ENS registry, deployed at the same address on mainnet and testnets
+const defaultENSRegistry = "0x00000000000C2E074eC69A0dFb2997BA6C7d2e1e"
+
+var (
+	ErrENSNotFound = errors.New("ENS name not found")
+
+	parsedENSABI = mustParseABI(ensABI)
+
+	ensResolver   ENSResolver
+	ensResolverMu sync.Mutex
+)
+
+// ENSResolver resolves ENS names to addresses and back
+type ENSResolver interface {
+	Resolve(ctx context.Context, name string) (common.Address, error)
+	LookupAddress(ctx context.Context, address common.Address) (string, error)
+}
+
+// ENS returns the shared resolver, creating a cached RPC resolver on first use
+func ENS() ENSResolver {
+	ensResolverMu.Lock()
+	defer ensResolverMu.Unlock()
+
+	if ensResolver == nil {
+		registry := common.HexToAddress(config.GetEnv("ENS_REGISTRY_ADDRESS", defaultENSRegistry))
+		ensResolver = NewCachedENSResolver(&rpcENSResolver{registry: registry}, LoadENSCacheConfig())
+	}
+	return ensResolver
+}
+
+// SetENSResolver replaces the shared resolver, e.g. with a stub
+func SetENSResolver(resolver ENSResolver) {
+	ensResolverMu.Lock()
+	defer ensResolverMu.Unlock()
+
+	ensResolver = resolver
+}
+
+// IsENSName reports whether value looks like an ENS name rather than a hex address
+func IsENSName(value string) bool {
+	return strings.Contains(value, ".") && !common.IsHexAddress(value)
+}
+
+// ResolveWalletInput accepts a hex address or an ENS name and returns the checksummed address
+func ResolveWalletInput(ctx context.Context, value string) (string, error) {
+	if common.IsHexAddress(value) {
+		return common.HexToAddress(value).Hex(), nil
+	}
+	if !IsENSName(value) {
+		return "", errors.New("invalid wallet address format")
+	}
+
+	address, err := ENS().Resolve(ctx, value)
+	if err != nil {
+		return "", err
+	}
+	return address.Hex(), nil
+}
+
+// NameHash implements the ENS namehash algorithm (EIP-137)
+func NameHash(name string) common.Hash {
+	var node common.Hash
+	if name == "" {
+		return node
+	}
+
+	labels := strings.Split(strings.ToLower(name), ".")
+	for i := len(labels) - 1; i >= 0; i-- {
+		labelHash := crypto.Keccak256([]byte(labels[i]))
+		node = common.BytesToHash(crypto.Keccak256(node[:], labelHash))
+	}
+	return node
+}
+
+// rpcENSResolver queries the ENS registry through WEB3_RPC_URL
+type rpcENSResolver struct {
+	registry common.Address
+}
+
+func (r *rpcENSResolver) Resolve(ctx context.Context, name string) (common.Address, error) {
+	node := NameHash(name)
+
+	resolver, err := r.resolverFor(ctx, node)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	var address common.Address
+	if err := r.call(ctx, resolver, "addr", node, &address); err != nil {
+		return common.Address{}, err
+	}
+	if address == (common.Address{}) {
+		return common.Address{}, ErrENSNotFound
+	}
+	return address, nil
+}
+
+// LookupAddress returns the primary name of an address, verified by resolving it forward
+func (r *rpcENSResolver) LookupAddress(ctx context.Context, address common.Address) (string, error) {
+	node := NameHash(strings.ToLower(address.Hex()[2:]) + ".addr.reverse")
+
+	resolver, err := r.resolverFor(ctx, node)
+	if err != nil {
+		return "", err
+	}
+
+	var name string
+	if err := r.call(ctx, resolver, "name", node, &name); err != nil {
+		return "", err
+	}
+	if name == "" {
+		return "", ErrENSNotFound
+	}
+
+	// Anyone can set a reverse record, so only trust names that point back to the address
+	forward, err := r.Resolve(ctx, name)
+	if err != nil || forward != address {
+		return "", ErrENSNotFound
+	}
+	return name, nil
+}
+
+func (r *rpcENSResolver) resolverFor(ctx context.Context, node common.Hash) (common.Address, error) {
+	var resolver common.Address
+	if err := r.call(ctx, r.registry, "resolver", node, &resolver); err != nil {
+		return common.Address{}, err
+	}
+	if resolver == (common.Address{}) {
+		return common.Address{}, ErrENSNotFound
+	}
+	return resolver, nil
+}
+
+func (r *rpcENSResolver) call(ctx context.Context, contract common.Address, method string, node common.Hash, result interface{}) error {
+	client, err := RPCClient()
+	if err != nil {
+		return err
+	}
+
+	data, err := parsedENSABI.Pack(method, node)
+	if err != nil {
+		return err
+	}
+
+	output, err := client.CallContract(ctx, ethereum.CallMsg{To: &contract, Data: data}, nil)
+	if err != nil {
+		return fmt.Errorf("ENS %s call failed: %v", method, err)
+	}
+	if len(output) == 0 {
+		return ErrENSNotFound
+	}
+
+	return parsedENSABI.UnpackIntoInterface(result, method, output)
+}
+
+// ENSCacheConfig bounds the lookups made through the shared resolver
+type ENSCacheConfig struct {
+	TTL        time.Duration // How long names, and names found missing, are kept
+	ErrorTTL   time.Duration // How long failed lookups are kept, so a down RPC isn't hit per request
+	Timeout    time.Duration // Limit on a single uncached lookup
+	MaxEntries int           // Entries kept per direction
+}
+
+// LoadENSCacheConfig reads the ENS cache settings from the environment
+func LoadENSCacheConfig() ENSCacheConfig {
+	maxEntries, err := strconv.Atoi(config.GetEnv("ENS_CACHE_MAX_ENTRIES", "10000"))
+	if err != nil || maxEntries <= 0 {
+		maxEntries = 10000
+	}
+
+	return ENSCacheConfig{
+		TTL:        ensDuration("ENS_CACHE_TTL", 15*time.Minute),
+		ErrorTTL:   ensDuration("ENS_ERROR_CACHE_TTL", time.Minute),
+		Timeout:    ensDuration("ENS_LOOKUP_TIMEOUT", 2*time.Second),
+		MaxEntries: maxEntries,
+	}
+}
+
+func ensDuration(key string, fallback time.Duration) time.Duration {
+	d, err := time.ParseDuration(config.GetEnv(key, fallback.String()))
+	if err != nil || d <= 0 {
+		return fallback
+	}
+	return d
+}
+
+type ensCacheEntry struct {
+	value   string
+	err     error
+	expires time.Time
+}
+
+// cachedENSResolver memoizes lookups, including misses and failures, in caches of
+// bounded size
+type cachedENSResolver struct {
+	inner   ENSResolver
+	config  ENSCacheConfig
+	mu      sync.Mutex
+	forward map[string]ensCacheEntry
+	reverse map[string]ensCacheEntry
+}
+
+func NewCachedENSResolver(inner ENSResolver, cfg ENSCacheConfig) ENSResolver {
+	return &cachedENSResolver{
+		inner:   inner,
+		config:  cfg,
+		forward: make(map[string]ensCacheEntry),
+		reverse: make(map[string]ensCacheEntry),
+	}
+}
+
+func (r *cachedENSResolver) Resolve(ctx context.Context, name string) (common.Address, error) {
+	key := strings.ToLower(name)
+	if entry, ok := r.get(r.forward, key); ok {
+		return common.HexToAddress(entry.value), entry.err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.config.Timeout)
+	defer cancel()
+
+	address, err := r.inner.Resolve(ctx, name)
+	r.put(r.forward, key, address.Hex(), err)
+	return address, err
+}
+
+func (r *cachedENSResolver) LookupAddress(ctx context.Context, address common.Address) (string, error) {
+	key := address.Hex()
+	if entry, ok := r.get(r.reverse, key); ok {
+		return entry.value, entry.err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.config.Timeout)
+	defer cancel()
+
+	name, err := r.inner.LookupAddress(ctx, address)
+	r.put(r.reverse, key, name, err)
+	return name, err
+}
+
+func (r *cachedENSResolver) get(cache map[string]ensCacheEntry, key string) (ensCacheEntry, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, ok := cache[key]
+	if !ok || !time.Now().Before(entry.expires) {
+		return ensCacheEntry{}, false
+	}
+	return entry, true
+}
+
+func (r *cachedENSResolver) put(cache map[string]ensCacheEntry, key, value string, err error) {
+	// A cancelled request says nothing about the name
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
+	ttl := r.config.TTL
+	if err != nil && !errors.Is(err, ErrENSNotFound) {
+		ttl = r.config.ErrorTTL
+	}
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := cache[key]; !ok && len(cache) >= r.config.MaxEntries {
+		evictENSEntry(cache, now)
+	}
+	cache[key] = ensCacheEntry{value: value, err: err, expires: now.Add(ttl)}
+}
+
+// evictENSEntry makes room in a full cache by dropping expired entries, or else the
+// entry closest to expiring
+func evictENSEntry(cache map[string]ensCacheEntry, now time.Time) {
+	var oldest string
+	var oldestExpiry time.Time
+	removed := false
+	for key, entry := range cache {
+		if !now.Before(entry.expires) {
+			delete(cache, key)
+			removed = true
+			continue
+		}
+		if oldest == "" || entry.expires.Before(oldestExpiry) {
+			oldest, oldestExpiry = key, entry.expires
+		}
+	}
+	if !removed && oldest != "" {
+		delete(cache, oldest)
+	}
+}
@@ -0,0 +1,186 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// stubENSResolver answers from fixed records and counts the lookups that reach it
+type stubENSResolver struct {
+	mu      sync.Mutex
+	names   map[string]common.Address
+	err     error
+	block   bool
+	lookups int
+}
+
+func (s *stubENSResolver) Resolve(ctx context.Context, name string) (common.Address, error) {
+	s.mu.Lock()
+	s.lookups++
+	s.mu.Unlock()
+
+	if s.block {
+		<-ctx.Done()
+		return common.Address{}, ctx.Err()
+	}
+	if s.err != nil {
+		return common.Address{}, s.err
+	}
+	address, ok := s.names[strings.ToLower(name)]
+	if !ok {
+		return common.Address{}, ErrENSNotFound
+	}
+	return address, nil
+}
+
+func (s *stubENSResolver) LookupAddress(ctx context.Context, address common.Address) (string, error) {
+	s.mu.Lock()
+	s.lookups++
+	s.mu.Unlock()
+
+	for name, a := range s.names {
+		if a == address {
+			return name, nil
+		}
+	}
+	return "", ErrENSNotFound
+}
+
+func (s *stubENSResolver) calls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lookups
+}
+
+var testENSAddress = common.HexToAddress("0x1111111111111111111111111111111111111111")
+
+func testENSCacheConfig() ENSCacheConfig {
+	return ENSCacheConfig{TTL: time.Hour, ErrorTTL: time.Hour, Timeout: time.Second, MaxEntries: 100}
+}
+
+func TestNameHash(t *testing.T) {
+	tests := map[string]string{
+		"":        "0x0000000000000000000000000000000000000000000000000000000000000000",
+		"eth":     "0x93cdeb708b7545dc668eb9280176169d1c33cfd8ed6f04690a0bcc88a93fc4ae",
+		"foo.eth": "0xde9b09fd7c5f901e23a3f19fecc54828e9c848539801e86591bd9801b019f84f",
+		"FOO.eth": "0xde9b09fd7c5f901e23a3f19fecc54828e9c848539801e86591bd9801b019f84f",
+	}
+	for name, want := range tests {
+		if got := NameHash(name).Hex(); got != want {
+			t.Errorf("NameHash(%q) = %s, want %s", name, got, want)
+		}
+	}
+}
+
+func TestResolveWalletInput(t *testing.T) {
+	stub := &stubENSResolver{names: map[string]common.Address{"alice.eth": testENSAddress}}
+	SetENSResolver(stub)
+	t.Cleanup(func() { SetENSResolver(nil) })
+
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{input: "0x1111111111111111111111111111111111111111", want: testENSAddress.Hex()},
+		{input: "alice.eth", want: testENSAddress.Hex()},
+		{input: "bob.eth", wantErr: true},
+		{input: "not-an-address", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		got, err := ResolveWalletInput(context.Background(), tc.input)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("ResolveWalletInput(%q) = %s, want an error", tc.input, got)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("ResolveWalletInput(%q) = %s, %v, want %s", tc.input, got, err, tc.want)
+		}
+	}
+}
+
+func TestCachedENSResolverCachesHitsAndMisses(t *testing.T) {
+	stub := &stubENSResolver{names: map[string]common.Address{"alice.eth": testENSAddress}}
+	resolver := NewCachedENSResolver(stub, testENSCacheConfig())
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if address, err := resolver.Resolve(ctx, "Alice.eth"); err != nil || address != testENSAddress {
+			t.Fatalf("Resolve = %s, %v", address.Hex(), err)
+		}
+		if _, err := resolver.Resolve(ctx, "bob.eth"); !errors.Is(err, ErrENSNotFound) {
+			t.Fatalf("Resolve(bob.eth) error = %v, want ErrENSNotFound", err)
+		}
+		if name, err := resolver.LookupAddress(ctx, testENSAddress); err != nil || name != "alice.eth" {
+			t.Fatalf("LookupAddress = %q, %v", name, err)
+		}
+	}
+
+	if got := stub.calls(); got != 3 {
+		t.Errorf("inner resolver called %d times, want 3", got)
+	}
+}
+
+func TestCachedENSResolverCachesFailuresBriefly(t *testing.T) {
+	stub := &stubENSResolver{err: errors.New("rpc down")}
+	config := testENSCacheConfig()
+	config.ErrorTTL = 20 * time.Millisecond
+	resolver := NewCachedENSResolver(stub, config)
+	ctx := context.Background()
+
+	resolver.Resolve(ctx, "alice.eth")
+	resolver.Resolve(ctx, "alice.eth")
+	if got := stub.calls(); got != 1 {
+		t.Fatalf("inner resolver called %d times while the failure was cached, want 1", got)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	resolver.Resolve(ctx, "alice.eth")
+	if got := stub.calls(); got != 2 {
+		t.Errorf("inner resolver called %d times after the failure expired, want 2", got)
+	}
+}
+
+func TestCachedENSResolverTimesOut(t *testing.T) {
+	stub := &stubENSResolver{block: true}
+	config := testENSCacheConfig()
+	config.Timeout = 10 * time.Millisecond
+	resolver := NewCachedENSResolver(stub, config)
+
+	start := time.Now()
+	_, err := resolver.Resolve(context.Background(), "slow.eth")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("lookup took %s", elapsed)
+	}
+}
+
+func TestCachedENSResolverIsBounded(t *testing.T) {
+	stub := &stubENSResolver{}
+	config := testENSCacheConfig()
+	config.MaxEntries = 2
+	resolver := NewCachedENSResolver(stub, config).(*cachedENSResolver)
+	ctx := context.Background()
+
+	for _, name := range []string{"a.eth", "b.eth", "c.eth", "d.eth"} {
+		resolver.Resolve(ctx, name)
+	}
+
+	if got := len(resolver.forward); got != 2 {
+		t.Errorf("cache holds %d entries, want 2", got)
+	}
+	if _, ok := resolver.forward["d.eth"]; !ok {
+		t.Error("latest lookup was not cached")
+	}
+}
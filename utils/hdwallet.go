@@ -0,0 +1,245 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// BIP-32 serialization versions for mainnet and testnet keys
+var (
+	versionXpub = []byte{0x04, 0x88, 0xB2, 0x1E}
+	versionXprv = []byte{0x04, 0x88, 0xAD, 0xE4}
+	versionTpub = []byte{0x04, 0x35, 0x87, 0xCF}
+	versionTprv = []byte{0x04, 0x35, 0x83, 0x94}
+)
+
+var ErrHardenedDerivation = errors.New("hardened derivation is not supported")
+
+// MaxChildIndex is the highest non-hardened child index
+const MaxChildIndex = 0x7FFFFFFF
+
+// ExtendedKey is a BIP-32 extended public or private key
+type ExtendedKey struct {
+	key       []byte // 33-byte compressed public key or 32-byte private key
+	chainCode []byte
+	depth     uint8
+	isPrivate bool
+}
+
+// ParseExtendedKey decodes a base58check encoded xpub/xprv (or tpub/tprv)
+func ParseExtendedKey(encoded string) (*ExtendedKey, error) {
+	data, err := base58CheckDecode(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != 78 {
+		return nil, fmt.Errorf("invalid extended key length: %d", len(data))
+	}
+
+	version := data[:4]
+	key := &ExtendedKey{
+		depth:     data[4],
+		chainCode: data[13:45],
+	}
+
+	switch {
+	case bytes.Equal(version, versionXpub), bytes.Equal(version, versionTpub):
+		key.key = data[45:78]
+		if _, err := crypto.DecompressPubkey(key.key); err != nil {
+			return nil, fmt.Errorf("invalid public key: %v", err)
+		}
+	case bytes.Equal(version, versionXprv), bytes.Equal(version, versionTprv):
+		if data[45] != 0 {
+			return nil, errors.New("invalid private key padding")
+		}
+		key.key = data[46:78]
+		key.isPrivate = true
+	default:
+		return nil, fmt.Errorf("unknown extended key version %x", version)
+	}
+
+	return key, nil
+}
+
+// IsPrivate reports whether the key can derive child private keys
+func (k *ExtendedKey) IsPrivate() bool {
+	return k.isPrivate
+}
+
+// Child derives the non-hardened child key at the given index
+func (k *ExtendedKey) Child(index uint32) (*ExtendedKey, error) {
+	if index > MaxChildIndex {
+		return nil, ErrHardenedDerivation
+	}
+
+	pubKey, err := k.compressedPublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, 37)
+	copy(data, pubKey)
+	binary.BigEndian.PutUint32(data[33:], index)
+
+	mac := hmac.New(sha512.New, k.chainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	curve := crypto.S256()
+	il := new(big.Int).SetBytes(sum[:32])
+	if il.Cmp(curve.Params().N) >= 0 {
+		return nil, errors.New("invalid child key, try the next index")
+	}
+
+	child := &ExtendedKey{
+		chainCode: sum[32:],
+		depth:     k.depth + 1,
+		isPrivate: k.isPrivate,
+	}
+
+	if k.isPrivate {
+		childKey := new(big.Int).Add(il, new(big.Int).SetBytes(k.key))
+		childKey.Mod(childKey, curve.Params().N)
+		if childKey.Sign() == 0 {
+			return nil, errors.New("invalid child key, try the next index")
+		}
+		child.key = common.LeftPadBytes(childKey.Bytes(), 32)
+		return child, nil
+	}
+
+	parent, err := crypto.DecompressPubkey(k.key)
+	if err != nil {
+		return nil, err
+	}
+
+	ilx, ily := curve.ScalarBaseMult(sum[:32])
+	x, y := curve.Add(ilx, ily, parent.X, parent.Y)
+	if x.Sign() == 0 && y.Sign() == 0 {
+		return nil, errors.New("invalid child key, try the next index")
+	}
+
+	child.key = crypto.CompressPubkey(&ecdsa.PublicKey{Curve: curve, X: x, Y: y})
+	return child, nil
+}
+
+// Derive walks a path of non-hardened child indexes
+func (k *ExtendedKey) Derive(path ...uint32) (*ExtendedKey, error) {
+	key := k
+	for _, index := range path {
+		var err error
+		if key, err = key.Child(index); err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+// Address returns the Ethereum address controlled by the key
+func (k *ExtendedKey) Address() (common.Address, error) {
+	pubKey, err := k.compressedPublicKey()
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	publicKey, err := crypto.DecompressPubkey(pubKey)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return crypto.PubkeyToAddress(*publicKey), nil
+}
+
+// PrivateKey returns the ECDSA private key of a private extended key
+func (k *ExtendedKey) PrivateKey() (*ecdsa.PrivateKey, error) {
+	if !k.isPrivate {
+		return nil, errors.New("extended key is public only")
+	}
+	return crypto.ToECDSA(k.key)
+}
+
+func (k *ExtendedKey) compressedPublicKey() ([]byte, error) {
+	if !k.isPrivate {
+		return k.key, nil
+	}
+
+	privateKey, err := crypto.ToECDSA(k.key)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.CompressPubkey(&privateKey.PublicKey), nil
+}
+
+// base58CheckEncode appends a 4-byte checksum to payload and encodes it as base58
+func base58CheckEncode(payload []byte) string {
+	first := sha256.Sum256(payload)
+	second := sha256.Sum256(first[:])
+	data := append(append([]byte{}, payload...), second[:4]...)
+
+	var encoded []byte
+	value := new(big.Int).SetBytes(data)
+	radix := big.NewInt(58)
+	digit := new(big.Int)
+	for value.Sign() > 0 {
+		value.DivMod(value, radix, digit)
+		encoded = append(encoded, base58Alphabet[digit.Int64()])
+	}
+
+	// Leading zero bytes are kept as leading '1's
+	for _, b := range data {
+		if b != 0 {
+			break
+		}
+		encoded = append(encoded, base58Alphabet[0])
+	}
+
+	for i, j := 0, len(encoded)-1; i < j; i, j = i+1, j-1 {
+		encoded[i], encoded[j] = encoded[j], encoded[i]
+	}
+	return string(encoded)
+}
+
+// base58CheckDecode decodes a base58 string and verifies its 4-byte checksum
+func base58CheckDecode(encoded string) ([]byte, error) {
+	value := new(big.Int)
+	radix := big.NewInt(58)
+	for _, r := range encoded {
+		digit := bytes.IndexRune([]byte(base58Alphabet), r)
+		if digit < 0 {
+			return nil, fmt.Errorf("invalid base58 character %q", r)
+		}
+		value.Mul(value, radix)
+		value.Add(value, big.NewInt(int64(digit)))
+	}
+
+	decoded := value.Bytes()
+	for _, r := range encoded {
+		if r != '1' {
+			break
+		}
+		decoded = append([]byte{0}, decoded...)
+	}
+
+	if len(decoded) < 4 {
+		return nil, errors.New("base58 data too short")
+	}
+
+	payload, checksum := decoded[:len(decoded)-4], decoded[len(decoded)-4:]
+	first := sha256.Sum256(payload)
+	second := sha256.Sum256(first[:])
+	if !bytes.Equal(second[:4], checksum) {
+		return nil, errors.New("invalid base58 checksum")
+	}
+
+	return payload, nil
+}
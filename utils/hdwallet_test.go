@@ -0,0 +1,192 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// Non-hardened steps of the BIP-32 test vectors. Each case starts from the published
+// key of the parent, since hardened steps cannot be derived here.
+var bip32Vectors = []struct {
+	name       string
+	parentPrv  string
+	parentPub  string
+	index      uint32
+	expectPrv  string
+	expectPub  string
+	expectPath string
+}{
+	{
+		name:       "vector 1 m/0H/1",
+		parentPrv:  "xprv9uHRZZhk6KAJC1avXpDAp4MDc3sQKNxDiPvvkX8Br5ngLNv1TxvUxt4cV1rGL5hj6KCesnDYUhd7oWgT11eZG7XnxHrnYeSvkzY7d2bhkJ7",
+		parentPub:  "xpub68Gmy5EdvgibQVfPdqkBBCHxA5htiqg55crXYuXoQRKfDBFA1WEjWgP6LHhwBZeNK1VTsfTFUHCdrfp1bgwQ9xv5ski8PX9rL2dZXvgGDnw",
+		index:      1,
+		expectPrv:  "xprv9wTYmMFdV23N2TdNG573QoEsfRrWKQgWeibmLntzniatZvR9BmLnvSxqu53Kw1UmYPxLgboyZQaXwTCg8MSY3H2EU4pWcQDnRnrVA1xe8fs",
+		expectPub:  "xpub6ASuArnXKPbfEwhqN6e3mwBcDTgzisQN1wXN9BJcM47sSikHjJf3UFHKkNAWbWMiGj7Wf5uMash7SyYq527Hqck2AxYysAA7xmALppuCkwQ",
+		expectPath: "m/0H/1",
+	},
+	{
+		name:       "vector 1 m/0H/1/2H/2",
+		parentPrv:  "xprv9z4pot5VBttmtdRTWfWQmoH1taj2axGVzFqSb8C9xaxKymcFzXBDptWmT7FwuEzG3ryjH4ktypQSAewRiNMjANTtpgP4mLTj34bhnZX7UiM",
+		parentPub:  "xpub6D4BDPcP2GT577Vvch3R8wDkScZWzQzMMUm3PWbmWvVJrZwQY4VUNgqFJPMM3No2dFDFGTsxxpG5uJh7n7epu4trkrX7x7DogT5Uv6fcLW5",
+		index:      2,
+		expectPrv:  "xprvA2JDeKCSNNZky6uBCviVfJSKyQ1mDYahRjijr5idH2WwLsEd4Hsb2Tyh8RfQMuPh7f7RtyzTtdrbdqqsunu5Mm3wDvUAKRHSC34sJ7in334",
+		expectPub:  "xpub6FHa3pjLCk84BayeJxFW2SP4XRrFd1JYnxeLeU8EqN3vDfZmbqBqaGJAyiLjTAwm6ZLRQUMv1ZACTj37sR62cfN7fe5JnJ7dh8zL4fiyLHV",
+		expectPath: "m/0H/1/2H/2",
+	},
+	{
+		name:       "vector 1 m/0H/1/2H/2/1000000000",
+		parentPrv:  "xprvA2JDeKCSNNZky6uBCviVfJSKyQ1mDYahRjijr5idH2WwLsEd4Hsb2Tyh8RfQMuPh7f7RtyzTtdrbdqqsunu5Mm3wDvUAKRHSC34sJ7in334",
+		parentPub:  "xpub6FHa3pjLCk84BayeJxFW2SP4XRrFd1JYnxeLeU8EqN3vDfZmbqBqaGJAyiLjTAwm6ZLRQUMv1ZACTj37sR62cfN7fe5JnJ7dh8zL4fiyLHV",
+		index:      1000000000,
+		expectPrv:  "xprvA41z7zogVVwxVSgdKUHDy1SKmdb533PjDz7J6N6mV6uS3ze1ai8FHa8kmHScGpWmj4WggLyQjgPie1rFSruoUihUZREPSL39UNdE3BBDu76",
+		expectPub:  "xpub6H1LXWLaKsWFhvm6RVpEL9P4KfRZSW7abD2ttkWP3SSQvnyA8FSVqNTEcYFgJS2UaFcxupHiYkro49S8yGasTvXEYBVPamhGW6cFJodrTHy",
+		expectPath: "m/0H/1/2H/2/1000000000",
+	},
+	{
+		name:       "vector 2 m/0",
+		parentPrv:  "xprv9s21ZrQH143K31xYSDQpPDxsXRTUcvj2iNHm5NUtrGiGG5e2DtALGdso3pGz6ssrdK4PFmM8NSpSBHNqPqm55Qn3LqFtT2emdEXVYsCzC2U",
+		parentPub:  "xpub661MyMwAqRbcFW31YEwpkMuc5THy2PSt5bDMsktWQcFF8syAmRUapSCGu8ED9W6oDMSgv6Zz8idoc4a6mr8BDzTJY47LJhkJ8UB7WEGuduB",
+		index:      0,
+		expectPrv:  "xprv9vHkqa6EV4sPZHYqZznhT2NPtPCjKuDKGY38FBWLvgaDx45zo9WQRUT3dKYnjwih2yJD9mkrocEZXo1ex8G81dwSM1fwqWpWkeS3v86pgKt",
+		expectPub:  "xpub69H7F5d8KSRgmmdJg2KhpAK8SR3DjMwAdkxj3ZuxV27CprR9LgpeyGmXUbC6wb7ERfvrnKZjXoUmmDznezpbZb7ap6r1D3tgFxHmwMkQTPH",
+		expectPath: "m/0",
+	},
+	{
+		name:       "vector 2 m/0/2147483647H/1",
+		parentPrv:  "xprv9wSp6B7kry3Vj9m1zSnLvN3xH8RdsPP1Mh7fAaR7aRLcQMKTR2vidYEeEg2mUCTAwCd6vnxVrcjfy2kRgVsFawNzmjuHc2YmYRmagcEPdU9",
+		parentPub:  "xpub6ASAVgeehLbnwdqV6UKMHVzgqAG8Gr6riv3Fxxpj8ksbH9ebxaEyBLZ85ySDhKiLDBrQSARLq1uNRts8RuJiHjaDMBU4Zn9h8LZNnBC5y4a",
+		index:      1,
+		expectPrv:  "xprv9zFnWC6h2cLgpmSA46vutJzBcfJ8yaJGg8cX1e5StJh45BBciYTRXSd25UEPVuesF9yog62tGAQtHjXajPPdbRCHuWS6T8XA2ECKADdw4Ef",
+		expectPub:  "xpub6DF8uhdarytz3FWdA8TvFSvvAh8dP3283MY7p2V4SeE2wyWmG5mg5EwVvmdMVCQcoNJxGoWaU9DCWh89LojfZ537wTfunKau47EL2dhHKon",
+		expectPath: "m/0/2147483647H/1",
+	},
+	{
+		name:       "vector 2 m/0/2147483647H/1/2147483646H/2",
+		parentPrv:  "xprvA1RpRA33e1JQ7ifknakTFpgNXPmW2YvmhqLQYMmrj4xJXXWYpDPS3xz7iAxn8L39njGVyuoseXzU6rcxFLJ8HFsTjSyQbLYnMpCqE2VbFWc",
+		parentPub:  "xpub6ERApfZwUNrhLCkDtcHTcxd75RbzS1ed54G1LkBUHQVHQKqhMkhgbmJbZRkrgZw4koxb5JaHWkY4ALHY2grBGRjaDMzQLcgJvLJuZZvRcEL",
+		index:      2,
+		expectPrv:  "xprvA2nrNbFZABcdryreWet9Ea4LvTJcGsqrMzxHx98MMrotbir7yrKCEXw7nadnHM8Dq38EGfSh6dqA9QWTyefMLEcBYJUuekgW4BYPJcr9E7j",
+		expectPub:  "xpub6FnCn6nSzZAw5Tw7cgR9bi15UV96gLZhjDstkXXxvCLsUXBGXPdSnLFbdpq8p9HmGsApME5hQTZ3emM2rnY5agb9rXpVGyy3bdW6EEgAtqt",
+		expectPath: "m/0/2147483647H/1/2147483646H/2",
+	},
+}
+
+func mustParseExtendedKey(t *testing.T, encoded string) *ExtendedKey {
+	t.Helper()
+
+	key, err := ParseExtendedKey(encoded)
+	if err != nil {
+		t.Fatalf("ParseExtendedKey(%s): %v", encoded, err)
+	}
+	return key
+}
+
+func assertSameKey(t *testing.T, got, want *ExtendedKey) {
+	t.Helper()
+
+	if got.isPrivate != want.isPrivate {
+		t.Fatalf("isPrivate = %v, want %v", got.isPrivate, want.isPrivate)
+	}
+	if !bytes.Equal(got.key, want.key) {
+		t.Errorf("key = %x, want %x", got.key, want.key)
+	}
+	if !bytes.Equal(got.chainCode, want.chainCode) {
+		t.Errorf("chain code = %x, want %x", got.chainCode, want.chainCode)
+	}
+	if got.depth != want.depth {
+		t.Errorf("depth = %d, want %d", got.depth, want.depth)
+	}
+}
+
+func TestExtendedKeyChildMatchesBIP32Vectors(t *testing.T) {
+	for _, tc := range bip32Vectors {
+		t.Run(tc.name, func(t *testing.T) {
+			prvChild, err := mustParseExtendedKey(t, tc.parentPrv).Child(tc.index)
+			if err != nil {
+				t.Fatalf("private Child(%d): %v", tc.index, err)
+			}
+			assertSameKey(t, prvChild, mustParseExtendedKey(t, tc.expectPrv))
+
+			pubChild, err := mustParseExtendedKey(t, tc.parentPub).Child(tc.index)
+			if err != nil {
+				t.Fatalf("public Child(%d): %v", tc.index, err)
+			}
+			assertSameKey(t, pubChild, mustParseExtendedKey(t, tc.expectPub))
+
+			// Both derivations must control the same address
+			prvAddress, err := prvChild.Address()
+			if err != nil {
+				t.Fatal(err)
+			}
+			pubAddress, err := pubChild.Address()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if prvAddress != pubAddress {
+				t.Errorf("%s: private address %s, public address %s", tc.expectPath, prvAddress.Hex(), pubAddress.Hex())
+			}
+		})
+	}
+}
+
+func TestExtendedKeyRejectsHardenedIndex(t *testing.T) {
+	key := mustParseExtendedKey(t, bip32Vectors[0].parentPub)
+
+	if _, err := key.Child(MaxChildIndex + 1); !errors.Is(err, ErrHardenedDerivation) {
+		t.Errorf("Child(2^31) error = %v, want ErrHardenedDerivation", err)
+	}
+	if _, err := key.Child(MaxChildIndex); err != nil {
+		t.Errorf("Child(2^31-1): %v", err)
+	}
+}
+
+func TestBase58CheckRoundTrip(t *testing.T) {
+	payloads := [][]byte{
+		{},
+		{0x00},
+		{0x00, 0x00, 0x01},
+		{0xff, 0xfe, 0xfd},
+		bytes.Repeat([]byte{0x5a}, 78),
+	}
+
+	for _, payload := range payloads {
+		encoded := base58CheckEncode(payload)
+		decoded, err := base58CheckDecode(encoded)
+		if err != nil {
+			t.Errorf("decode(%q): %v", encoded, err)
+			continue
+		}
+		if !bytes.Equal(decoded, payload) {
+			t.Errorf("round trip of %x gave %x", payload, decoded)
+		}
+	}
+
+	// Published keys must survive a decode and re-encode unchanged
+	for _, tc := range bip32Vectors {
+		for _, encoded := range []string{tc.parentPrv, tc.expectPub} {
+			data, err := base58CheckDecode(encoded)
+			if err != nil {
+				t.Fatalf("decode(%s): %v", encoded, err)
+			}
+			if got := base58CheckEncode(data); got != encoded {
+				t.Errorf("re-encoded %s as %s", encoded, got)
+			}
+		}
+	}
+}
+
+func TestBase58CheckDecodeRejectsBadChecksum(t *testing.T) {
+	encoded := []byte(bip32Vectors[0].parentPub)
+	last := len(encoded) - 1
+	if encoded[last] == 'w' {
+		encoded[last] = 'x'
+	} else {
+		encoded[last] = 'w'
+	}
+
+	if _, err := base58CheckDecode(string(encoded)); err == nil {
+		t.Error("expected a checksum error")
+	}
+}
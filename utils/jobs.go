@@ -0,0 +1,159 @@
+package utils
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/database"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	defaultJobAttempts = 8
+	jobLockDuration    = 5 * time.Minute
+)
+
+// JobHandler performs a job; returning an error schedules a retry
+type JobHandler func(ctx context.Context, job models.Job) error
+
+// EnqueueJob persists a job for the worker to pick up
+func EnqueueJob(ctx context.Context, jobType string, payload map[string]string) error {
+	job := models.Job{
+		ID:          primitive.NewObjectID(),
+		Type:        jobType,
+		Payload:     payload,
+		Status:      models.JobStatusPending,
+		MaxAttempts: defaultJobAttempts,
+		RunAt:       time.Now(),
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+
+	_, err := database.DB.Collection("jobs").InsertOne(ctx, job)
+	return err
+}
+
+// EnqueueJobOnce persists a job unless a job with the same key was queued before, so
+// callers retrying after a failure can queue it again safely
+func EnqueueJobOnce(ctx context.Context, key, jobType string, payload map[string]string) error {
+	job := models.Job{
+		ID:          primitive.NewObjectID(),
+		Type:        jobType,
+		Key:         key,
+		Payload:     payload,
+		Status:      models.JobStatusPending,
+		MaxAttempts: defaultJobAttempts,
+		RunAt:       time.Now(),
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+
+	_, err := database.DB.Collection("jobs").InsertOne(ctx, job)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	return err
+}
+
+// jobTimeout is how long a job of the given type may run
+func jobTimeout(jobType string) time.Duration {
+	if jobType == JobTypeMintReceipt {
+		return receiptJobTimeout()
+	}
+	return jobLockDuration
+}
+
+// RunJobWorker polls the job queue and dispatches jobs to their handlers
+func RunJobWorker(handlers map[string]JobHandler, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// Claimed jobs stay locked for as long as the slowest type may run
+	types := make([]string, 0, len(handlers))
+	lock := jobLockDuration
+	for jobType := range handlers {
+		types = append(types, jobType)
+		if timeout := jobTimeout(jobType); timeout > lock {
+			lock = timeout
+		}
+	}
+
+	for range ticker.C {
+		for {
+			job, err := claimJob(types, lock)
+			if err != nil {
+				if err != mongo.ErrNoDocuments {
+					log.Printf("❌ Failed to claim job: %v", err)
+				}
+				break
+			}
+
+			runJob(job, handlers[job.Type])
+		}
+	}
+}
+
+// claimJob locks the next due job, including jobs whose worker died mid-run
+func claimJob(types []string, lock time.Duration) (models.Job, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	filter := bson.M{
+		"type":  bson.M{"$in": types},
+		"runAt": bson.M{"$lte": now},
+		"$or": []bson.M{
+			{"status": models.JobStatusPending},
+			{"status": models.JobStatusRunning, "lockedUntil": bson.M{"$lt": now}},
+		},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"status":      models.JobStatusRunning,
+			"lockedUntil": now.Add(lock),
+			"updatedAt":   now,
+		},
+		"$inc": bson.M{"attempts": 1},
+	}
+
+	var job models.Job
+	err := database.DB.Collection("jobs").FindOneAndUpdate(
+		ctx,
+		filter,
+		update,
+		options.FindOneAndUpdate().SetSort(bson.M{"runAt": 1}).SetReturnDocument(options.After),
+	).Decode(&job)
+	return job, err
+}
+
+func runJob(job models.Job, handler JobHandler) {
+	ctx, cancel := context.WithTimeout(context.Background(), jobTimeout(job.Type))
+	defer cancel()
+
+	set := bson.M{"status": models.JobStatusDone, "updatedAt": time.Now()}
+
+	if err := handler(ctx, job); err != nil {
+		log.Printf("❌ Job %s (%s) attempt %d failed: %v", job.ID.Hex(), job.Type, job.Attempts, err)
+		set["lastError"] = err.Error()
+		set["status"] = models.JobStatusPending
+		// Exponential backoff: 1m, 2m, 4m, ...
+		set["runAt"] = time.Now().Add(time.Minute << uint(job.Attempts-1))
+		if job.Attempts >= job.MaxAttempts {
+			set["status"] = models.JobStatusFailed
+		}
+	}
+
+	_, err := database.DB.Collection("jobs").UpdateOne(
+		context.Background(),
+		bson.M{"_id": job.ID},
+		bson.M{"$set": set},
+	)
+	if err != nil {
+		log.Printf("❌ Failed to update job %s: %v", job.ID.Hex(), err)
+	}
+}
@@ -8,27 +8,42 @@ import (
 )
 
 type Claims struct {
-	UserID string `json:"userId"`
+	UserID    string   `json:"userId"`
+	SessionID string   `json:"sid"`
+	Roles     []string `json:"roles,omitempty"`
+	MFA       bool     `json:"mfa,omitempty"` // Set when the login passed two-factor authentication
 	jwt.StandardClaims
 }
 
-func GenerateJWT(userID string) (string, error) {
+// AccessTokenTTL is the lifetime of access tokens, configurable with ACCESS_TOKEN_TTL
+func AccessTokenTTL() time.Duration {
+	ttl, err := time.ParseDuration(config.GetEnv("ACCESS_TOKEN_TTL", "15m"))
+	if err != nil || ttl <= 0 {
+		return 15 * time.Minute
+	}
+	return ttl
+}
+
+// GenerateJWT issues a short-lived access token for the user
+func GenerateJWT(userID, sessionID string, roles []string, mfa bool) (string, error) {
+	now := time.Now()
 	claims := &Claims{
-		UserID: userID,
+		UserID:    userID,
+		SessionID: sessionID,
+		Roles:     roles,
+		MFA:       mfa,
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(24 * time.Hour).Unix(),
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(AccessTokenTTL()).Unix(),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(config.GetEnv("JWT_SECRET", "")))
+	return signToken(claims)
 }
 
 func ValidateJWT(tokenString string) (*Claims, error) {
 	claims := &Claims{}
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(config.GetEnv("JWT_SECRET", "")), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, claims, verificationKey)
 
 	if err != nil {
 		return nil, err
@@ -38,5 +53,10 @@ func ValidateJWT(tokenString string) (*Claims, error) {
 		return nil, jwt.ErrSignatureInvalid
 	}
 
+	// Access tokens have no audience; other tokens signed with the same keys do
+	if claims.Audience != "" {
+		return nil, jwt.ErrSignatureInvalid
+	}
+
 	return claims, nil
 }
@@ -0,0 +1,365 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/config"
+	"github.com/golang-jwt/jwt"
+)
+
+// signingKey is an Ed25519 key identified by its file name (the kid)
+type signingKey struct {
+	kid        string
+	createdAt  time.Time
+	privateKey ed25519.PrivateKey // nil for verification-only keys
+	publicKey  ed25519.PublicKey
+}
+
+// keyStore holds the active signing key and every key still accepted for verification
+type keyStore struct {
+	mu       sync.RWMutex
+	dir      string
+	active   *signingKey
+	keys     map[string]*signingKey
+	loadedAt time.Time
+}
+
+// unknownKidReloadInterval limits directory reloads triggered by tokens signed with
+// a key this replica has not seen yet, e.g. one another replica just generated
+const unknownKidReloadInterval = 10 * time.Second
+
+// JWK is a public key in JSON Web Key format
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+}
+
+var jwtKeys = &keyStore{keys: make(map[string]*signingKey)}
+
+// InitKeyStore loads JWT keys from JWT_KEY_DIR. Private keys are stored as
+// <kid>.pem (PKCS#8) and verification-only keys as <kid>.pub.pem (PKIX).
+// It fails when no signing key is available, so the server never issues
+// tokens without a key. Replicas share the directory; a token signed with a
+// kid this replica has not loaded yet makes it reread the directory.
+func InitKeyStore() error {
+	dir := config.GetEnv("JWT_KEY_DIR", "")
+	if dir == "" {
+		return errors.New("JWT_KEY_DIR is not configured")
+	}
+
+	jwtKeys.mu.Lock()
+	jwtKeys.dir = dir
+	jwtKeys.mu.Unlock()
+
+	if err := jwtKeys.load(); err != nil {
+		return err
+	}
+
+	if jwtKeys.activeKey() == nil {
+		if !keyAutoGenerate() {
+			return fmt.Errorf("no JWT signing key found in %s", dir)
+		}
+		if err := jwtKeys.generate(); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("🔑 JWT signing key %s loaded", jwtKeys.activeKey().kid)
+	return nil
+}
+
+// StartKeyRotation reloads the key directory periodically and, when
+// JWT_KEY_AUTO_GENERATE is enabled, creates a new signing key once the
+// active one is older than JWT_KEY_ROTATION_INTERVAL
+func StartKeyRotation(checkInterval time.Duration) {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := jwtKeys.load(); err != nil {
+			log.Printf("❌ Failed to reload JWT keys: %v", err)
+			continue
+		}
+
+		active := jwtKeys.activeKey()
+		if keyAutoGenerate() && (active == nil || time.Since(active.createdAt) > keyRotationInterval()) {
+			if err := jwtKeys.generate(); err != nil {
+				log.Printf("❌ Failed to rotate JWT signing key: %v", err)
+				continue
+			}
+			log.Printf("🔄 Rotated JWT signing key to %s", jwtKeys.activeKey().kid)
+		}
+	}
+}
+
+// JWKS returns the public verification keys
+func JWKS() []JWK {
+	jwtKeys.mu.RLock()
+	defer jwtKeys.mu.RUnlock()
+
+	keys := make([]JWK, 0, len(jwtKeys.keys))
+	for _, key := range jwtKeys.sortedKeys() {
+		keys = append(keys, JWK{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(key.publicKey),
+			Kid: key.kid,
+			Alg: jwt.SigningMethodEdDSA.Alg(),
+			Use: "sig",
+		})
+	}
+	return keys
+}
+
+// signToken signs claims with the active key and sets the kid header
+func signToken(claims jwt.Claims) (string, error) {
+	active := jwtKeys.activeKey()
+	if active == nil {
+		return "", errors.New("no JWT signing key loaded")
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = active.kid
+	return token.SignedString(active.privateKey)
+}
+
+// verificationKey resolves the public key named by the token's kid header
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodEd25519); !ok {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	kid, _ := token.Header["kid"].(string)
+
+	key, ok := jwtKeys.key(kid)
+	if !ok && jwtKeys.reloadForUnknownKid() {
+		key, ok = jwtKeys.key(kid)
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key.publicKey, nil
+}
+
+func (s *keyStore) key(kid string) (*signingKey, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, ok := s.keys[kid]
+	return key, ok
+}
+
+// reloadForUnknownKid rereads the key directory unless it was read very recently,
+// and reports whether it did
+func (s *keyStore) reloadForUnknownKid() bool {
+	s.mu.Lock()
+	if time.Since(s.loadedAt) < unknownKidReloadInterval {
+		s.mu.Unlock()
+		return false
+	}
+	// Claimed up front so concurrent requests with the same kid reload once
+	s.loadedAt = time.Now()
+	s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		log.Printf("❌ Failed to reload JWT keys: %v", err)
+		return false
+	}
+	return true
+}
+
+func (s *keyStore) activeKey() *signingKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.active
+}
+
+// sortedKeys returns keys newest first; callers must hold the lock
+func (s *keyStore) sortedKeys() []*signingKey {
+	keys := make([]*signingKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].createdAt.After(keys[j].createdAt) })
+	return keys
+}
+
+// load reads the key directory and picks the newest private key as the active one
+func (s *keyStore) load() error {
+	s.mu.RLock()
+	dir := s.dir
+	s.mu.RUnlock()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read JWT_KEY_DIR: %v", err)
+	}
+
+	keys := make(map[string]*signingKey)
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".pem") {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		key, err := readKeyFile(filepath.Join(dir, name))
+		if err != nil {
+			return fmt.Errorf("failed to load %s: %v", name, err)
+		}
+		key.createdAt = keyCreatedAt(key.kid, info.ModTime())
+
+		// A private key supersedes a public-only copy of the same kid
+		if existing, ok := keys[key.kid]; ok && existing.privateKey != nil {
+			continue
+		}
+		keys[key.kid] = key
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys = keys
+	s.loadedAt = time.Now()
+	s.active = nil
+	for _, key := range s.sortedKeys() {
+		if key.privateKey != nil {
+			s.active = key
+			break
+		}
+	}
+
+	// Keys superseded by the active key stop being accepted once every token they signed has expired
+	if s.active != nil && time.Since(s.active.createdAt) > keyRetention() {
+		for kid, key := range s.keys {
+			if key.createdAt.Before(s.active.createdAt) {
+				delete(s.keys, kid)
+			}
+		}
+	}
+	return nil
+}
+
+// generate writes a new Ed25519 private key to the key directory and activates it
+func (s *keyStore) generate() error {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return err
+	}
+
+	// The random suffix keeps replicas rotating at the same moment from overwriting each other's key
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	kid := now.UTC().Format(kidTimeLayout) + "-" + hex.EncodeToString(suffix)
+	key := &signingKey{kid: kid, createdAt: now, privateKey: privateKey, publicKey: publicKey}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := filepath.Join(s.dir, kid+".pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		return err
+	}
+
+	s.keys[kid] = key
+	s.active = key
+	return nil
+}
+
+// kidTimeLayout dates a key in its kid, as in 20250101T000000Z or 20250101T000000Z-1a2b3c4d
+const kidTimeLayout = "20060102T150405Z"
+
+// keyCreatedAt reads a key's creation time from its kid. File times change when keys
+// are copied, restored or mounted, so they are only used for keys named otherwise.
+func keyCreatedAt(kid string, modTime time.Time) time.Time {
+	stamp, _, _ := strings.Cut(kid, "-")
+	if created, err := time.Parse(kidTimeLayout, stamp); err == nil {
+		return created
+	}
+	return modTime
+}
+
+func readKeyFile(path string) (*signingKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	name := filepath.Base(path)
+	if strings.HasSuffix(name, ".pub.pem") {
+		publicKey, err := jwt.ParseEdPublicKeyFromPEM(data)
+		if err != nil {
+			return nil, err
+		}
+		edKey, ok := publicKey.(ed25519.PublicKey)
+		if !ok {
+			return nil, errors.New("not an Ed25519 public key")
+		}
+		return &signingKey{kid: strings.TrimSuffix(name, ".pub.pem"), publicKey: edKey}, nil
+	}
+
+	privateKey, err := jwt.ParseEdPrivateKeyFromPEM(data)
+	if err != nil {
+		return nil, err
+	}
+	edKey, ok := privateKey.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("not an Ed25519 private key")
+	}
+	return &signingKey{
+		kid:        strings.TrimSuffix(name, ".pem"),
+		privateKey: edKey,
+		publicKey:  edKey.Public().(ed25519.PublicKey),
+	}, nil
+}
+
+func keyAutoGenerate() bool {
+	return config.GetEnv("JWT_KEY_AUTO_GENERATE", "false") == "true"
+}
+
+func keyRotationInterval() time.Duration {
+	interval, err := time.ParseDuration(config.GetEnv("JWT_KEY_ROTATION_INTERVAL", "720h"))
+	if err != nil || interval <= 0 {
+		return 30 * 24 * time.Hour
+	}
+	return interval
+}
+
+// keyRetention is how long superseded keys stay valid after a rotation: the
+// lifetime of the last tokens they signed plus some clock skew
+func keyRetention() time.Duration {
+	return AccessTokenTTL() + time.Hour
+}
@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestKeyCreatedAtPrefersKidTimestamp(t *testing.T) {
+	modTime := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	stamped := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := map[string]time.Time{
+		"20250102T030405Z-1a2b3c4d": stamped,
+		"20250102T030405Z":          stamped,
+		"legacy-key":                modTime,
+		"2025-01-02":                modTime,
+	}
+	for kid, want := range tests {
+		if got := keyCreatedAt(kid, modTime); !got.Equal(want) {
+			t.Errorf("keyCreatedAt(%q) = %s, want %s", kid, got, want)
+		}
+	}
+}
+
+// writeTestKey writes a private key file and gives it the modification time given
+func writeTestKey(t *testing.T, dir, kid string, modTime time.Time) {
+	t.Helper()
+
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, kid+".pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKeyStoreIgnoresCopiedFileTimes(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+
+	// As after restoring a backup: the older key's file looks newest
+	older := now.Add(-48*time.Hour).UTC().Format(kidTimeLayout) + "-00000001"
+	newer := now.Add(-time.Minute).UTC().Format(kidTimeLayout) + "-00000002"
+	writeTestKey(t, dir, older, now)
+	writeTestKey(t, dir, newer, now.Add(-72*time.Hour))
+
+	store := &keyStore{dir: dir}
+	if err := store.load(); err != nil {
+		t.Fatal(err)
+	}
+
+	if active := store.activeKey(); active == nil || active.kid != newer {
+		t.Fatalf("active key = %v, want %s", active, newer)
+	}
+	if _, ok := store.key(older); !ok {
+		t.Error("older key dropped while tokens it signed may still be valid")
+	}
+}
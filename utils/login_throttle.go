@@ -0,0 +1,444 @@
+package utils
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/config"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/database"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// Failures allowed before each further attempt has to wait
+	loginDelayAfter = 3
+	maxLoginDelay   = 30 * time.Second
+)
+
+// LoginAttempts is the failed-login state of one IP or account
+type LoginAttempts struct {
+	Failures    int       `bson:"failures"`
+	LastFailure time.Time `bson:"lastFailure"`
+	LockedUntil time.Time `bson:"lockedUntil"`
+}
+
+// LoginAttemptStore keeps failed-login counters. The in-memory store only sees
+// attempts made against this replica; use the Mongo store when running several.
+type LoginAttemptStore interface {
+	Get(ctx context.Context, key string) (LoginAttempts, error)
+	// RecordFailure counts a failure, starting over when the last one is older than window
+	RecordFailure(ctx context.Context, key string, window time.Duration) (LoginAttempts, error)
+	Lock(ctx context.Context, key string, until time.Time) error
+	Reset(ctx context.Context, key string) error
+}
+
+var (
+	loginAttemptStore   LoginAttemptStore
+	loginAttemptStoreMu sync.Mutex
+)
+
+// LoginAttemptsStore returns the shared store, selected with LOGIN_THROTTLE_STORE (memory or mongo)
+func LoginAttemptsStore() LoginAttemptStore {
+	loginAttemptStoreMu.Lock()
+	defer loginAttemptStoreMu.Unlock()
+
+	if loginAttemptStore == nil {
+		if config.GetEnv("LOGIN_THROTTLE_STORE", "memory") == "mongo" {
+			loginAttemptStore = &mongoLoginAttemptStore{}
+		} else {
+			loginAttemptStore = NewMemoryLoginAttemptStore()
+		}
+	}
+	return loginAttemptStore
+}
+
+// SetLoginAttemptStore replaces the shared store
+func SetLoginAttemptStore(store LoginAttemptStore) {
+	loginAttemptStoreMu.Lock()
+	defer loginAttemptStoreMu.Unlock()
+
+	loginAttemptStore = store
+}
+
+// CheckLogin returns how long the client must wait before its next login attempt;
+// zero means the attempt may go ahead
+func CheckLogin(ctx context.Context, ip, email string) (time.Duration, error) {
+	var wait time.Duration
+
+	for _, key := range []string{ipLoginKey(ip), accountLoginKey(email)} {
+		attempts, err := LoginAttemptsStore().Get(ctx, key)
+		if err != nil {
+			return 0, err
+		}
+		if w := attempts.wait(time.Now()); w > wait {
+			wait = w
+		}
+	}
+	return wait, nil
+}
+
+// RecordLoginFailure counts a failed attempt against the IP and the account and
+// locks whichever has reached its limit
+func RecordLoginFailure(ctx context.Context, ip, email string) error {
+	limits := []struct {
+		key         string
+		scope       string
+		maxFailures int
+	}{
+		{ipLoginKey(ip), "ip", loginMaxFailures("LOGIN_IP_MAX_FAILURES", 20)},
+		{accountLoginKey(email), "account", loginMaxFailures("LOGIN_MAX_FAILURES", 5)},
+	}
+
+	now := time.Now()
+	for _, limit := range limits {
+		attempts, err := LoginAttemptsStore().RecordFailure(ctx, limit.key, loginFailureWindow())
+		if err != nil {
+			return err
+		}
+		if attempts.Failures < limit.maxFailures || attempts.LockedUntil.After(now) {
+			continue
+		}
+
+		lockedUntil := now.Add(loginLockoutDuration())
+		if err := LoginAttemptsStore().Lock(ctx, limit.key, lockedUntil); err != nil {
+			return err
+		}
+		recordLoginLockout(ctx, limit.scope, ip, email, attempts.Failures, lockedUntil)
+	}
+	return nil
+}
+
+// RecordLoginSuccess clears the account's failures. The IP counter is left alone so
+// one valid account cannot be used to keep guessing others from the same address.
+func RecordLoginSuccess(ctx context.Context, email string) error {
+	return LoginAttemptsStore().Reset(ctx, accountLoginKey(email))
+}
+
+// ForgetLoginHistory removes the failure counters and lockout records kept for a user,
+// for when the account is deleted
+func ForgetLoginHistory(ctx context.Context, email, userID string) error {
+	for _, key := range []string{accountLoginKey(email), twoFactorKey(userID)} {
+		if err := LoginAttemptsStore().Reset(ctx, key); err != nil {
+			return err
+		}
+	}
+
+	_, err := database.DB.Collection("login_lockouts").DeleteMany(ctx, loginLockoutFilter(email, userID))
+	return err
+}
+
+// loginLockoutFilter matches the lockouts recorded against the user's account or
+// two-factor checks
+func loginLockoutFilter(email, userID string) bson.M {
+	return bson.M{"$or": []bson.M{
+		{"scope": "account", "email": strings.ToLower(strings.TrimSpace(email))},
+		{"scope": "user", "userId": userID},
+	}}
+}
+
+// CheckTwoFactorAttempts returns how long a signed-in user must wait before another
+// two-factor code is checked, so an access token alone cannot be used to guess codes
+func CheckTwoFactorAttempts(ctx context.Context, userID string) (time.Duration, error) {
+	attempts, err := LoginAttemptsStore().Get(ctx, twoFactorKey(userID))
+	if err != nil {
+		return 0, err
+	}
+	return attempts.wait(time.Now()), nil
+}
+
+// RecordTwoFactorFailure counts a wrong code from a signed-in user and locks further
+// checks under the same limit as account logins
+func RecordTwoFactorFailure(ctx context.Context, ip, userID string) error {
+	attempts, err := LoginAttemptsStore().RecordFailure(ctx, twoFactorKey(userID), loginFailureWindow())
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if attempts.Failures < loginMaxFailures("LOGIN_MAX_FAILURES", 5) || attempts.LockedUntil.After(now) {
+		return nil
+	}
+
+	lockedUntil := now.Add(loginLockoutDuration())
+	if err := LoginAttemptsStore().Lock(ctx, twoFactorKey(userID), lockedUntil); err != nil {
+		return err
+	}
+	recordTwoFactorLockout(ctx, ip, userID, attempts.Failures, lockedUntil)
+	return nil
+}
+
+// RecordTwoFactorSuccess clears the user's failed code checks
+func RecordTwoFactorSuccess(ctx context.Context, userID string) error {
+	return LoginAttemptsStore().Reset(ctx, twoFactorKey(userID))
+}
+
+// wait is the remaining lockout, or the progressive delay after repeated failures
+func (a LoginAttempts) wait(now time.Time) time.Duration {
+	if a.LockedUntil.After(now) {
+		return a.LockedUntil.Sub(now)
+	}
+	if a.Failures < loginDelayAfter || now.Sub(a.LastFailure) > loginFailureWindow() {
+		return 0
+	}
+
+	delay := time.Second << uint(a.Failures-loginDelayAfter)
+	if delay > maxLoginDelay || delay <= 0 {
+		delay = maxLoginDelay
+	}
+	return a.LastFailure.Add(delay).Sub(now)
+}
+
+func recordLoginLockout(ctx context.Context, scope, ip, email string, failures int, lockedUntil time.Time) {
+	log.Printf("🔒 Login locked for %s %s until %s after %d failures", scope, lockoutSubject(scope, ip, email), lockedUntil.Format(time.RFC3339), failures)
+
+	_, err := database.DB.Collection("login_lockouts").InsertOne(ctx, models.LoginLockout{
+		ID:          primitive.NewObjectID(),
+		Scope:       scope,
+		IP:          ip,
+		Email:       strings.ToLower(email),
+		Failures:    failures,
+		LockedUntil: lockedUntil,
+		CreatedAt:   time.Now(),
+	})
+	if err != nil {
+		log.Printf("❌ Failed to record login lockout: %v", err)
+	}
+
+	targetType, targetID := models.AuditTargetIP, ip
+	if scope == "account" {
+		targetType, targetID = models.AuditTargetAccount, strings.ToLower(email)
+	}
+	RecordAudit(models.AuditEntry{
+		ActorType:  models.AuditActorSystem,
+		Action:     models.AuditLoginLocked,
+		TargetType: targetType,
+		TargetID:   targetID,
+		IP:         ip,
+		Metadata: map[string]string{
+			"failures":    strconv.Itoa(failures),
+			"lockedUntil": lockedUntil.UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+func recordTwoFactorLockout(ctx context.Context, ip, userID string, failures int, lockedUntil time.Time) {
+	log.Printf("🔒 Two-factor checks locked for user %s until %s after %d failures", userID, lockedUntil.Format(time.RFC3339), failures)
+
+	_, err := database.DB.Collection("login_lockouts").InsertOne(ctx, models.LoginLockout{
+		ID:          primitive.NewObjectID(),
+		Scope:       "user",
+		IP:          ip,
+		UserID:      userID,
+		Failures:    failures,
+		LockedUntil: lockedUntil,
+		CreatedAt:   time.Now(),
+	})
+	if err != nil {
+		log.Printf("❌ Failed to record two-factor lockout: %v", err)
+	}
+
+	RecordAudit(models.AuditEntry{
+		ActorType:  models.AuditActorSystem,
+		Action:     models.AuditLoginLocked,
+		TargetType: models.AuditTargetUser,
+		TargetID:   userID,
+		IP:         ip,
+		Metadata: map[string]string{
+			"failures":    strconv.Itoa(failures),
+			"lockedUntil": lockedUntil.UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+func lockoutSubject(scope, ip, email string) string {
+	if scope == "ip" {
+		return ip
+	}
+	return email
+}
+
+func ipLoginKey(ip string) string {
+	return "ip:" + ip
+}
+
+func twoFactorKey(userID string) string {
+	return "2fa:" + userID
+}
+
+func accountLoginKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func loginMaxFailures(key string, fallback int) int {
+	n, err := strconv.Atoi(config.GetEnv(key, strconv.Itoa(fallback)))
+	if err != nil || n <= 0 {
+		return fallback
+	}
+	return n
+}
+
+func loginFailureWindow() time.Duration {
+	window, err := time.ParseDuration(config.GetEnv("LOGIN_FAILURE_WINDOW", "15m"))
+	if err != nil || window <= 0 {
+		return 15 * time.Minute
+	}
+	return window
+}
+
+func loginLockoutDuration() time.Duration {
+	duration, err := time.ParseDuration(config.GetEnv("LOGIN_LOCKOUT_DURATION", "15m"))
+	if err != nil || duration <= 0 {
+		return 15 * time.Minute
+	}
+	return duration
+}
+
+type memoryLoginAttemptStore struct {
+	mu        sync.Mutex
+	attempts  map[string]LoginAttempts
+	lastPrune time.Time
+}
+
+func NewMemoryLoginAttemptStore() LoginAttemptStore {
+	return &memoryLoginAttemptStore{attempts: make(map[string]LoginAttempts), lastPrune: time.Now()}
+}
+
+func (s *memoryLoginAttemptStore) Get(ctx context.Context, key string) (LoginAttempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.attempts[key], nil
+}
+
+func (s *memoryLoginAttemptStore) RecordFailure(ctx context.Context, key string, window time.Duration) (LoginAttempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.prune(now, window)
+
+	attempts := s.attempts[key]
+	if now.Sub(attempts.LastFailure) > window {
+		attempts.Failures = 0
+	}
+	attempts.Failures++
+	attempts.LastFailure = now
+	s.attempts[key] = attempts
+	return attempts, nil
+}
+
+func (s *memoryLoginAttemptStore) Lock(ctx context.Context, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempts := s.attempts[key]
+	attempts.LockedUntil = until
+	s.attempts[key] = attempts
+	return nil
+}
+
+func (s *memoryLoginAttemptStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.attempts, key)
+	return nil
+}
+
+// prune drops entries that no longer delay or lock anyone; callers must hold the lock
+func (s *memoryLoginAttemptStore) prune(now time.Time, window time.Duration) {
+	if now.Sub(s.lastPrune) < window {
+		return
+	}
+	s.lastPrune = now
+
+	for key, attempts := range s.attempts {
+		if now.Sub(attempts.LastFailure) > window && !attempts.LockedUntil.After(now) {
+			delete(s.attempts, key)
+		}
+	}
+}
+
+// mongoLoginAttemptStore shares counters between replicas in the login_attempts collection.
+// Each entry carries an expiresAt, pushed out by failures and locks, after which it no
+// longer delays or locks anyone and the TTL index removes it.
+type mongoLoginAttemptStore struct{}
+
+// EnsureLoginAttemptIndexes creates the TTL index that clears out stale login counters
+func EnsureLoginAttemptIndexes(ctx context.Context) error {
+	_, err := database.DB.Collection("login_attempts").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Entries written before expiresAt existed are kept for as long as any of them could matter
+	_, err = database.DB.Collection("login_attempts").UpdateMany(
+		ctx,
+		bson.M{"expiresAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"expiresAt": time.Now().Add(loginFailureWindow() + loginLockoutDuration())}},
+	)
+	return err
+}
+
+func (s *mongoLoginAttemptStore) Get(ctx context.Context, key string) (LoginAttempts, error) {
+	var attempts LoginAttempts
+	err := database.DB.Collection("login_attempts").FindOne(ctx, bson.M{"_id": key}).Decode(&attempts)
+	if err == mongo.ErrNoDocuments {
+		return LoginAttempts{}, nil
+	}
+	return attempts, err
+}
+
+func (s *mongoLoginAttemptStore) RecordFailure(ctx context.Context, key string, window time.Duration) (LoginAttempts, error) {
+	collection := database.DB.Collection("login_attempts")
+	now := time.Now()
+
+	// Start the count over when the previous failure is outside the window
+	_, err := collection.UpdateOne(
+		ctx,
+		bson.M{"_id": key, "lastFailure": bson.M{"$lt": now.Add(-window)}},
+		bson.M{"$set": bson.M{"failures": 0}},
+	)
+	if err != nil {
+		return LoginAttempts{}, err
+	}
+
+	var attempts LoginAttempts
+	err = collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": key},
+		bson.M{
+			"$inc": bson.M{"failures": 1},
+			"$set": bson.M{"lastFailure": now},
+			"$max": bson.M{"expiresAt": now.Add(window)},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&attempts)
+	return attempts, err
+}
+
+func (s *mongoLoginAttemptStore) Lock(ctx context.Context, key string, until time.Time) error {
+	_, err := database.DB.Collection("login_attempts").UpdateOne(
+		ctx,
+		bson.M{"_id": key},
+		bson.M{"$set": bson.M{"lockedUntil": until}, "$max": bson.M{"expiresAt": until}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+func (s *mongoLoginAttemptStore) Reset(ctx context.Context, key string) error {
+	_, err := database.DB.Collection("login_attempts").DeleteOne(ctx, bson.M{"_id": key})
+	return err
+}
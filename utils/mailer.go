@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/config"
+)
+
+// Email is a plain-text message to a single recipient
+type Email struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers transactional email
+type Mailer interface {
+	Send(ctx context.Context, email Email) error
+}
+
+var (
+	mailer   Mailer
+	mailerMu sync.Mutex
+)
+
+// Mail returns the shared mailer. MAILER=smtp sends through SMTP_HOST; anything
+// else writes messages to the log, or to MAIL_SINK_FILE when it is set.
+func Mail() Mailer {
+	mailerMu.Lock()
+	defer mailerMu.Unlock()
+
+	if mailer == nil {
+		if config.GetEnv("MAILER", "log") == "smtp" {
+			mailer = &smtpMailer{
+				host:     config.GetEnv("SMTP_HOST", ""),
+				port:     config.GetEnv("SMTP_PORT", "587"),
+				username: config.GetEnv("SMTP_USERNAME", ""),
+				password: config.GetEnv("SMTP_PASSWORD", ""),
+				from:     config.GetEnv("MAIL_FROM", "no-reply@0xmart.local"),
+			}
+		} else {
+			mailer = &sinkMailer{path: config.GetEnv("MAIL_SINK_FILE", "")}
+		}
+	}
+	return mailer
+}
+
+// SetMailer replaces the shared mailer, e.g. with a stub
+func SetMailer(m Mailer) {
+	mailerMu.Lock()
+	defer mailerMu.Unlock()
+
+	mailer = m
+}
+
+// Background sends get this long, as the request they came from is already answered
+const backgroundMailTimeout = time.Minute
+
+// SendInBackground runs send off the request path with its own timeout, so neither a
+// slow mail server nor whether anything was sent shows in the response time
+func SendInBackground(what string, send func(ctx context.Context) error) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), backgroundMailTimeout)
+		defer cancel()
+
+		if err := send(ctx); err != nil {
+			log.Printf("❌ Failed to send %s: %v", what, err)
+		}
+	}()
+}
+
+// AppURL is the frontend base URL used in emailed links
+func AppURL() string {
+	return strings.TrimRight(config.GetEnv("APP_URL", "http://localhost:3000"), "/")
+}
+
+type smtpMailer struct {
+	host     string
+	port     string
+	username string
+	password string
+	from     string
+}
+
+func (m *smtpMailer) Send(ctx context.Context, email Email) error {
+	if m.host == "" {
+		return fmt.Errorf("SMTP_HOST is not configured")
+	}
+
+	var auth smtp.Auth
+	if m.username != "" {
+		auth = smtp.PlainAuth("", m.username, m.password, m.host)
+	}
+
+	// net/smtp has no context support, so run the send and give up when ctx is done
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(net.JoinHostPort(m.host, m.port), auth, m.from, []string{email.To}, formatEmail(m.from, email))
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// sinkMailer logs messages instead of sending them, for local development and tests
+type sinkMailer struct {
+	mu   sync.Mutex
+	path string
+}
+
+func (m *sinkMailer) Send(ctx context.Context, email Email) error {
+	if m.path == "" {
+		log.Printf("📧 Mail to %s: %s\n%s", email.To, email.Subject, email.Body)
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, err := os.OpenFile(m.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(formatEmail("", email), '\n'))
+	return err
+}
+
+func formatEmail(from string, email Email) []byte {
+	var b strings.Builder
+	if from != "" {
+		fmt.Fprintf(&b, "From: %s\r\n", from)
+	}
+	fmt.Fprintf(&b, "To: %s\r\n", email.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", email.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(email.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
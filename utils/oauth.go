@@ -0,0 +1,317 @@
+package utils
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/config"
+	"github.com/golang-jwt/jwt"
+	"golang.org/x/sync/singleflight"
+)
+
+var (
+	ErrProviderNotConfigured = errors.New("OAuth provider is not configured")
+	ErrInvalidIDToken        = errors.New("invalid provider ID token")
+	ErrInvalidSignature      = errors.New("invalid NextAuth signature")
+)
+
+// Well-known settings for providers, so only the client ID has to be configured
+var defaultOAuthProviders = map[string]oauthProvider{
+	"google": {
+		jwksURL: "https://www.googleapis.com/oauth2/v3/certs",
+		issuers: []string{"https://accounts.google.com", "accounts.google.com"},
+	},
+	"apple": {
+		jwksURL: "https://appleid.apple.com/auth/keys",
+		issuers: []string{"https://appleid.apple.com"},
+	},
+}
+
+// nextAuthSignatureSkew bounds the age of a signed NextAuth request
+const nextAuthSignatureSkew = 5 * time.Minute
+
+// ProviderIdentity is the identity asserted by a verified provider ID token
+type ProviderIdentity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
+type oauthProvider struct {
+	jwksURL  string
+	issuers  []string
+	clientID string
+}
+
+// oauthProviderConfig reads OAUTH_<PROVIDER>_CLIENT_ID, _JWKS_URL and _ISSUER.
+// The JWKS URL may be a file:// URL pointing at a local stand-in key set.
+func oauthProviderConfig(name string) (oauthProvider, error) {
+	provider := defaultOAuthProviders[strings.ToLower(name)]
+	prefix := "OAUTH_" + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name)) + "_"
+
+	provider.clientID = config.GetEnv(prefix+"CLIENT_ID", "")
+	provider.jwksURL = config.GetEnv(prefix+"JWKS_URL", provider.jwksURL)
+	if issuer := config.GetEnv(prefix+"ISSUER", ""); issuer != "" {
+		provider.issuers = strings.Split(issuer, ",")
+	}
+
+	if provider.clientID == "" || provider.jwksURL == "" || len(provider.issuers) == 0 {
+		return oauthProvider{}, ErrProviderNotConfigured
+	}
+	return provider, nil
+}
+
+// VerifyProviderIDToken checks an OpenID Connect ID token against the provider's
+// JWKS, issuer and our client ID, and returns the identity it asserts
+func VerifyProviderIDToken(ctx context.Context, providerName, idToken string) (ProviderIdentity, error) {
+	provider, err := oauthProviderConfig(providerName)
+	if err != nil {
+		return ProviderIdentity{}, err
+	}
+
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		switch token.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodECDSA:
+		default:
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		kid, _ := token.Header["kid"].(string)
+		return providerJWKS.key(ctx, provider.jwksURL, kid)
+	})
+	if err != nil || !token.Valid {
+		return ProviderIdentity{}, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	if !claims.VerifyAudience(provider.clientID, true) {
+		return ProviderIdentity{}, fmt.Errorf("%w: audience mismatch", ErrInvalidIDToken)
+	}
+
+	issuerOK := false
+	for _, issuer := range provider.issuers {
+		if claims.VerifyIssuer(strings.TrimSpace(issuer), true) {
+			issuerOK = true
+			break
+		}
+	}
+	if !issuerOK {
+		return ProviderIdentity{}, fmt.Errorf("%w: issuer mismatch", ErrInvalidIDToken)
+	}
+
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return ProviderIdentity{}, fmt.Errorf("%w: missing subject", ErrInvalidIDToken)
+	}
+
+	email, _ := claims["email"].(string)
+
+	// Some providers encode email_verified as a string
+	verified := false
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		verified = v
+	case string:
+		verified = v == "true"
+	}
+
+	return ProviderIdentity{Subject: subject, Email: email, EmailVerified: verified}, nil
+}
+
+// NextAuthSigningEnabled reports whether NEXTAUTH_SIGNING_SECRET is configured
+func NextAuthSigningEnabled() bool {
+	return config.GetEnv("NEXTAUTH_SIGNING_SECRET", "") != ""
+}
+
+// VerifyNextAuthSignature checks the hex HMAC-SHA256 of "<timestamp>.<body>"
+// that our NextAuth server computes with NEXTAUTH_SIGNING_SECRET
+func VerifyNextAuthSignature(body []byte, timestamp, signature string) error {
+	secret := config.GetEnv("NEXTAUTH_SIGNING_SECRET", "")
+	if secret == "" {
+		return ErrInvalidSignature
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	age := time.Since(time.Unix(unix, 0))
+	if age > nextAuthSignatureSkew || age < -nextAuthSignatureSkew {
+		return fmt.Errorf("%w: stale timestamp", ErrInvalidSignature)
+	}
+
+	given, err := hex.DecodeString(signature)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	if !hmac.Equal(mac.Sum(nil), given) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// jwksCache keeps provider key sets in memory, keyed by JWKS URL
+type jwksCache struct {
+	mu      sync.Mutex
+	entries map[string]*jwksCacheEntry
+	fetches singleflight.Group
+}
+
+// jwksCacheEntry is replaced, never modified, so it can be read without the lock
+type jwksCacheEntry struct {
+	keys      map[string]interface{}
+	fetchedAt time.Time
+}
+
+var providerJWKS = &jwksCache{entries: make(map[string]*jwksCacheEntry)}
+
+// jwksRefreshInterval limits refetches triggered by unknown kids
+const jwksRefreshInterval = time.Minute
+
+func jwksCacheTTL() time.Duration {
+	ttl, err := time.ParseDuration(config.GetEnv("OAUTH_JWKS_CACHE_TTL", "1h"))
+	if err != nil || ttl <= 0 {
+		return time.Hour
+	}
+	return ttl
+}
+
+// key returns the public key for kid, refetching the set when it has expired
+// or when a provider has rotated to a kid we have not seen yet. The fetch happens
+// outside the lock and concurrent fetches of one URL are shared, so a slow provider
+// does not hold up sign-ins with other providers.
+func (c *jwksCache) key(ctx context.Context, url, kid string) (interface{}, error) {
+	c.mu.Lock()
+	entry := c.entries[url]
+	c.mu.Unlock()
+
+	stale := entry == nil || time.Since(entry.fetchedAt) > jwksCacheTTL()
+	if !stale {
+		if _, ok := entry.keys[kid]; !ok && time.Since(entry.fetchedAt) > jwksRefreshInterval {
+			stale = true
+		}
+	}
+
+	if stale {
+		fetched, err, _ := c.fetches.Do(url, func() (interface{}, error) {
+			keys, err := fetchJWKS(ctx, url)
+			if err != nil {
+				return nil, err
+			}
+
+			fresh := &jwksCacheEntry{keys: keys, fetchedAt: time.Now()}
+			c.mu.Lock()
+			c.entries[url] = fresh
+			c.mu.Unlock()
+			return fresh, nil
+		})
+		if err != nil {
+			if entry == nil {
+				return nil, err
+			}
+			// Keep serving the last known keys if the provider is unreachable
+		} else {
+			entry = fetched.(*jwksCacheEntry)
+		}
+	}
+
+	key, ok := entry.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+func fetchJWKS(ctx context.Context, url string) (map[string]interface{}, error) {
+	var data []byte
+	var err error
+
+	if path := strings.TrimPrefix(url, "file://"); path != url {
+		data, err = os.ReadFile(path)
+	} else {
+		data, err = httpGet(ctx, url)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %v", err)
+	}
+
+	var set struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Crv string `json:"crv"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse JWKS: %v", err)
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, k := range set.Keys {
+		switch k.Kty {
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(k.N)
+			e, errE := base64.RawURLEncoding.DecodeString(k.E)
+			if errN != nil || errE != nil {
+				continue
+			}
+			keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "EC":
+			if k.Crv != "P-256" {
+				continue
+			}
+			x, errX := base64.RawURLEncoding.DecodeString(k.X)
+			y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+			if errX != nil || errY != nil {
+				continue
+			}
+			keys[k.Kid] = &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		}
+	}
+	return keys, nil
+}
+
+func httpGet(ctx context.Context, url string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
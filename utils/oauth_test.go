@@ -0,0 +1,172 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+const (
+	testOAuthClientID = "test-client"
+	testOAuthIssuer   = "https://issuer.example.com"
+	testOAuthKid      = "test-key"
+)
+
+// newTestJWKS serves a stand-in provider key set and configures the "test" provider
+// to use it
+func newTestJWKS(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	set := map[string]interface{}{
+		"keys": []map[string]string{{
+			"kid": testOAuthKid,
+			"kty": "RSA",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(set)
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv("OAUTH_TEST_CLIENT_ID", testOAuthClientID)
+	t.Setenv("OAUTH_TEST_JWKS_URL", server.URL)
+	t.Setenv("OAUTH_TEST_ISSUER", testOAuthIssuer)
+	return key
+}
+
+func testIDTokenClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":            testOAuthIssuer,
+		"aud":            testOAuthClientID,
+		"sub":            "provider-user-1",
+		"email":          "user@example.com",
+		"email_verified": true,
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func signTestIDToken(t *testing.T, key *rsa.PrivateKey, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestVerifyProviderIDToken(t *testing.T) {
+	key := newTestJWKS(t)
+
+	tests := []struct {
+		name    string
+		kid     string
+		mutate  func(jwt.MapClaims)
+		wantErr bool
+	}{
+		{name: "valid token", kid: testOAuthKid},
+		{
+			name:    "wrong audience",
+			kid:     testOAuthKid,
+			mutate:  func(c jwt.MapClaims) { c["aud"] = "someone-else" },
+			wantErr: true,
+		},
+		{
+			name:    "wrong issuer",
+			kid:     testOAuthKid,
+			mutate:  func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" },
+			wantErr: true,
+		},
+		{
+			name:    "expired token",
+			kid:     testOAuthKid,
+			mutate:  func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() },
+			wantErr: true,
+		},
+		{name: "unknown kid", kid: "rotated-away", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			claims := testIDTokenClaims()
+			if tc.mutate != nil {
+				tc.mutate(claims)
+			}
+
+			identity, err := VerifyProviderIDToken(context.Background(), "test", signTestIDToken(t, key, tc.kid, claims))
+			if tc.wantErr {
+				if !errors.Is(err, ErrInvalidIDToken) {
+					t.Errorf("error = %v, want ErrInvalidIDToken", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if identity.Subject != "provider-user-1" || identity.Email != "user@example.com" || !identity.EmailVerified {
+				t.Errorf("identity = %+v", identity)
+			}
+		})
+	}
+}
+
+func TestVerifyProviderIDTokenReportsUnverifiedEmail(t *testing.T) {
+	key := newTestJWKS(t)
+
+	for _, verified := range []interface{}{false, "false", nil} {
+		claims := testIDTokenClaims()
+		claims["email_verified"] = verified
+
+		identity, err := VerifyProviderIDToken(context.Background(), "test", signTestIDToken(t, key, testOAuthKid, claims))
+		if err != nil {
+			t.Fatalf("email_verified=%v: %v", verified, err)
+		}
+		if identity.EmailVerified {
+			t.Errorf("email_verified=%v reported as verified", verified)
+		}
+	}
+}
+
+func TestVerifyProviderIDTokenRejectsForeignKey(t *testing.T) {
+	newTestJWKS(t)
+
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = VerifyProviderIDToken(context.Background(), "test", signTestIDToken(t, other, testOAuthKid, testIDTokenClaims()))
+	if !errors.Is(err, ErrInvalidIDToken) {
+		t.Errorf("error = %v, want ErrInvalidIDToken", err)
+	}
+}
+
+func TestVerifyProviderIDTokenUnconfiguredProvider(t *testing.T) {
+	_, err := VerifyProviderIDToken(context.Background(), "nobody", "token")
+	if !errors.Is(err, ErrProviderNotConfigured) {
+		t.Errorf("error = %v, want ErrProviderNotConfigured", err)
+	}
+}
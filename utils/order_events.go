@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type OrderEventType string
+
+const (
+	OrderEventCreated        OrderEventType = "order.created"
+	OrderEventPaymentSeen    OrderEventType = "payment.seen"
+	OrderEventConfirmations  OrderEventType = "payment.confirmations"
+	OrderEventPaymentRemoved OrderEventType = "payment.removed" // The payment's block was reorganised away
+	OrderEventPartiallyPaid  OrderEventType = "order.partially_paid"
+	OrderEventPaid           OrderEventType = "order.paid"
+	OrderEventFulfillment    OrderEventType = "order.fulfillment"
+)
+
+// OrderEvent is a status change pushed to the owner of an order
+type OrderEvent struct {
+	Type              OrderEventType           `json:"type"`
+	OrderID           primitive.ObjectID       `json:"orderId"`
+	OrderNumber       uint64                   `json:"orderNumber"`
+	UserID            primitive.ObjectID       `json:"-"`
+	Status            models.OrderStatus       `json:"status,omitempty"`
+	FulfillmentStatus models.FulfillmentStatus `json:"fulfillmentStatus,omitempty"`
+	TxHash            string                   `json:"txHash,omitempty"`
+	Confirmations     uint64                   `json:"confirmations,omitempty"`
+	Timestamp         time.Time                `json:"timestamp"`
+}
+
+// OrderEventBus fans order events out to subscribers. The in-process implementation
+// only reaches clients connected to this replica; a Mongo change stream backed bus
+// can replace it through SetOrderEventBus.
+type OrderEventBus interface {
+	Publish(event OrderEvent)
+	Subscribe(userID primitive.ObjectID) (<-chan OrderEvent, func())
+}
+
+var (
+	orderEventBus   OrderEventBus = NewMemoryOrderEventBus()
+	orderEventBusMu sync.RWMutex
+)
+
+// OrderEvents returns the shared order event bus
+func OrderEvents() OrderEventBus {
+	orderEventBusMu.RLock()
+	defer orderEventBusMu.RUnlock()
+
+	return orderEventBus
+}
+
+// SetOrderEventBus replaces the shared order event bus
+func SetOrderEventBus(bus OrderEventBus) {
+	orderEventBusMu.Lock()
+	defer orderEventBusMu.Unlock()
+
+	orderEventBus = bus
+}
+
+// PublishOrderEvent stamps and publishes an event for an order
+func PublishOrderEvent(eventType OrderEventType, order models.Order, mutate ...func(*OrderEvent)) {
+	event := OrderEvent{
+		Type:              eventType,
+		OrderID:           order.ID,
+		OrderNumber:       order.OrderNumber,
+		UserID:            order.UserID,
+		Status:            order.Status,
+		FulfillmentStatus: order.FulfillmentStatus,
+		Timestamp:         time.Now(),
+	}
+	for _, fn := range mutate {
+		fn(&event)
+	}
+
+	OrderEvents().Publish(event)
+}
+
+type memoryOrderEventBus struct {
+	mu          sync.RWMutex
+	subscribers map[primitive.ObjectID]map[chan OrderEvent]struct{}
+}
+
+func NewMemoryOrderEventBus() OrderEventBus {
+	return &memoryOrderEventBus{
+		subscribers: make(map[primitive.ObjectID]map[chan OrderEvent]struct{}),
+	}
+}
+
+func (b *memoryOrderEventBus) Publish(event OrderEvent) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for ch := range b.subscribers[event.UserID] {
+		select {
+		case ch <- event:
+		default:
+			// Never block the publisher on a slow client
+			log.Printf("⚠️ Dropping %s event for a slow subscriber", event.Type)
+		}
+	}
+}
+
+func (b *memoryOrderEventBus) Subscribe(userID primitive.ObjectID) (<-chan OrderEvent, func()) {
+	ch := make(chan OrderEvent, 16)
+
+	b.mu.Lock()
+	if b.subscribers[userID] == nil {
+		b.subscribers[userID] = make(map[chan OrderEvent]struct{})
+	}
+	b.subscribers[userID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers[userID], ch)
+			if len(b.subscribers[userID]) == 0 {
+				delete(b.subscribers, userID)
+			}
+			b.mu.Unlock()
+		})
+	}
+
+	return ch, unsubscribe
+}
@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/config"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+)
+
+// PasswordResetTTL is how long a reset link works, configurable with PASSWORD_RESET_TTL
+func PasswordResetTTL() time.Duration {
+	ttl, err := time.ParseDuration(config.GetEnv("PASSWORD_RESET_TTL", "1h"))
+	if err != nil || ttl <= 0 {
+		return time.Hour
+	}
+	return ttl
+}
+
+// SendPasswordResetEmail mails the user a single-use password reset link
+func SendPasswordResetEmail(ctx context.Context, user models.User) error {
+	token, err := IssueUserToken(ctx, user.ID, models.UserTokenPasswordReset, user.Email, PasswordResetTTL())
+	if err != nil {
+		return err
+	}
+
+	link := AppURL() + "/reset-password?token=" + url.QueryEscape(token)
+	return Mail().Send(ctx, Email{
+		To:      user.Email,
+		Subject: "Reset your 0xmart password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nSomeone asked to reset the password of your 0xmart account. Choose a new password here:\n\n%s\n\nThe link expires in %s and can be used once. If this wasn't you, ignore this email; your password has not changed.\n",
+			user.Name, link, PasswordResetTTL(),
+		),
+	})
+}
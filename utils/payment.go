@@ -3,15 +3,21 @@ package utils
 import (
 	"context"
 	"crypto/ecdsa"
+	"errors"
 	"fmt"
 	"math/big"
 
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
 )
 
+// ErrBalanceBelowFee is returned by Sweep when there is nothing left after the fee
+var ErrBalanceBelowFee = errors.New("balance does not cover the transfer fee")
+
 type PaymentProcessor struct {
 	client     *ethclient.Client
 	privateKey *ecdsa.PrivateKey
@@ -34,45 +40,159 @@ func NewPaymentProcessor(rpcURL, privateKeyHex string) (*PaymentProcessor, error
 	}, nil
 }
 
+// NewPaymentProcessorWithKey creates a processor that signs with the given key over an existing client
+func NewPaymentProcessorWithKey(client *ethclient.Client, privateKey *ecdsa.PrivateKey) *PaymentProcessor {
+	return &PaymentProcessor{
+		client:     client,
+		privateKey: privateKey,
+	}
+}
+
 func (p *PaymentProcessor) ProcessPayment(toAddress string, amount *big.Int) (*types.Transaction, error) {
 	ctx := context.Background()
 
-	publicKey := p.privateKey.Public()
-	publicKeyECDSA, ok := publicKey.(*ecdsa.PublicKey)
-	if !ok {
-		return nil, fmt.Errorf("error casting public key to ECDSA")
+	gasPrice, err := p.client.SuggestGasPrice(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get gas price: %v", err)
 	}
 
-	fromAddress := crypto.PubkeyToAddress(*publicKeyECDSA)
-	nonce, err := p.client.PendingNonceAt(ctx, fromAddress)
+	return p.transfer(ctx, toAddress, amount, gasPrice)
+}
+
+// Sweep sends the whole balance of the signer, less the transfer fee, to toAddress
+func (p *PaymentProcessor) Sweep(toAddress string) (*types.Transaction, error) {
+	ctx := context.Background()
+
+	balance, err := p.client.BalanceAt(ctx, p.Address(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get balance: %v", err)
+	}
+
+	gasPrice, err := p.client.SuggestGasPrice(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get gas price: %v", err)
+	}
+
+	fee := new(big.Int).Mul(gasPrice, big.NewInt(int64(transferGasLimit)))
+	amount := new(big.Int).Sub(balance, fee)
+	if amount.Sign() <= 0 {
+		return nil, fmt.Errorf("%w: %s", ErrBalanceBelowFee, balance)
+	}
+
+	return p.transfer(ctx, toAddress, amount, gasPrice)
+}
+
+// Address returns the address of the signing key
+func (p *PaymentProcessor) Address() common.Address {
+	return crypto.PubkeyToAddress(p.privateKey.PublicKey)
+}
+
+const transferGasLimit = uint64(21000)
+
+func (p *PaymentProcessor) transfer(ctx context.Context, toAddress string, amount, gasPrice *big.Int) (*types.Transaction, error) {
+	return p.send(ctx, common.HexToAddress(toAddress), amount, transferGasLimit, gasPrice, nil)
+}
+
+// SignTransaction prepares a contract call signed by the processor's key without sending
+// it, so that it can be recorded before it is broadcast with SendTransaction
+func (p *PaymentProcessor) SignTransaction(ctx context.Context, to common.Address, data []byte) (*types.Transaction, error) {
+	gasPrice, err := p.client.SuggestGasPrice(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get gas price: %v", err)
+	}
+
+	gasLimit, err := p.client.EstimateGas(ctx, ethereum.CallMsg{From: p.Address(), To: &to, Data: data})
+	if err != nil {
+		return nil, fmt.Errorf("failed to estimate gas: %v", err)
+	}
+
+	nonce, err := p.client.PendingNonceAt(ctx, p.Address())
 	if err != nil {
 		return nil, fmt.Errorf("failed to get nonce: %v", err)
 	}
 
+	return p.sign(ctx, types.NewTransaction(nonce, to, big.NewInt(0), gasLimit, gasPrice, data))
+}
+
+// SendTransaction broadcasts a transaction signed with SignTransaction
+func (p *PaymentProcessor) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	if err := p.client.SendTransaction(ctx, tx); err != nil {
+		return fmt.Errorf("failed to send transaction: %v", err)
+	}
+	return nil
+}
+
+// WaitMined blocks until the transaction is mined and returns its receipt
+func (p *PaymentProcessor) WaitMined(ctx context.Context, tx *types.Transaction) (*types.Receipt, error) {
+	return bind.WaitMined(ctx, p.client, tx)
+}
+
+// TransactionReceipt returns the receipt of an already sent transaction
+func (p *PaymentProcessor) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	return p.client.TransactionReceipt(ctx, txHash)
+}
+
+// TransactWithNonce sends a contract call with the nonce of an earlier transaction that
+// was not mined, replacing it. The gas price is raised to at least minGasPrice.
+func (p *PaymentProcessor) TransactWithNonce(ctx context.Context, to common.Address, data []byte, nonce uint64, minGasPrice *big.Int) (*types.Transaction, error) {
 	gasPrice, err := p.client.SuggestGasPrice(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get gas price: %v", err)
 	}
+	if gasPrice.Cmp(minGasPrice) < 0 {
+		gasPrice = minGasPrice
+	}
 
-	to := common.HexToAddress(toAddress)
-	gasLimit := uint64(21000)
+	gasLimit, err := p.client.EstimateGas(ctx, ethereum.CallMsg{From: p.Address(), To: &to, Data: data})
+	if err != nil {
+		return nil, fmt.Errorf("failed to estimate gas: %v", err)
+	}
 
-	tx := types.NewTransaction(nonce, to, amount, gasLimit, gasPrice, nil)
+	return p.sendWithNonce(ctx, nonce, to, big.NewInt(0), gasLimit, gasPrice, data)
+}
 
-	chainID, err := p.client.NetworkID(ctx)
+// ConfirmedNonce returns the signer's nonce as of the latest block
+func (p *PaymentProcessor) ConfirmedNonce(ctx context.Context) (uint64, error) {
+	return p.client.NonceAt(ctx, p.Address(), nil)
+}
+
+// TransactionByHash returns a sent transaction, with ethereum.NotFound once the node
+// has dropped it
+func (p *PaymentProcessor) TransactionByHash(ctx context.Context, txHash common.Hash) (*types.Transaction, error) {
+	tx, _, err := p.client.TransactionByHash(ctx, txHash)
+	return tx, err
+}
+
+func (p *PaymentProcessor) send(ctx context.Context, to common.Address, amount *big.Int, gasLimit uint64, gasPrice *big.Int, data []byte) (*types.Transaction, error) {
+	nonce, err := p.client.PendingNonceAt(ctx, p.Address())
 	if err != nil {
-		return nil, fmt.Errorf("failed to get chain id: %v", err)
+		return nil, fmt.Errorf("failed to get nonce: %v", err)
 	}
 
-	signedTx, err := types.SignTx(tx, types.NewEIP155Signer(chainID), p.privateKey)
+	return p.sendWithNonce(ctx, nonce, to, amount, gasLimit, gasPrice, data)
+}
+
+func (p *PaymentProcessor) sendWithNonce(ctx context.Context, nonce uint64, to common.Address, amount *big.Int, gasLimit uint64, gasPrice *big.Int, data []byte) (*types.Transaction, error) {
+	signedTx, err := p.sign(ctx, types.NewTransaction(nonce, to, amount, gasLimit, gasPrice, data))
 	if err != nil {
-		return nil, fmt.Errorf("failed to sign transaction: %v", err)
+		return nil, err
+	}
+
+	if err := p.SendTransaction(ctx, signedTx); err != nil {
+		return nil, err
 	}
+	return signedTx, nil
+}
 
-	err = p.client.SendTransaction(ctx, signedTx)
+func (p *PaymentProcessor) sign(ctx context.Context, tx *types.Transaction) (*types.Transaction, error) {
+	chainID, err := p.client.NetworkID(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send transaction: %v", err)
+		return nil, fmt.Errorf("failed to get chain id: %v", err)
 	}
 
+	signedTx, err := types.SignTx(tx, types.NewEIP155Signer(chainID), p.privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign transaction: %v", err)
+	}
 	return signedTx, nil
 }
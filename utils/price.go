@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+)
+
+const weiDecimals = 18
+
+var ErrInvalidPrice = errors.New("price must be a positive decimal ETH amount with at most 18 decimals")
+
+// PriceToWei converts a decimal ETH amount such as "0.05" to wei without going
+// through floating point, so every representable amount converts exactly
+func PriceToWei(eth string) (*big.Int, error) {
+	whole, frac, _ := strings.Cut(strings.TrimSpace(eth), ".")
+	if whole == "" && frac == "" || len(frac) > weiDecimals {
+		return nil, ErrInvalidPrice
+	}
+	for _, r := range whole + frac {
+		if r < '0' || r > '9' {
+			return nil, ErrInvalidPrice
+		}
+	}
+
+	wei, ok := new(big.Int).SetString(whole+frac+strings.Repeat("0", weiDecimals-len(frac)), 10)
+	if !ok || wei.Sign() <= 0 {
+		return nil, ErrInvalidPrice
+	}
+	return wei, nil
+}
@@ -0,0 +1,184 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/config"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+var ErrQuoteMismatch = errors.New("payment does not match checkout quote")
+
+// Field order must match the CheckoutQuote struct hashed by the payment contract
+var checkoutQuoteTypes = apitypes.Types{
+	"EIP712Domain": {
+		{Name: "name", Type: "string"},
+		{Name: "version", Type: "string"},
+		{Name: "chainId", Type: "uint256"},
+		{Name: "verifyingContract", Type: "address"},
+	},
+	"CheckoutQuote": {
+		{Name: "orderNumber", Type: "uint256"},
+		{Name: "amount", Type: "uint256"},
+		{Name: "token", Type: "address"},
+		{Name: "payer", Type: "address"},
+		{Name: "expiry", Type: "uint256"},
+	},
+}
+
+// QuotesEnabled reports whether a quote signing key is configured
+func QuotesEnabled() bool {
+	return config.GetEnv("QUOTE_SIGNER_PRIVATE_KEY", "") != ""
+}
+
+// NewCheckoutQuote builds and signs a quote for paying amount towards an order
+func NewCheckoutQuote(orderNumber uint64, amount, payer string) (*models.PaymentQuote, error) {
+	chainID, err := strconv.ParseInt(config.GetEnv("CHAIN_ID", "1"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CHAIN_ID: %v", err)
+	}
+
+	ttl, err := time.ParseDuration(config.GetEnv("QUOTE_TTL", "30m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid QUOTE_TTL: %v", err)
+	}
+
+	if payer == "" {
+		payer = common.Address{}.Hex()
+	}
+
+	quote := &models.PaymentQuote{
+		OrderNumber:       orderNumber,
+		Amount:            amount,
+		Token:             common.HexToAddress(config.GetEnv("PAYMENT_TOKEN_ADDRESS", "")).Hex(),
+		Payer:             common.HexToAddress(payer).Hex(),
+		Expiry:            time.Now().Add(ttl).Unix(),
+		ChainID:           chainID,
+		VerifyingContract: common.HexToAddress(config.GetEnv("CONTRACT_ADDRESS", "")).Hex(),
+	}
+
+	if err := SignCheckoutQuote(quote); err != nil {
+		return nil, err
+	}
+	return quote, nil
+}
+
+// SignCheckoutQuote signs the quote's EIP-712 digest with QUOTE_SIGNER_PRIVATE_KEY
+func SignCheckoutQuote(quote *models.PaymentQuote) error {
+	privateKey, err := crypto.HexToECDSA(strings.TrimPrefix(config.GetEnv("QUOTE_SIGNER_PRIVATE_KEY", ""), "0x"))
+	if err != nil {
+		return fmt.Errorf("failed to parse quote signer key: %v", err)
+	}
+
+	digest, err := checkoutQuoteDigest(quote)
+	if err != nil {
+		return err
+	}
+
+	signature, err := crypto.Sign(digest, privateKey)
+	if err != nil {
+		return fmt.Errorf("failed to sign quote: %v", err)
+	}
+
+	// Solidity's ecrecover expects v to be 27 or 28
+	signature[crypto.RecoveryIDOffset] += 27
+	quote.Signature = hexutil.Encode(signature)
+	return nil
+}
+
+// quoteRetention is how long a quote is kept after it expires, so that a payment mined
+// just before expiry still finds it when it is processed
+const quoteRetention = time.Hour
+
+// RetainQuotes drops quotes that expired longer ago than quoteRetention and adds the
+// newly issued one
+func RetainQuotes(quotes []models.PaymentQuote, issued *models.PaymentQuote) []models.PaymentQuote {
+	cutoff := time.Now().Add(-quoteRetention).Unix()
+
+	var retained []models.PaymentQuote
+	for _, quote := range quotes {
+		if quote.Expiry >= cutoff {
+			retained = append(retained, quote)
+		}
+	}
+	return append(retained, *issued)
+}
+
+// VerifyQuotePayment checks that an on-chain payment was authorized by one of the
+// order's quotes: the order, amount, token and payer must match a quote that had not
+// expired when the payment was mined.
+func VerifyQuotePayment(quotes []models.PaymentQuote, tx *models.Transaction) error {
+	err := fmt.Errorf("%w: no quote issued", ErrQuoteMismatch)
+	for i := range quotes {
+		if err = verifyQuote(&quotes[i], tx); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func verifyQuote(quote *models.PaymentQuote, tx *models.Transaction) error {
+	if quote.OrderNumber != tx.OrderID {
+		return fmt.Errorf("%w: order number %d, quoted %d", ErrQuoteMismatch, tx.OrderID, quote.OrderNumber)
+	}
+
+	if parseWei(quote.Amount).Cmp(parseWei(tx.Amount)) != 0 {
+		return fmt.Errorf("%w: amount %s, quoted %s", ErrQuoteMismatch, tx.Amount, quote.Amount)
+	}
+
+	if common.HexToAddress(quote.Token) != common.HexToAddress(tx.Token) {
+		return fmt.Errorf("%w: token %s, quoted %s", ErrQuoteMismatch, tx.Token, quote.Token)
+	}
+
+	payer := common.HexToAddress(quote.Payer)
+	if payer != (common.Address{}) && payer != common.HexToAddress(tx.CustomerAddress) {
+		return fmt.Errorf("%w: payer %s, quoted %s", ErrQuoteMismatch, tx.CustomerAddress, quote.Payer)
+	}
+
+	// Fall back to the time the event was received if the block could not be fetched
+	paidAt := tx.BlockTime
+	if paidAt.IsZero() {
+		paidAt = tx.Timestamp
+	}
+	if paidAt.Unix() > quote.Expiry {
+		return fmt.Errorf("%w: quote expired at %d", ErrQuoteMismatch, quote.Expiry)
+	}
+
+	return nil
+}
+
+func checkoutQuoteDigest(quote *models.PaymentQuote) ([]byte, error) {
+	typedData := apitypes.TypedData{
+		Types:       checkoutQuoteTypes,
+		PrimaryType: "CheckoutQuote",
+		Domain: apitypes.TypedDataDomain{
+			Name:              config.GetEnv("QUOTE_DOMAIN_NAME", "0xmart"),
+			Version:           config.GetEnv("QUOTE_DOMAIN_VERSION", "1"),
+			ChainId:           math.NewHexOrDecimal256(quote.ChainID),
+			VerifyingContract: quote.VerifyingContract,
+		},
+		Message: apitypes.TypedDataMessage{
+			"orderNumber": new(big.Int).SetUint64(quote.OrderNumber).String(),
+			"amount":      quote.Amount,
+			"token":       quote.Token,
+			"payer":       quote.Payer,
+			"expiry":      strconv.FormatInt(quote.Expiry, 10),
+		},
+	}
+
+	digest, _, err := apitypes.TypedDataAndHash(typedData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash quote: %v", err)
+	}
+	return digest, nil
+}
@@ -0,0 +1,196 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Well-known throwaway key; its address is 0x7E5F4552091A69125d5DfCb7b8C2659029395Bdf
+const testQuoteSignerKey = "0000000000000000000000000000000000000000000000000000000000000001"
+
+// EIP-712 digest of testQuote under the 0xmart/1 domain
+const testQuoteDigest = "0xf546d23e3142063bdebf992eb446e87c34f79b91250e5cb0047c06011d57a009"
+
+func testQuote() *models.PaymentQuote {
+	return &models.PaymentQuote{
+		OrderNumber:       42,
+		Amount:            "1500000000000000000",
+		Token:             common.Address{}.Hex(),
+		Payer:             "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC",
+		Expiry:            1767225600,
+		ChainID:           11155111,
+		VerifyingContract: "0x1111111111111111111111111111111111111111",
+	}
+}
+
+func uint256Word(v *big.Int) []byte {
+	return common.LeftPadBytes(v.Bytes(), 32)
+}
+
+// expectedQuoteDigest encodes the quote by hand following EIP-712, independently of
+// the apitypes encoder used by checkoutQuoteDigest
+func expectedQuoteDigest(quote *models.PaymentQuote) []byte {
+	domainType := crypto.Keccak256([]byte("EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)"))
+	domainSeparator := crypto.Keccak256(
+		domainType,
+		crypto.Keccak256([]byte("0xmart")),
+		crypto.Keccak256([]byte("1")),
+		uint256Word(big.NewInt(quote.ChainID)),
+		common.LeftPadBytes(common.HexToAddress(quote.VerifyingContract).Bytes(), 32),
+	)
+
+	quoteType := crypto.Keccak256([]byte("CheckoutQuote(uint256 orderNumber,uint256 amount,address token,address payer,uint256 expiry)"))
+	amount, _ := new(big.Int).SetString(quote.Amount, 10)
+	structHash := crypto.Keccak256(
+		quoteType,
+		uint256Word(new(big.Int).SetUint64(quote.OrderNumber)),
+		uint256Word(amount),
+		common.LeftPadBytes(common.HexToAddress(quote.Token).Bytes(), 32),
+		common.LeftPadBytes(common.HexToAddress(quote.Payer).Bytes(), 32),
+		uint256Word(big.NewInt(quote.Expiry)),
+	)
+
+	return crypto.Keccak256([]byte("\x19\x01"), domainSeparator, structHash)
+}
+
+func TestCheckoutQuoteDigestMatchesEIP712Encoding(t *testing.T) {
+	t.Setenv("QUOTE_DOMAIN_NAME", "0xmart")
+	t.Setenv("QUOTE_DOMAIN_VERSION", "1")
+
+	quote := testQuote()
+	digest, err := checkoutQuoteDigest(quote)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := expectedQuoteDigest(quote); !bytes.Equal(digest, want) {
+		t.Errorf("digest = %x, want %x", digest, want)
+	}
+
+	// Pinned so that a change to the typed data is caught even if both encoders drift
+	if got := hexutil.Encode(digest); got != testQuoteDigest {
+		t.Errorf("digest = %s, want %s", got, testQuoteDigest)
+	}
+}
+
+func TestSignCheckoutQuoteRecoversSigner(t *testing.T) {
+	t.Setenv("QUOTE_SIGNER_PRIVATE_KEY", "0x"+testQuoteSignerKey)
+	t.Setenv("QUOTE_DOMAIN_NAME", "0xmart")
+	t.Setenv("QUOTE_DOMAIN_VERSION", "1")
+
+	quote := testQuote()
+	if err := SignCheckoutQuote(quote); err != nil {
+		t.Fatal(err)
+	}
+
+	signature, err := hexutil.Decode(quote.Signature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(signature) != crypto.SignatureLength {
+		t.Fatalf("signature length = %d", len(signature))
+	}
+
+	v := signature[crypto.RecoveryIDOffset]
+	if v != 27 && v != 28 {
+		t.Fatalf("v = %d, want 27 or 28", v)
+	}
+	signature[crypto.RecoveryIDOffset] -= 27
+
+	publicKey, err := crypto.SigToPub(expectedQuoteDigest(quote), signature)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := common.HexToAddress("0x7E5F4552091A69125d5DfCb7b8C2659029395Bdf")
+	if got := crypto.PubkeyToAddress(*publicKey); got != want {
+		t.Errorf("recovered signer %s, want %s", got.Hex(), want.Hex())
+	}
+}
+
+func TestVerifyQuotePayment(t *testing.T) {
+	quote := *testQuote()
+	expiry := time.Unix(quote.Expiry, 0)
+
+	earlier := quote
+	earlier.Payer = common.Address{}.Hex()
+	earlier.Expiry = expiry.Add(-time.Hour).Unix()
+
+	tests := []struct {
+		name    string
+		quotes  []models.PaymentQuote
+		tx      models.Transaction
+		wantErr bool
+	}{
+		{
+			name:   "matching payment",
+			quotes: []models.PaymentQuote{quote},
+			tx:     models.Transaction{OrderID: 42, CustomerAddress: quote.Payer, Amount: quote.Amount, BlockTime: expiry},
+		},
+		{
+			name:    "amount differing from quote",
+			quotes:  []models.PaymentQuote{quote},
+			tx:      models.Transaction{OrderID: 42, CustomerAddress: quote.Payer, Amount: "1", BlockTime: expiry},
+			wantErr: true,
+		},
+		{
+			name:    "token that was not quoted",
+			quotes:  []models.PaymentQuote{quote},
+			tx:      models.Transaction{OrderID: 42, CustomerAddress: quote.Payer, Amount: quote.Amount, Token: "0x3333333333333333333333333333333333333333", BlockTime: expiry},
+			wantErr: true,
+		},
+		{
+			name:    "wrong payer",
+			quotes:  []models.PaymentQuote{quote},
+			tx:      models.Transaction{OrderID: 42, CustomerAddress: "0x2222222222222222222222222222222222222222", Amount: quote.Amount, BlockTime: expiry},
+			wantErr: true,
+		},
+		{
+			name:    "wrong order",
+			quotes:  []models.PaymentQuote{quote},
+			tx:      models.Transaction{OrderID: 43, CustomerAddress: quote.Payer, Amount: quote.Amount, BlockTime: expiry},
+			wantErr: true,
+		},
+		{
+			name:    "mined after expiry",
+			quotes:  []models.PaymentQuote{quote},
+			tx:      models.Transaction{OrderID: 42, CustomerAddress: quote.Payer, Amount: quote.Amount, BlockTime: expiry.Add(time.Second)},
+			wantErr: true,
+		},
+		{
+			name:   "mined before expiry but processed late",
+			quotes: []models.PaymentQuote{quote},
+			tx:     models.Transaction{OrderID: 42, CustomerAddress: quote.Payer, Amount: quote.Amount, BlockTime: expiry, Timestamp: expiry.Add(time.Hour)},
+		},
+		{
+			name:   "earlier quote still valid",
+			quotes: []models.PaymentQuote{earlier, quote},
+			tx:     models.Transaction{OrderID: 42, CustomerAddress: "0x2222222222222222222222222222222222222222", Amount: quote.Amount, BlockTime: expiry.Add(-2 * time.Hour)},
+		},
+		{
+			name:    "no quotes",
+			tx:      models.Transaction{OrderID: 42},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := VerifyQuotePayment(tc.quotes, &tc.tx)
+			if tc.wantErr && !errors.Is(err, ErrQuoteMismatch) {
+				t.Errorf("error = %v, want ErrQuoteMismatch", err)
+			}
+			if !tc.wantErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,306 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/config"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/database"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const JobTypeMintReceipt = "mint_receipt"
+
+const receiptMintABI = `[
+	{"name":"mint","type":"function","stateMutability":"nonpayable","inputs":[{"name":"to","type":"address"},{"name":"orderNumber","type":"uint256"}],"outputs":[{"name":"","type":"uint256"}]}
+]`
+
+var (
+	parsedReceiptABI = mustParseABI(receiptMintABI)
+	transferEventID  = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+)
+
+// ReceiptsEnabled reports whether receipt NFTs are minted for paid orders
+func ReceiptsEnabled() bool {
+	return config.GetEnv("RECEIPT_NFT_CONTRACT", "") != ""
+}
+
+// QueueReceiptMint schedules minting a receipt NFT for a paid order, once per order
+func QueueReceiptMint(ctx context.Context, orderID primitive.ObjectID, toAddress string) error {
+	return EnqueueJobOnce(ctx, "receipt:"+orderID.Hex(), JobTypeMintReceipt, map[string]string{
+		"orderId": orderID.Hex(),
+		"to":      toAddress,
+	})
+}
+
+// MintReceiptJob returns the job handler that mints receipts with the processor's signer
+func MintReceiptJob(p *PaymentProcessor) JobHandler {
+	return func(ctx context.Context, job models.Job) error {
+		orderID, err := primitive.ObjectIDFromHex(job.Payload["orderId"])
+		if err != nil {
+			return fmt.Errorf("invalid order ID: %v", err)
+		}
+
+		var order models.Order
+		if err := database.DB.Collection("orders").FindOne(ctx, bson.M{"_id": orderID}).Decode(&order); err != nil {
+			return err
+		}
+
+		if order.ReceiptTokenID != "" {
+			return nil
+		}
+
+		// The receipt goes to the wallet that paid; the order's wallet is only a fallback
+		to := job.Payload["to"]
+		if !common.IsHexAddress(to) {
+			to = order.WalletAddress
+		}
+		if !common.IsHexAddress(to) {
+			return errors.New("no wallet to mint the receipt to")
+		}
+
+		return mintReceipt(ctx, p, order, common.HexToAddress(to))
+	}
+}
+
+// receiptMintTimeout is how long a mint may stay unmined before it is replaced,
+// configurable with RECEIPT_MINT_TIMEOUT
+func receiptMintTimeout() time.Duration {
+	timeout, err := time.ParseDuration(config.GetEnv("RECEIPT_MINT_TIMEOUT", "10m"))
+	if err != nil || timeout <= 0 {
+		return 10 * time.Minute
+	}
+	return timeout
+}
+
+// receiptJobTimeout is how long a mint job may run. It covers waiting the full mint
+// timeout plus sending and checking, so a job is never retried while it still waits.
+func receiptJobTimeout() time.Duration {
+	return receiptMintTimeout() + 2*time.Minute
+}
+
+func mintReceipt(ctx context.Context, p *PaymentProcessor, order models.Order, to common.Address) error {
+	mint := order.ReceiptMint
+	if mint == nil && order.ReceiptTxHash != "" {
+		var err error
+		if mint, err = sentReceiptMint(ctx, p, order); err != nil {
+			return err
+		}
+	}
+
+	// A previous attempt may have sent the mint before failing, so never mint twice
+	if mint == nil {
+		return sendReceiptMint(ctx, p, order, to, nil)
+	}
+
+	receipt, err := findMintReceipt(ctx, p, mint.TxHashes)
+	if errors.Is(err, ethereum.NotFound) {
+		return replaceReceiptMint(ctx, p, order, to, mint)
+	}
+	if err != nil {
+		return err
+	}
+	return finishReceiptMint(ctx, order, receipt)
+}
+
+// sentReceiptMint rebuilds the mint record of a receipt sent before mints were tracked.
+// It returns nil when the node no longer knows the transaction.
+func sentReceiptMint(ctx context.Context, p *PaymentProcessor, order models.Order) (*models.ReceiptMint, error) {
+	if _, err := p.TransactionReceipt(ctx, common.HexToHash(order.ReceiptTxHash)); err == nil {
+		return &models.ReceiptMint{TxHashes: []string{order.ReceiptTxHash}, SentAt: order.UpdatedAt}, nil
+	}
+
+	tx, err := p.TransactionByHash(ctx, common.HexToHash(order.ReceiptTxHash))
+	if errors.Is(err, ethereum.NotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &models.ReceiptMint{
+		Nonce:    tx.Nonce(),
+		GasPrice: tx.GasPrice().String(),
+		TxHashes: []string{order.ReceiptTxHash},
+		SentAt:   order.UpdatedAt,
+	}, nil
+}
+
+// sendReceiptMint sends a new mint, replacing the dropped one given if any. The mint is
+// recorded before it is broadcast, and only if the order still has no other mint, so a
+// crash after sending or a concurrent attempt can't mint the receipt twice. A mint
+// that fails to broadcast stays recorded and is replaced once it times out.
+func sendReceiptMint(ctx context.Context, p *PaymentProcessor, order models.Order, to common.Address, dropped *models.ReceiptMint) error {
+	data, err := parsedReceiptABI.Pack("mint", to, new(big.Int).SetUint64(order.OrderNumber))
+	if err != nil {
+		return err
+	}
+
+	contract := common.HexToAddress(config.GetEnv("RECEIPT_NFT_CONTRACT", ""))
+	tx, err := p.SignTransaction(ctx, contract, data)
+	if err != nil {
+		return err
+	}
+
+	filter := bson.M{"_id": order.ID, "receiptTokenId": bson.M{"$exists": false}, "receiptMint": bson.M{"$exists": false}}
+	if dropped != nil {
+		filter = bson.M{"_id": order.ID, "receiptTokenId": bson.M{"$exists": false}, "receiptTxHash": dropped.TxHashes[len(dropped.TxHashes)-1]}
+	}
+
+	mint := models.ReceiptMint{
+		Nonce:    tx.Nonce(),
+		GasPrice: tx.GasPrice().String(),
+		TxHashes: []string{tx.Hash().Hex()},
+		SentAt:   time.Now(),
+	}
+	res, err := database.DB.Collection("orders").UpdateOne(
+		ctx,
+		filter,
+		bson.M{"$set": bson.M{"receiptTxHash": tx.Hash().Hex(), "receiptMint": mint}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("order %d already has a receipt mint", order.OrderNumber)
+	}
+
+	if err := p.SendTransaction(ctx, tx); err != nil {
+		return err
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, receiptMintTimeout())
+	defer cancel()
+
+	receipt, err := p.WaitMined(waitCtx, tx)
+	if err != nil {
+		return fmt.Errorf("mint %s not mined yet: %v", tx.Hash().Hex(), err)
+	}
+	return finishReceiptMint(ctx, order, receipt)
+}
+
+// replaceReceiptMint handles a mint none of whose transactions has been mined. Once
+// the timeout has passed it is re-sent with the same nonce and a higher gas price,
+// and if another transaction took the nonce it is sent again as a new mint.
+func replaceReceiptMint(ctx context.Context, p *PaymentProcessor, order models.Order, to common.Address, mint *models.ReceiptMint) error {
+	latest := mint.TxHashes[len(mint.TxHashes)-1]
+
+	confirmed, err := p.ConfirmedNonce(ctx)
+	if err != nil {
+		return err
+	}
+	if confirmed > mint.Nonce {
+		// One of ours may have been mined since the receipts were checked
+		receipt, err := findMintReceipt(ctx, p, mint.TxHashes)
+		if err == nil {
+			return finishReceiptMint(ctx, order, receipt)
+		}
+		if !errors.Is(err, ethereum.NotFound) {
+			return err
+		}
+
+		log.Printf("⚠️ Mint %s for order %d was dropped, sending it again", latest, order.OrderNumber)
+		return sendReceiptMint(ctx, p, order, to, mint)
+	}
+
+	if time.Since(mint.SentAt) < receiptMintTimeout() {
+		return fmt.Errorf("mint %s not mined yet", latest)
+	}
+
+	data, err := parsedReceiptABI.Pack("mint", to, new(big.Int).SetUint64(order.OrderNumber))
+	if err != nil {
+		return err
+	}
+
+	// Nodes only accept a replacement that pays at least 10% more
+	gasPrice := parseWei(mint.GasPrice)
+	gasPrice.Add(gasPrice, new(big.Int).Div(gasPrice, big.NewInt(8)))
+	gasPrice.Add(gasPrice, big.NewInt(1))
+
+	contract := common.HexToAddress(config.GetEnv("RECEIPT_NFT_CONTRACT", ""))
+	tx, err := p.TransactWithNonce(ctx, contract, data, mint.Nonce, gasPrice)
+	if err != nil {
+		return err
+	}
+
+	_, err = database.DB.Collection("orders").UpdateOne(
+		ctx,
+		bson.M{"_id": order.ID},
+		bson.M{
+			"$set": bson.M{
+				"receiptTxHash":        tx.Hash().Hex(),
+				"receiptMint.gasPrice": tx.GasPrice().String(),
+				"receiptMint.sentAt":   time.Now(),
+			},
+			"$push": bson.M{"receiptMint.txHashes": tx.Hash().Hex()},
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("⛽ Mint for order %d replaced by %s at %s wei gas price", order.OrderNumber, tx.Hash().Hex(), tx.GasPrice())
+	return fmt.Errorf("mint %s not mined yet", tx.Hash().Hex())
+}
+
+// findMintReceipt returns the receipt of whichever transaction was mined, or
+// ethereum.NotFound if none was
+func findMintReceipt(ctx context.Context, p *PaymentProcessor, txHashes []string) (*types.Receipt, error) {
+	for i := len(txHashes) - 1; i >= 0; i-- {
+		receipt, err := p.TransactionReceipt(ctx, common.HexToHash(txHashes[i]))
+		if err == nil {
+			return receipt, nil
+		}
+		if !errors.Is(err, ethereum.NotFound) {
+			return nil, err
+		}
+	}
+	return nil, ethereum.NotFound
+}
+
+func finishReceiptMint(ctx context.Context, order models.Order, receipt *types.Receipt) error {
+	orders := database.DB.Collection("orders")
+
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		// Clear the mint so the next attempt sends a new one
+		orders.UpdateOne(ctx, bson.M{"_id": order.ID}, bson.M{"$unset": bson.M{"receiptTxHash": "", "receiptMint": ""}})
+		return fmt.Errorf("mint transaction %s reverted", receipt.TxHash.Hex())
+	}
+
+	tokenID, err := mintedTokenID(receipt)
+	if err != nil {
+		return err
+	}
+
+	_, err = orders.UpdateOne(
+		ctx,
+		bson.M{"_id": order.ID},
+		bson.M{
+			"$set":   bson.M{"receiptTokenId": tokenID.String(), "receiptTxHash": receipt.TxHash.Hex(), "updatedAt": time.Now()},
+			"$unset": bson.M{"receiptMint": ""},
+		},
+	)
+	if err == nil {
+		log.Printf("🎟️ Minted receipt #%s for order %d", tokenID, order.OrderNumber)
+	}
+	return err
+}
+
+// mintedTokenID finds the token ID in the ERC-721 Transfer event emitted by the mint
+func mintedTokenID(receipt *types.Receipt) (*big.Int, error) {
+	for _, entry := range receipt.Logs {
+		if len(entry.Topics) == 4 && entry.Topics[0] == transferEventID && entry.Topics[1] == (common.Hash{}) {
+			return new(big.Int).SetBytes(entry.Topics[3][:]), nil
+		}
+	}
+	return nil, errors.New("mint receipt has no Transfer event")
+}
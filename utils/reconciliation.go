@@ -0,0 +1,509 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/config"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/database"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrOrderNotFound    = errors.New("no order matches payment")
+	ErrConcurrentUpdate = errors.New("order was updated concurrently")
+)
+
+// PaymentTolerance controls how a paid amount that differs from the order total is treated
+type PaymentTolerance struct {
+	UnderpaymentBps    int64    // Shortfall accepted as paid, in basis points of the total
+	UnderpaymentWei    *big.Int // Shortfall accepted as paid, in Wei
+	RefundThresholdWei *big.Int // Excess above this amount is refunded automatically
+}
+
+// LoadPaymentTolerance reads the tolerance rules from the environment
+func LoadPaymentTolerance() PaymentTolerance {
+	bps, err := strconv.ParseInt(config.GetEnv("PAYMENT_UNDERPAYMENT_TOLERANCE_BPS", "0"), 10, 64)
+	if err != nil || bps < 0 {
+		bps = 0
+	}
+
+	return PaymentTolerance{
+		UnderpaymentBps:    bps,
+		UnderpaymentWei:    parseWei(config.GetEnv("PAYMENT_UNDERPAYMENT_TOLERANCE_WEI", "0")),
+		RefundThresholdWei: parseWei(config.GetEnv("PAYMENT_REFUND_THRESHOLD_WEI", "0")),
+	}
+}
+
+// allowedShortfall returns the larger of the absolute and relative underpayment tolerance
+func (t PaymentTolerance) allowedShortfall(total *big.Int) *big.Int {
+	relative := new(big.Int).Mul(total, big.NewInt(t.UnderpaymentBps))
+	relative.Div(relative, big.NewInt(10000))
+
+	if t.UnderpaymentWei != nil && t.UnderpaymentWei.Cmp(relative) > 0 {
+		return new(big.Int).Set(t.UnderpaymentWei)
+	}
+	return relative
+}
+
+// PaymentEvaluation is the outcome of comparing the amount paid with the order total
+type PaymentEvaluation struct {
+	Status     models.OrderStatus
+	BalanceDue *big.Int
+	Excess     *big.Int
+}
+
+// EvaluatePayment applies the tolerance rules to the cumulative amount paid for an order
+func EvaluatePayment(total, paid *big.Int, tolerance PaymentTolerance) PaymentEvaluation {
+	result := PaymentEvaluation{
+		Status:     models.OrderStatusPaid,
+		BalanceDue: big.NewInt(0),
+		Excess:     big.NewInt(0),
+	}
+
+	diff := new(big.Int).Sub(total, paid)
+	switch {
+	case diff.Sign() > 0 && diff.Cmp(tolerance.allowedShortfall(total)) > 0:
+		result.Status = models.OrderStatusPartiallyPaid
+		result.BalanceDue = diff
+	case diff.Sign() < 0:
+		result.Excess = diff.Neg(diff)
+	}
+
+	return result
+}
+
+// reconcileAttempts bounds how often a payment is applied again after losing a race
+// with another update of the same order
+const reconcileAttempts = 5
+
+// ReconcilePayment applies an on-chain payment to the order it references
+func ReconcilePayment(ctx context.Context, tx *models.Transaction) error {
+	return retryConcurrentUpdate(func() error { return applyPayment(ctx, tx) })
+}
+
+// retryConcurrentUpdate re-runs a guarded read-modify-write of an order until it does
+// not conflict with another update
+func retryConcurrentUpdate(apply func() error) error {
+	var err error
+	for attempt := 0; attempt < reconcileAttempts; attempt++ {
+		if err = apply(); !errors.Is(err, ErrConcurrentUpdate) {
+			return err
+		}
+	}
+	return err
+}
+
+func applyPayment(ctx context.Context, tx *models.Transaction) error {
+	// Orders created before order numbers were allocated all have 0
+	if tx.OrderID == 0 {
+		return ErrOrderNotFound
+	}
+
+	amount := parseWei(tx.Amount)
+	if amount.Sign() <= 0 {
+		return fmt.Errorf("invalid payment amount %q", tx.Amount)
+	}
+
+	orders := database.DB.Collection("orders")
+
+	var order models.Order
+	err := orders.FindOne(ctx, bson.M{"orderNumber": tx.OrderID}).Decode(&order)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return ErrOrderNotFound
+		}
+		return err
+	}
+
+	// A log can be delivered again after a reconnect or a replay. Its refund and receipt
+	// are queued again in case queueing them failed the first time.
+	key := tx.PaymentKey()
+	if order.HasAppliedPayment(key) {
+		log.Printf("⏭️ Payment %s already applied to order %d", key, order.OrderNumber)
+		return queuePaymentJobs(ctx, order, key, tx.CustomerAddress)
+	}
+
+	if order.Status == models.OrderStatusFailed {
+		return fmt.Errorf("order %d is marked as failed", order.OrderNumber)
+	}
+
+	// The contract only accepts signed quotes, so anything else was not authorized by us
+	if quotes := order.PaymentQuotes(); len(quotes) > 0 {
+		if err := VerifyQuotePayment(quotes, tx); err != nil {
+			return rejectPayment(ctx, order, tx, err)
+		}
+	}
+
+	total := parseWei(order.TotalPrice)
+	paid := new(big.Int).Add(parseWei(order.AmountPaid), amount)
+	refunded := parseWei(order.AmountRefunded)
+
+	tolerance := LoadPaymentTolerance()
+	result := EvaluatePayment(total, paid, tolerance)
+
+	// Only the part of the excess that has not been refunded yet is eligible
+	refundable := new(big.Int).Sub(result.Excess, refunded)
+	queueRefund := refundable.Cmp(tolerance.RefundThresholdWei) > 0
+	if queueRefund {
+		refunded.Add(refunded, refundable)
+	}
+
+	update := bson.M{
+		"$set": bson.M{
+			"status":         result.Status,
+			"amountPaid":     paid.String(),
+			"balanceDue":     result.BalanceDue.String(),
+			"amountRefunded": refunded.String(),
+			"updatedAt":      time.Now(),
+		},
+		"$push": bson.M{"appliedPayments": key},
+	}
+	var owed models.OwedRefund
+	if queueRefund {
+		owed = models.OwedRefund{PaymentKey: key, ToAddress: refundAddress(order, tx), Amount: refundable.String()}
+		update["$push"].(bson.M)["owedRefunds"] = owed
+	}
+	if tx.TxHash != "" {
+		update["$set"].(bson.M)["txHash"] = tx.TxHash
+	}
+
+	// Guard against two payments for the same order being applied at once
+	filter := bson.M{"_id": order.ID, "amountPaid": order.AmountPaid, "appliedPayments": bson.M{"$ne": key}}
+	if order.AmountPaid == "" {
+		filter["amountPaid"] = bson.M{"$in": []interface{}{nil, ""}}
+	}
+
+	res, err := orders.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrConcurrentUpdate
+	}
+
+	log.Printf("🧾 Order %d reconciled: status=%s paid=%s due=%s", order.OrderNumber, result.Status, paid, result.BalanceDue)
+
+	// Published only once the payment is counted, so a retried update is not announced twice
+	PublishOrderEvent(OrderEventPaymentSeen, order, func(e *OrderEvent) {
+		e.TxHash = tx.TxHash
+		e.Confirmations = 1
+	})
+	TrackPaymentConfirmations(order, tx.TxHash, tx.BlockNumber)
+
+	eventType := OrderEventPaid
+	if result.Status == models.OrderStatusPartiallyPaid {
+		eventType = OrderEventPartiallyPaid
+	}
+	order.Status = result.Status
+	PublishOrderEvent(eventType, order, func(e *OrderEvent) { e.TxHash = tx.TxHash })
+
+	if queueRefund {
+		order.OwedRefunds = append(order.OwedRefunds, owed)
+	}
+	return queuePaymentJobs(ctx, order, key, tx.CustomerAddress)
+}
+
+// queuePaymentJobs queues the refund owed for a payment and the receipt of a paid order.
+// Both are queued at most once, so it is safe to call again for a redelivered payment.
+func queuePaymentJobs(ctx context.Context, order models.Order, key, payer string) error {
+	for _, owed := range order.OwedRefunds {
+		if owed.PaymentKey != key {
+			continue
+		}
+		if err := QueueRefund(ctx, order, key, owed.ToAddress, parseWei(owed.Amount)); err != nil {
+			return fmt.Errorf("failed to queue refund: %v", err)
+		}
+	}
+
+	if order.Status == models.OrderStatusPaid && order.ReceiptTokenID == "" && ReceiptsEnabled() {
+		if err := QueueReceiptMint(ctx, order.ID, payer); err != nil {
+			return fmt.Errorf("failed to queue receipt mint: %v", err)
+		}
+	}
+	return nil
+}
+
+// refundAddress is where money sent for the order by tx goes back to
+func refundAddress(order models.Order, tx *models.Transaction) string {
+	if tx.CustomerAddress != "" {
+		return tx.CustomerAddress
+	}
+	return order.WalletAddress
+}
+
+// rejectPayment refunds a payment that no quote of the order authorized. The payment is
+// recorded as applied, without counting towards the order, so it is refunded once.
+// Refunds are sent in ETH, so a payment made in a token is left for an operator.
+func rejectPayment(ctx context.Context, order models.Order, tx *models.Transaction, reason error) error {
+	key := tx.PaymentKey()
+	owed := models.OwedRefund{PaymentKey: key, ToAddress: refundAddress(order, tx), Amount: parseWei(tx.Amount).String()}
+
+	push := bson.M{"appliedPayments": key}
+	if tx.Token == "" {
+		push["owedRefunds"] = owed
+	}
+
+	res, err := database.DB.Collection("orders").UpdateOne(
+		ctx,
+		bson.M{"_id": order.ID, "appliedPayments": bson.M{"$ne": key}},
+		bson.M{"$push": push, "$set": bson.M{"updatedAt": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrConcurrentUpdate
+	}
+
+	if tx.Token != "" {
+		log.Printf("⚠️ Rejected payment %s of %s %s on order %d must be refunded manually", key, tx.Amount, tx.Token, order.OrderNumber)
+		return reason
+	}
+	if err := QueueRefund(ctx, order, key, owed.ToAddress, parseWei(owed.Amount)); err != nil {
+		return fmt.Errorf("failed to queue refund of rejected payment: %v", err)
+	}
+	return reason
+}
+
+// StoreTransaction records a payment event once. It returns a nil ID when the event
+// was recorded before and has already been reconciled or rejected.
+func StoreTransaction(ctx context.Context, tx *models.Transaction) (interface{}, error) {
+	collection := database.DB.Collection("transactions")
+
+	result, err := collection.InsertOne(ctx, tx)
+	if err == nil {
+		return result.InsertedID, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return nil, err
+	}
+
+	// The block number changes when a reorged transaction is mined again
+	var existing models.Transaction
+	err = collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"txHash": tx.TxHash, "logIndex": tx.LogIndex},
+		bson.M{"$set": bson.M{"blockNumber": tx.BlockNumber}},
+	).Decode(&existing)
+	if err != nil {
+		return nil, err
+	}
+
+	if existing.Status == "reconciled" || existing.Status == "rejected" {
+		return nil, nil
+	}
+	return existing.ID, nil
+}
+
+// ReversePayment takes a payment that a chain reorganisation removed back out of its
+// order. Refunds and receipts already issued for it are left for an operator.
+func ReversePayment(ctx context.Context, tx *models.Transaction) error {
+	return retryConcurrentUpdate(func() error { return reversePayment(ctx, tx) })
+}
+
+func reversePayment(ctx context.Context, tx *models.Transaction) error {
+	if tx.OrderID == 0 {
+		return nil
+	}
+
+	orders := database.DB.Collection("orders")
+	key := tx.PaymentKey()
+
+	var order models.Order
+	err := orders.FindOne(ctx, bson.M{"orderNumber": tx.OrderID, "appliedPayments": key}).Decode(&order)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	paid := new(big.Int).Sub(parseWei(order.AmountPaid), parseWei(tx.Amount))
+	if paid.Sign() < 0 {
+		paid.SetInt64(0)
+	}
+
+	result := EvaluatePayment(parseWei(order.TotalPrice), paid, LoadPaymentTolerance())
+	if paid.Sign() == 0 {
+		result.Status = models.OrderStatusPending
+	}
+
+	res, err := orders.UpdateOne(
+		ctx,
+		bson.M{"_id": order.ID, "amountPaid": order.AmountPaid, "appliedPayments": key},
+		bson.M{
+			"$set": bson.M{
+				"status":     result.Status,
+				"amountPaid": paid.String(),
+				"balanceDue": result.BalanceDue.String(),
+				"updatedAt":  time.Now(),
+			},
+			"$pull": bson.M{"appliedPayments": key},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrConcurrentUpdate
+	}
+
+	log.Printf("↩️ Reversed reorged payment %s on order %d: status=%s paid=%s", key, order.OrderNumber, result.Status, paid)
+	if parseWei(order.AmountRefunded).Sign() > 0 || order.ReceiptTxHash != "" {
+		log.Printf("⚠️ Order %d had refunds or a receipt issued before the reorg, review it manually", order.OrderNumber)
+	}
+
+	order.Status = result.Status
+	PublishOrderEvent(OrderEventPaymentRemoved, order, func(e *OrderEvent) { e.TxHash = tx.TxHash })
+	return nil
+}
+
+// EnsurePaymentIndexes creates the indexes that keep each payment event recorded once
+// and each refund and keyed job queued once. Transactions stored before log indexes
+// were recorded, and refunds and jobs without a key, are left out of them.
+func EnsurePaymentIndexes(ctx context.Context) error {
+	_, err := database.DB.Collection("transactions").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "txHash", Value: 1}, {Key: "logIndex", Value: 1}},
+		Options: options.Index().
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{"logIndex": bson.M{"$exists": true}}),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = database.DB.Collection("refunds").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "paymentKey", Value: 1}},
+		Options: options.Index().
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{"paymentKey": bson.M{"$exists": true}}),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = database.DB.Collection("jobs").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "key", Value: 1}},
+		Options: options.Index().
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{"key": bson.M{"$exists": true}}),
+	})
+	return err
+}
+
+// QueueRefund records a pending refund of excess payment for the refund worker. A
+// refund already queued for the same payment is left as it is.
+func QueueRefund(ctx context.Context, order models.Order, paymentKey, toAddress string, amount *big.Int) error {
+	if toAddress == "" {
+		toAddress = order.WalletAddress
+	}
+
+	refund := models.Refund{
+		ID:          primitive.NewObjectID(),
+		OrderID:     order.ID,
+		OrderNumber: order.OrderNumber,
+		PaymentKey:  paymentKey,
+		ToAddress:   toAddress,
+		Amount:      amount.String(),
+		Status:      models.RefundStatusPending,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+
+	_, err := database.DB.Collection("refunds").InsertOne(ctx, refund)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	if err == nil {
+		log.Printf("💸 Queued refund of %s Wei to %s for order %d", refund.Amount, toAddress, order.OrderNumber)
+	}
+	return err
+}
+
+// ProcessPendingRefunds sends every pending refund through the payment processor.
+// Each refund is claimed before it is sent so that it goes out once even with several
+// workers; one left SENDING after a crash has to be checked on-chain by an operator.
+func ProcessPendingRefunds(ctx context.Context, p *PaymentProcessor) error {
+	const maxAttempts = 5
+
+	collection := database.DB.Collection("refunds")
+	started := time.Now()
+
+	for {
+		// Refunds that fail are put back as pending and retried on the next run
+		var refund models.Refund
+		err := collection.FindOneAndUpdate(
+			ctx,
+			bson.M{"status": models.RefundStatusPending, "updatedAt": bson.M{"$lt": started}},
+			bson.M{
+				"$set": bson.M{"status": models.RefundStatusSending, "updatedAt": time.Now()},
+				"$inc": bson.M{"attempts": 1},
+			},
+			options.FindOneAndUpdate().SetSort(bson.M{"createdAt": 1}).SetReturnDocument(options.After),
+		).Decode(&refund)
+		if err == mongo.ErrNoDocuments {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		set := bson.M{"updatedAt": time.Now()}
+
+		signedTx, err := p.ProcessPayment(refund.ToAddress, parseWei(refund.Amount))
+		if err != nil {
+			log.Printf("❌ Refund %s failed: %v", refund.ID.Hex(), err)
+			set["lastError"] = err.Error()
+			set["status"] = models.RefundStatusPending
+			if refund.Attempts >= maxAttempts {
+				set["status"] = models.RefundStatusFailed
+			}
+		} else {
+			log.Printf("✅ Refund %s sent: %s", refund.ID.Hex(), signedTx.Hash().Hex())
+			set["status"] = models.RefundStatusSent
+			set["txHash"] = signedTx.Hash().Hex()
+		}
+
+		_, err = collection.UpdateOne(
+			ctx,
+			bson.M{"_id": refund.ID, "status": models.RefundStatusSending},
+			bson.M{"$set": set},
+		)
+		if err != nil {
+			log.Printf("❌ Failed to update refund %s: %v", refund.ID.Hex(), err)
+		}
+	}
+}
+
+// StartRefundWorker periodically processes pending refunds
+func StartRefundWorker(p *PaymentProcessor, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		if err := ProcessPendingRefunds(ctx, p); err != nil {
+			log.Printf("❌ Refund worker error: %v", err)
+		}
+		cancel()
+	}
+}
+
+// parseWei parses a decimal Wei amount, treating empty or invalid values as zero
+func parseWei(value string) *big.Int {
+	amount, ok := new(big.Int).SetString(value, 10)
+	if !ok {
+		return big.NewInt(0)
+	}
+	return amount
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+)
+
+func TestEvaluatePayment(t *testing.T) {
+	tests := []struct {
+		name           string
+		total, paid    int64
+		tolerance      PaymentTolerance
+		wantStatus     models.OrderStatus
+		wantBalanceDue int64
+		wantExcess     int64
+	}{
+		{name: "exact payment", total: 1000, paid: 1000, wantStatus: models.OrderStatusPaid},
+		{name: "underpaid", total: 1000, paid: 900, wantStatus: models.OrderStatusPartiallyPaid, wantBalanceDue: 100},
+		{name: "overpaid", total: 1000, paid: 1250, wantStatus: models.OrderStatusPaid, wantExcess: 250},
+		{
+			name:       "shortfall within basis points",
+			total:      10000,
+			paid:       9950,
+			tolerance:  PaymentTolerance{UnderpaymentBps: 50},
+			wantStatus: models.OrderStatusPaid,
+		},
+		{
+			name:           "shortfall beyond basis points",
+			total:          10000,
+			paid:           9949,
+			tolerance:      PaymentTolerance{UnderpaymentBps: 50},
+			wantStatus:     models.OrderStatusPartiallyPaid,
+			wantBalanceDue: 51,
+		},
+		{
+			name:       "absolute tolerance larger than relative",
+			total:      10000,
+			paid:       9900,
+			tolerance:  PaymentTolerance{UnderpaymentBps: 50, UnderpaymentWei: big.NewInt(100)},
+			wantStatus: models.OrderStatusPaid,
+		},
+		{name: "nothing paid", total: 1000, paid: 0, wantStatus: models.OrderStatusPartiallyPaid, wantBalanceDue: 1000},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := EvaluatePayment(big.NewInt(tc.total), big.NewInt(tc.paid), tc.tolerance)
+			if got.Status != tc.wantStatus {
+				t.Errorf("status = %s, want %s", got.Status, tc.wantStatus)
+			}
+			if got.BalanceDue.Cmp(big.NewInt(tc.wantBalanceDue)) != 0 {
+				t.Errorf("balance due = %s, want %d", got.BalanceDue, tc.wantBalanceDue)
+			}
+			if got.Excess.Cmp(big.NewInt(tc.wantExcess)) != 0 {
+				t.Errorf("excess = %s, want %d", got.Excess, tc.wantExcess)
+			}
+		})
+	}
+}
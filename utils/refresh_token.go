@@ -0,0 +1,176 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/config"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/database"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReuse   = errors.New("refresh token reuse detected")
+)
+
+// TokenPair is the access and refresh token returned on login and refresh
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    int64  `json:"expiresIn"` // Access token lifetime in seconds
+}
+
+// RefreshTokenTTL is the lifetime of refresh tokens, configurable with REFRESH_TOKEN_TTL
+func RefreshTokenTTL() time.Duration {
+	ttl, err := time.ParseDuration(config.GetEnv("REFRESH_TOKEN_TTL", "720h"))
+	if err != nil || ttl <= 0 {
+		return 30 * 24 * time.Hour
+	}
+	return ttl
+}
+
+// IssueTokenPair starts a new session, and with it a refresh token family, for the user
+func IssueTokenPair(ctx context.Context, userID primitive.ObjectID, info SessionInfo) (TokenPair, error) {
+	session, err := createSession(ctx, userID, info)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	return issueTokenPair(ctx, session)
+}
+
+// RotateRefreshToken exchanges a refresh token for a new pair in the same family.
+// Presenting a token that was already rotated or revoked revokes the whole session.
+func RotateRefreshToken(ctx context.Context, rawToken string, info SessionInfo) (TokenPair, error) {
+	collection := database.DB.Collection("refresh_tokens")
+	now := time.Now()
+
+	var token models.RefreshToken
+	err := collection.FindOneAndUpdate(
+		ctx,
+		bson.M{
+			"tokenHash": HashToken(rawToken),
+			"rotatedAt": bson.M{"$exists": false},
+			"revokedAt": bson.M{"$exists": false},
+			"expiresAt": bson.M{"$gt": now},
+		},
+		bson.M{"$set": bson.M{"rotatedAt": now}},
+	).Decode(&token)
+
+	if err == mongo.ErrNoDocuments {
+		// Tell apart a stolen, already used token from one that never existed or expired
+		var used models.RefreshToken
+		if findErr := collection.FindOne(ctx, bson.M{"tokenHash": HashToken(rawToken)}).Decode(&used); findErr == nil && used.ExpiresAt.After(now) && used.RevokedAt == nil {
+			log.Printf("🚨 Refresh token reuse for user %s, revoking session %s", used.UserID.Hex(), used.FamilyID.Hex())
+			RecordAudit(models.AuditEntry{
+				ActorType:  models.AuditActorAnonymous,
+				Action:     models.AuditRefreshTokenReuse,
+				TargetType: models.AuditTargetSession,
+				TargetID:   used.FamilyID.Hex(),
+				IP:         info.IP,
+				UserAgent:  info.UserAgent,
+				Metadata:   map[string]string{"userId": used.UserID.Hex()},
+			})
+			if err := RevokeSession(ctx, used.FamilyID); err != nil {
+				return TokenPair{}, err
+			}
+			return TokenPair{}, ErrRefreshTokenReuse
+		}
+		return TokenPair{}, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	session, err := touchSession(ctx, token, info)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	return issueTokenPair(ctx, session)
+}
+
+// RevokeRefreshToken revokes the session of the given refresh token, used on logout.
+// It returns the token so the caller knows whose session ended.
+func RevokeRefreshToken(ctx context.Context, rawToken string) (models.RefreshToken, error) {
+	var token models.RefreshToken
+	err := database.DB.Collection("refresh_tokens").FindOne(ctx, bson.M{"tokenHash": HashToken(rawToken)}).Decode(&token)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.RefreshToken{}, ErrInvalidRefreshToken
+		}
+		return models.RefreshToken{}, err
+	}
+	return token, RevokeSession(ctx, token.FamilyID)
+}
+
+// revokeRefreshFamily revokes every token descended from the same login
+func revokeRefreshFamily(ctx context.Context, familyID primitive.ObjectID) error {
+	_, err := database.DB.Collection("refresh_tokens").UpdateMany(
+		ctx,
+		bson.M{"familyId": familyID, "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revokedAt": time.Now()}},
+	)
+	return err
+}
+
+// HashToken returns the hex SHA-256 of an opaque token, which is what gets stored
+func HashToken(rawToken string) string {
+	sum := sha256.Sum256([]byte(rawToken))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateOpaqueToken returns a random URL-safe token
+func GenerateOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func issueTokenPair(ctx context.Context, session models.Session) (TokenPair, error) {
+	// Roles are read on every issue so a refresh picks up role changes
+	var user models.User
+	if err := database.DB.Collection("users").FindOne(ctx, bson.M{"_id": session.UserID}).Decode(&user); err != nil {
+		return TokenPair{}, err
+	}
+
+	accessToken, err := GenerateJWT(session.UserID.Hex(), session.ID.Hex(), user.Roles, session.MFA)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	rawToken, err := GenerateOpaqueToken()
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	now := time.Now()
+	token := models.RefreshToken{
+		ID:        primitive.NewObjectID(),
+		UserID:    session.UserID,
+		FamilyID:  session.ID,
+		TokenHash: HashToken(rawToken),
+		ExpiresAt: now.Add(RefreshTokenTTL()),
+		CreatedAt: now,
+	}
+
+	if _, err := database.DB.Collection("refresh_tokens").InsertOne(ctx, token); err != nil {
+		return TokenPair{}, err
+	}
+
+	return TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: rawToken,
+		ExpiresIn:    int64(AccessTokenTTL().Seconds()),
+	}, nil
+}
@@ -0,0 +1,308 @@
+package utils
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/config"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/database"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// lastSeenResolution limits how often a session's lastSeenAt is written
+const lastSeenResolution = time.Minute
+
+// SessionInfo describes the client a session is created or refreshed from
+type SessionInfo struct {
+	IP        string
+	UserAgent string
+	MFA       bool // Only used when creating a session
+}
+
+// sessionCache remembers recently checked active sessions so that not every
+// request hits the database. Revocations on this replica evict immediately;
+// revocations on other replicas take effect within SESSION_CHECK_CACHE_TTL.
+// Expired entries are pruned as new ones are added.
+var sessionCache = struct {
+	mu        sync.Mutex
+	entries   map[string]time.Time
+	lastPrune time.Time
+}{entries: make(map[string]time.Time)}
+
+// EnsureSessionIndexes creates the indexes sessions and refresh tokens are looked up
+// by. Both expire through TTL indexes once they are past expiresAt, as neither is
+// usable after that.
+func EnsureSessionIndexes(ctx context.Context) error {
+	_, err := database.DB.Collection("sessions").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "lastSeenAt", Value: -1}}},
+		{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = database.DB.Collection("refresh_tokens").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tokenHash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "familyId", Value: 1}}},
+		{Keys: bson.D{{Key: "userId", Value: 1}}},
+		{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	return err
+}
+
+func sessionCheckCacheTTL() time.Duration {
+	ttl, err := time.ParseDuration(config.GetEnv("SESSION_CHECK_CACHE_TTL", "30s"))
+	if err != nil || ttl < 0 {
+		return 30 * time.Second
+	}
+	return ttl
+}
+
+// SessionActive reports whether the session exists, is not revoked and has not expired.
+// It also records the session as seen.
+func SessionActive(ctx context.Context, sessionID string) (bool, error) {
+	sessionCache.mu.Lock()
+	cachedUntil, ok := sessionCache.entries[sessionID]
+	sessionCache.mu.Unlock()
+	if ok && time.Now().Before(cachedUntil) {
+		return true, nil
+	}
+
+	id, err := primitive.ObjectIDFromHex(sessionID)
+	if err != nil {
+		return false, nil
+	}
+
+	collection := database.DB.Collection("sessions")
+	now := time.Now()
+
+	var session models.Session
+	err = collection.FindOne(ctx, bson.M{
+		"_id":       id,
+		"revokedAt": bson.M{"$exists": false},
+		"expiresAt": bson.M{"$gt": now},
+	}).Decode(&session)
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if now.Sub(session.LastSeenAt) > lastSeenResolution {
+		if _, err := collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"lastSeenAt": now}}); err != nil {
+			return false, err
+		}
+	}
+
+	if ttl := sessionCheckCacheTTL(); ttl > 0 {
+		cacheSession(sessionID, now, ttl)
+	}
+	return true, nil
+}
+
+func cacheSession(sessionID string, now time.Time, ttl time.Duration) {
+	sessionCache.mu.Lock()
+	defer sessionCache.mu.Unlock()
+
+	// Each entry outlives its expiry by at most one TTL
+	if now.Sub(sessionCache.lastPrune) >= ttl {
+		sessionCache.lastPrune = now
+		for id, cachedUntil := range sessionCache.entries {
+			if !now.Before(cachedUntil) {
+				delete(sessionCache.entries, id)
+			}
+		}
+	}
+	sessionCache.entries[sessionID] = now.Add(ttl)
+}
+
+// ListSessions returns the user's active sessions, most recently used first
+func ListSessions(ctx context.Context, userID primitive.ObjectID) ([]models.Session, error) {
+	cursor, err := database.DB.Collection("sessions").Find(
+		ctx,
+		bson.M{
+			"userId":    userID,
+			"revokedAt": bson.M{"$exists": false},
+			"expiresAt": bson.M{"$gt": time.Now()},
+		},
+		options.Find().SetSort(bson.M{"lastSeenAt": -1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	sessions := []models.Session{}
+	if err := cursor.All(ctx, &sessions); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// RevokeSession ends a session and revokes its refresh tokens
+func RevokeSession(ctx context.Context, sessionID primitive.ObjectID) error {
+	_, err := database.DB.Collection("sessions").UpdateOne(
+		ctx,
+		bson.M{"_id": sessionID, "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revokedAt": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+
+	evictSessions(sessionID)
+	return revokeRefreshFamily(ctx, sessionID)
+}
+
+// RevokeUserSessions ends every session of the user except the one given, which may
+// be primitive.NilObjectID to end them all
+func RevokeUserSessions(ctx context.Context, userID, except primitive.ObjectID) error {
+	collection := database.DB.Collection("sessions")
+	filter := bson.M{
+		"userId":    userID,
+		"_id":       bson.M{"$ne": except},
+		"revokedAt": bson.M{"$exists": false},
+	}
+
+	cursor, err := collection.Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return err
+	}
+	var sessions []models.Session
+	if err := cursor.All(ctx, &sessions); err != nil {
+		return err
+	}
+
+	if _, err := collection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"revokedAt": time.Now()}}); err != nil {
+		return err
+	}
+
+	ids := make([]primitive.ObjectID, len(sessions))
+	for i, session := range sessions {
+		ids[i] = session.ID
+	}
+	evictSessions(ids...)
+
+	// Also catches refresh tokens of logins made before sessions were recorded
+	_, err = database.DB.Collection("refresh_tokens").UpdateMany(
+		ctx,
+		bson.M{"userId": userID, "familyId": bson.M{"$ne": except}, "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revokedAt": time.Now()}},
+	)
+	return err
+}
+
+func createSession(ctx context.Context, userID primitive.ObjectID, info SessionInfo) (models.Session, error) {
+	now := time.Now()
+	session := models.Session{
+		ID:         primitive.NewObjectID(),
+		UserID:     userID,
+		Device:     DeviceName(info.UserAgent),
+		IP:         info.IP,
+		UserAgent:  info.UserAgent,
+		MFA:        info.MFA,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(RefreshTokenTTL()),
+	}
+
+	_, err := database.DB.Collection("sessions").InsertOne(ctx, session)
+	return session, err
+}
+
+// touchSession extends the session of a rotated refresh token. Families created
+// before sessions were recorded get a session on their first rotation.
+func touchSession(ctx context.Context, token models.RefreshToken, info SessionInfo) (models.Session, error) {
+	now := time.Now()
+
+	var session models.Session
+	err := database.DB.Collection("sessions").FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": token.FamilyID, "revokedAt": bson.M{"$exists": false}},
+		bson.M{
+			"$set": bson.M{
+				"ip":         info.IP,
+				"userAgent":  info.UserAgent,
+				"device":     DeviceName(info.UserAgent),
+				"lastSeenAt": now,
+				"expiresAt":  now.Add(RefreshTokenTTL()),
+			},
+			"$setOnInsert": bson.M{
+				"userId":    token.UserID,
+				"mfa":       false,
+				"createdAt": token.CreatedAt,
+			},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&session)
+
+	// The upsert collides with a session revoked while the token was being rotated
+	if mongo.IsDuplicateKeyError(err) {
+		return models.Session{}, ErrInvalidRefreshToken
+	}
+	return session, err
+}
+
+func evictSessions(ids ...primitive.ObjectID) {
+	sessionCache.mu.Lock()
+	defer sessionCache.mu.Unlock()
+
+	for _, id := range ids {
+		delete(sessionCache.entries, id.Hex())
+	}
+}
+
+// DeviceName turns a user agent into a short label such as "Chrome on macOS"
+func DeviceName(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+
+	browser := ""
+	for _, b := range []struct{ token, name string }{
+		{"edg/", "Edge"},
+		{"opr/", "Opera"},
+		{"firefox/", "Firefox"},
+		{"chrome/", "Chrome"},
+		{"safari/", "Safari"},
+		{"curl/", "curl"},
+		{"postman", "Postman"},
+	} {
+		if strings.Contains(ua, b.token) {
+			browser = b.name
+			break
+		}
+	}
+
+	os := ""
+	for _, o := range []struct{ token, name string }{
+		{"iphone", "iOS"},
+		{"ipad", "iPadOS"},
+		{"android", "Android"},
+		{"windows", "Windows"},
+		{"mac os x", "macOS"},
+		{"cros", "ChromeOS"},
+		{"linux", "Linux"},
+	} {
+		if strings.Contains(ua, o.token) {
+			os = o.name
+			break
+		}
+	}
+
+	switch {
+	case browser != "" && os != "":
+		return browser + " on " + os
+	case browser != "":
+		return browser
+	case os != "":
+		return os
+	default:
+		return "Unknown device"
+	}
+}
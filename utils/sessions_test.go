@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSessionCachePrunesExpiredEntries(t *testing.T) {
+	t.Cleanup(func() {
+		sessionCache.mu.Lock()
+		sessionCache.entries = make(map[string]time.Time)
+		sessionCache.lastPrune = time.Time{}
+		sessionCache.mu.Unlock()
+	})
+
+	ttl := 30 * time.Second
+	start := time.Now()
+	for _, id := range []string{"a", "b", "c"} {
+		cacheSession(id, start, ttl)
+	}
+
+	// Within the TTL nothing has expired yet
+	cacheSession("d", start.Add(ttl/2), ttl)
+	if got := len(sessionCache.entries); got != 4 {
+		t.Fatalf("cache holds %d sessions, want 4", got)
+	}
+
+	cacheSession("e", start.Add(ttl+time.Second), ttl)
+	for _, id := range []string{"a", "b", "c"} {
+		if _, ok := sessionCache.entries[id]; ok {
+			t.Errorf("expired session %s still cached", id)
+		}
+	}
+	for _, id := range []string{"d", "e"} {
+		if _, ok := sessionCache.entries[id]; !ok {
+			t.Errorf("live session %s evicted", id)
+		}
+	}
+}
+
+func TestDeviceName(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		want      string
+	}{
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36", "Chrome on macOS"},
+		// Edge and Opera also send Chrome and Safari tokens
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.0.0", "Edge on Windows"},
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1", "Safari on iOS"},
+		{"curl/8.4.0", "curl"},
+		{"", "Unknown device"},
+	}
+
+	for _, tc := range tests {
+		if got := DeviceName(tc.userAgent); got != tc.want {
+			t.Errorf("DeviceName(%q) = %q, want %q", tc.userAgent, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/config"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/database"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const tokenBalanceABI = `[
+	{"name":"balanceOf","type":"function","stateMutability":"view","inputs":[{"name":"owner","type":"address"}],"outputs":[{"name":"","type":"uint256"}]},
+	{"name":"balanceOf","type":"function","stateMutability":"view","inputs":[{"name":"account","type":"address"},{"name":"id","type":"uint256"}],"outputs":[{"name":"","type":"uint256"}]}
+]`
+
+var (
+	parsedTokenABI = mustParseABI(tokenBalanceABI)
+
+	rpcClient   *ethclient.Client
+	rpcClientMu sync.Mutex
+)
+
+// EnsureWalletIndexes makes a wallet linkable to one account only, so a single holder
+// cannot unlock gated products and holder discounts for other accounts
+func EnsureWalletIndexes(ctx context.Context) error {
+	_, err := database.DB.Collection("users").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "wallets.address", Value: 1}},
+		Options: options.Index().
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{"wallets.address": bson.M{"$exists": true}}),
+	})
+	return err
+}
+
+func mustParseABI(definition string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(definition))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+// RPCClient returns a shared client for WEB3_RPC_URL, dialing it on first use
+func RPCClient() (*ethclient.Client, error) {
+	rpcClientMu.Lock()
+	defer rpcClientMu.Unlock()
+
+	if rpcClient != nil {
+		return rpcClient, nil
+	}
+
+	client, err := ethclient.Dial(config.GetEnv("WEB3_RPC_URL", ""))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Ethereum client: %v", err)
+	}
+
+	rpcClient = client
+	return rpcClient, nil
+}
+
+// ValidateTokenRequirement checks that a gating rule can be evaluated on-chain
+func ValidateTokenRequirement(req models.TokenRequirement) error {
+	if !common.IsHexAddress(req.Contract) {
+		return errors.New("invalid token contract address")
+	}
+	if req.MinBalance < 1 {
+		return errors.New("minimum balance must be at least 1")
+	}
+
+	switch req.Standard {
+	case models.TokenStandardERC721:
+		return nil
+	case models.TokenStandardERC1155:
+		if _, ok := new(big.Int).SetString(req.TokenID, 10); !ok {
+			return errors.New("ERC1155 requirements need a numeric token ID")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported token standard %q", req.Standard)
+	}
+}
+
+// maxDiscountBps is a 100% discount; anything above it would make prices negative
+const maxDiscountBps = 10000
+
+// ValidateHolderDiscount checks the discount's token rule and that it takes off more
+// than nothing and at most the whole price
+func ValidateHolderDiscount(discount models.HolderDiscount) error {
+	if err := ValidateTokenRequirement(discount.TokenRequirement); err != nil {
+		return err
+	}
+	if discount.DiscountBps <= 0 || discount.DiscountBps > maxDiscountBps {
+		return errors.New("discount must be between 1 and 10000 basis points")
+	}
+	return nil
+}
+
+// TokenBalance calls balanceOf on the requirement's contract for owner
+func TokenBalance(ctx context.Context, req models.TokenRequirement, owner string) (*big.Int, error) {
+	client, err := RPCClient()
+	if err != nil {
+		return nil, err
+	}
+
+	// The ABI parser names the overloaded ERC1155 balanceOf(address,uint256) "balanceOf0"
+	var data []byte
+	if req.Standard == models.TokenStandardERC1155 {
+		tokenID, _ := new(big.Int).SetString(req.TokenID, 10)
+		data, err = parsedTokenABI.Pack("balanceOf0", common.HexToAddress(owner), tokenID)
+	} else {
+		data, err = parsedTokenABI.Pack("balanceOf", common.HexToAddress(owner))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	contract := common.HexToAddress(req.Contract)
+	output, err := client.CallContract(ctx, ethereum.CallMsg{To: &contract, Data: data}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("balanceOf call failed: %v", err)
+	}
+
+	return new(big.Int).SetBytes(output), nil
+}
+
+// MeetsTokenRequirement reports whether any of the wallets holds enough of the token
+func MeetsTokenRequirement(ctx context.Context, req models.TokenRequirement, wallets []string) (bool, error) {
+	minBalance := big.NewInt(req.MinBalance)
+	for _, wallet := range wallets {
+		balance, err := TokenBalance(ctx, req, wallet)
+		if err != nil {
+			return false, err
+		}
+		if balance.Cmp(minBalance) >= 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// BestHolderDiscount returns the largest discount, in basis points, the wallets qualify for.
+// Discounts out of range, which only products saved before validation can hold, are ignored.
+func BestHolderDiscount(ctx context.Context, discounts []models.HolderDiscount, wallets []string) (int64, error) {
+	var best int64
+	for _, discount := range discounts {
+		if discount.DiscountBps <= best || discount.DiscountBps > maxDiscountBps {
+			continue
+		}
+
+		ok, err := MeetsTokenRequirement(ctx, discount.TokenRequirement, wallets)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			best = discount.DiscountBps
+		}
+	}
+	return best, nil
+}
@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+)
+
+func TestValidateHolderDiscount(t *testing.T) {
+	nft := models.TokenRequirement{
+		Standard:   models.TokenStandardERC721,
+		Contract:   "0x1111111111111111111111111111111111111111",
+		MinBalance: 1,
+	}
+
+	valid := []int64{1, 2500, 10000}
+	for _, bps := range valid {
+		if err := ValidateHolderDiscount(models.HolderDiscount{TokenRequirement: nft, DiscountBps: bps}); err != nil {
+			t.Errorf("%d bps rejected: %v", bps, err)
+		}
+	}
+
+	invalid := []int64{-500, 0, 10001, 20000}
+	for _, bps := range invalid {
+		if err := ValidateHolderDiscount(models.HolderDiscount{TokenRequirement: nft, DiscountBps: bps}); err == nil {
+			t.Errorf("%d bps accepted", bps)
+		}
+	}
+
+	if err := ValidateHolderDiscount(models.HolderDiscount{DiscountBps: 1000}); err == nil {
+		t.Error("discount without a token rule accepted")
+	}
+}
+
+func TestBestHolderDiscountIgnoresOutOfRangeDiscounts(t *testing.T) {
+	// Checked before any balance is looked up, so no RPC client is needed
+	discounts := []models.HolderDiscount{{DiscountBps: 15000}, {DiscountBps: -100}}
+
+	best, err := BestHolderDiscount(context.Background(), discounts, []string{"0x2222222222222222222222222222222222222222"})
+	if err != nil || best != 0 {
+		t.Errorf("BestHolderDiscount = %d, %v, want 0", best, err)
+	}
+}
@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+// Base32 of the RFC 6238 SHA-1 seed "12345678901234567890"
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// RFC 6238 appendix B SHA-1 values, cut to the last six of their eight digits
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestTOTPCodeMatchesRFC6238(t *testing.T) {
+	key := []byte("12345678901234567890")
+
+	for _, tc := range rfc6238Vectors {
+		if got := totpCode(key, uint64(tc.unix/totpPeriod)); got != tc.code {
+			t.Errorf("T=%d: code = %s, want %s", tc.unix, got, tc.code)
+		}
+
+		step, ok := ValidateTOTP(rfc6238Secret, tc.code, time.Unix(tc.unix, 0))
+		if !ok || step != tc.unix/totpPeriod {
+			t.Errorf("T=%d: ValidateTOTP = (%d, %v), want (%d, true)", tc.unix, step, ok, tc.unix/totpPeriod)
+		}
+	}
+}
+
+func TestValidateTOTPSkewWindow(t *testing.T) {
+	key := []byte("12345678901234567890")
+	now := time.Unix(1234567890, 0)
+	current := now.Unix() / totpPeriod
+
+	tests := []struct {
+		offset int64
+		want   bool
+	}{
+		{-2, false},
+		{-1, true},
+		{0, true},
+		{1, true},
+		{2, false},
+	}
+
+	for _, tc := range tests {
+		code := totpCode(key, uint64(current+tc.offset))
+		step, ok := ValidateTOTP(rfc6238Secret, code, now)
+		if ok != tc.want {
+			t.Errorf("step offset %d: accepted = %v, want %v", tc.offset, ok, tc.want)
+		}
+		if ok && step != current+tc.offset {
+			t.Errorf("step offset %d: matched step %d, want %d", tc.offset, step, current+tc.offset)
+		}
+	}
+}
+
+func TestValidateTOTPRejectsMalformedInput(t *testing.T) {
+	now := time.Unix(59, 0)
+
+	for _, tc := range []struct{ secret, code string }{
+		{rfc6238Secret, "28708"},
+		{rfc6238Secret, "2870820"},
+		{"not base32!", "287082"},
+	} {
+		if _, ok := ValidateTOTP(tc.secret, tc.code, now); ok {
+			t.Errorf("ValidateTOTP(%q, %q) accepted", tc.secret, tc.code)
+		}
+	}
+}
+
+func TestValidateTOTPAfterRejectsReplay(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := now.Unix() / totpPeriod
+
+	if got, ok := ValidateTOTPAfter(rfc6238Secret, "050471", now, step-1); !ok || got != step {
+		t.Fatalf("first use = (%d, %v), want (%d, true)", got, ok, step)
+	}
+	if _, ok := ValidateTOTPAfter(rfc6238Secret, "050471", now, step); ok {
+		t.Error("code accepted again after its step was used")
+	}
+
+	// A code from an earlier step inside the skew window is not valid after a later one
+	earlier := totpCode([]byte("12345678901234567890"), uint64(step-1))
+	if _, ok := ValidateTOTPAfter(rfc6238Secret, earlier, now, step); ok {
+		t.Error("code from an earlier step accepted after a later step was used")
+	}
+}
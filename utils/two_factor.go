@@ -0,0 +1,148 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/config"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/database"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+	"github.com/golang-jwt/jwt"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	// Audience of the token handed out between the password and the second factor
+	twoFactorChallengeAudience = "2fa_challenge"
+	TwoFactorChallengeTTL      = 5 * time.Minute
+
+	recoveryCodeCount = 10
+)
+
+var ErrInvalidChallenge = errors.New("invalid or expired two-factor challenge")
+
+// TwoFactorRequiredRoles lists the roles that only apply to sessions that passed
+// two-factor authentication, configurable with REQUIRE_2FA_ROLES (comma separated)
+func TwoFactorRequiredRoles() []string {
+	var roles []string
+	for _, role := range strings.Split(config.GetEnv("REQUIRE_2FA_ROLES", models.RoleAdmin), ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// EffectiveRoles drops the roles that require two-factor authentication when the
+// session did not pass it
+func EffectiveRoles(roles []string, mfa bool) []string {
+	if mfa {
+		return roles
+	}
+
+	required := TwoFactorRequiredRoles()
+	effective := make([]string, 0, len(roles))
+	for _, role := range roles {
+		if !containsString(required, role) {
+			effective = append(effective, role)
+		}
+	}
+	return effective
+}
+
+type twoFactorChallengeClaims struct {
+	UserID string `json:"userId"`
+	jwt.StandardClaims
+}
+
+// IssueTwoFactorChallenge returns a short-lived token proving the password step passed
+func IssueTwoFactorChallenge(userID string) (string, error) {
+	now := time.Now()
+	return signToken(&twoFactorChallengeClaims{
+		UserID: userID,
+		StandardClaims: jwt.StandardClaims{
+			Audience:  twoFactorChallengeAudience,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(TwoFactorChallengeTTL).Unix(),
+		},
+	})
+}
+
+// ValidateTwoFactorChallenge returns the user ID of a valid challenge token
+func ValidateTwoFactorChallenge(tokenString string) (string, error) {
+	claims := &twoFactorChallengeClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, verificationKey)
+	if err != nil || !token.Valid || !claims.VerifyAudience(twoFactorChallengeAudience, true) {
+		return "", ErrInvalidChallenge
+	}
+	return claims.UserID, nil
+}
+
+// VerifyTwoFactorCode accepts a current TOTP code or an unused recovery code. Both are
+// consumed atomically, so the same code cannot be used by two concurrent requests.
+func VerifyTwoFactorCode(ctx context.Context, user models.User, code string) (bool, error) {
+	if user.TwoFactor == nil || !user.TwoFactor.Enabled {
+		return false, nil
+	}
+
+	code = normalizeTwoFactorCode(code)
+	collection := database.DB.Collection("users")
+
+	if step, ok := ValidateTOTPAfter(user.TwoFactor.Secret, code, time.Now(), user.TwoFactor.LastUsedStep); ok {
+		result, err := collection.UpdateOne(
+			ctx,
+			bson.M{"_id": user.ID, "twoFactor.lastUsedStep": bson.M{"$not": bson.M{"$gte": step}}},
+			bson.M{"$set": bson.M{"twoFactor.lastUsedStep": step}},
+		)
+		if err != nil {
+			return false, err
+		}
+		return result.ModifiedCount == 1, nil
+	}
+
+	hash := HashToken(code)
+	result, err := collection.UpdateOne(
+		ctx,
+		bson.M{"_id": user.ID, "twoFactor.recoveryCodes": hash},
+		bson.M{"$pull": bson.M{"twoFactor.recoveryCodes": hash}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// GenerateRecoveryCodes returns codes to show the user once and the hashes to store
+func GenerateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+
+	for i := range codes {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		raw := strings.ToLower(totpEncoding.EncodeToString(b))[:10]
+		codes[i] = raw[:5] + "-" + raw[5:]
+		hashes[i] = HashToken(raw)
+	}
+	return codes, hashes, nil
+}
+
+// normalizeTwoFactorCode strips the spacing and dashes users type or paste
+func normalizeTwoFactorCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer(" ", "", "-", "").Replace(code)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
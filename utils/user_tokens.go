@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/database"
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrInvalidUserToken = errors.New("invalid or expired token")
+
+// IssueUserToken creates a token for the purpose and discards the user's earlier
+// unused ones, so only the most recently mailed link works
+func IssueUserToken(ctx context.Context, userID primitive.ObjectID, purpose models.UserTokenPurpose, email string, ttl time.Duration) (string, error) {
+	collection := database.DB.Collection("user_tokens")
+
+	_, err := collection.DeleteMany(ctx, bson.M{
+		"userId":  userID,
+		"purpose": purpose,
+		"usedAt":  bson.M{"$exists": false},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	rawToken, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	_, err = collection.InsertOne(ctx, models.UserToken{
+		ID:        primitive.NewObjectID(),
+		UserID:    userID,
+		Purpose:   purpose,
+		Email:     email,
+		TokenHash: HashToken(rawToken),
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return rawToken, nil
+}
+
+// ConsumeUserToken marks a token as used and returns it; a token can only be consumed once
+func ConsumeUserToken(ctx context.Context, rawToken string, purpose models.UserTokenPurpose) (models.UserToken, error) {
+	now := time.Now()
+
+	var token models.UserToken
+	err := database.DB.Collection("user_tokens").FindOneAndUpdate(
+		ctx,
+		bson.M{
+			"tokenHash": HashToken(rawToken),
+			"purpose":   purpose,
+			"usedAt":    bson.M{"$exists": false},
+			"expiresAt": bson.M{"$gt": now},
+		},
+		bson.M{"$set": bson.M{"usedAt": now}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&token)
+
+	if err == mongo.ErrNoDocuments {
+		return models.UserToken{}, ErrInvalidUserToken
+	}
+	return token, err
+}
+
+// LastUserTokenIssuedAt returns when the user was last sent a token for the purpose
+func LastUserTokenIssuedAt(ctx context.Context, userID primitive.ObjectID, purpose models.UserTokenPurpose) (time.Time, error) {
+	var token models.UserToken
+	err := database.DB.Collection("user_tokens").FindOne(
+		ctx,
+		bson.M{"userId": userID, "purpose": purpose},
+		options.FindOne().SetSort(bson.M{"createdAt": -1}),
+	).Decode(&token)
+
+	if err == mongo.ErrNoDocuments {
+		return time.Time{}, nil
+	}
+	return token.CreatedAt, err
+}
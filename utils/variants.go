@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Madhav-Gupta-28/0xmart-backend-go/database"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MaxSKULength is the longest SKU accepted, including generated ones
+const MaxSKULength = 64
+
+// EnsureProductIndexes makes SKUs unique across products and indexes the fields
+// product listings sort on. Duplicate SKUs within one product are caught by
+// validation, which a multikey index can't do.
+func EnsureProductIndexes(ctx context.Context) error {
+	indexes := []mongo.IndexModel{{
+		Keys: bson.D{{Key: "variants.sku", Value: 1}},
+		Options: options.Index().
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{"variants.sku": bson.M{"$exists": true}}),
+	}}
+	for _, field := range []string{"createdAt", "priceUSD", "avgRating", "ratingCount"} {
+		indexes = append(indexes, mongo.IndexModel{Keys: bson.D{{Key: field, Value: 1}, {Key: "_id", Value: 1}}})
+	}
+
+	_, err := database.DB.Collection("products").Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// BackfillRatingCounts sets ratingCount on products rated before it was maintained
+func BackfillRatingCounts(ctx context.Context) error {
+	_, err := database.DB.Collection("products").UpdateMany(
+		ctx,
+		bson.M{"ratingCount": bson.M{"$exists": false}},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{"ratingCount": bson.M{"$size": bson.M{"$ifNull": bson.A{"$ratings", bson.A{}}}}}}}},
+	)
+	return err
+}
+
+// GenerateSKU builds a SKU from the product ID and the variant's option values, in
+// the order the product lists its options, e.g. "65F0C2...-M-BLACK". Long values are
+// cut to keep it within MaxSKULength.
+func GenerateSKU(productID primitive.ObjectID, values []string) string {
+	parts := []string{strings.ToUpper(productID.Hex())}
+	for _, value := range values {
+		var b strings.Builder
+		for _, r := range strings.ToUpper(value) {
+			if r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+				b.WriteRune(r)
+			}
+		}
+		if b.Len() > 0 {
+			parts = append(parts, b.String())
+		}
+	}
+	sku := strings.Join(parts, "-")
+	if len(sku) > MaxSKULength {
+		sku = strings.TrimRight(sku[:MaxSKULength], "-")
+	}
+	return sku
+}
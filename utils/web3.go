@@ -15,6 +15,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -114,6 +115,17 @@ func (b *BlockchainEventListener) Start() error {
 	sub, err := b.client.SubscribeFilterLogs(context.Background(), query, logs)
 	if err != nil {
 		log.Printf("❌ Failed to subscribe to contract events: %v", err)
+		b.stopListening()
+		return err
+	}
+
+	// New heads drive the confirmation counts pushed to clients
+	heads := make(chan *types.Header)
+	headSub, err := b.client.SubscribeNewHead(context.Background(), heads)
+	if err != nil {
+		log.Printf("❌ Failed to subscribe to new blocks: %v", err)
+		sub.Unsubscribe()
+		b.stopListening()
 		return err
 	}
 
@@ -125,33 +137,60 @@ func (b *BlockchainEventListener) Start() error {
 			select {
 			case err := <-sub.Err():
 				log.Printf("❌ Subscription error: %v", err)
+				headSub.Unsubscribe()
+				b.Restart()
+				return
+			case err := <-headSub.Err():
+				log.Printf("❌ Block subscription error: %v", err)
+				sub.Unsubscribe()
 				b.Restart()
+				return
+			case header := <-heads:
+				AdvanceConfirmations(header.Number.Uint64())
 			case vLog := <-logs:
 				log.Printf("📥 Received event: %+v", vLog)
 
+				// A reorg re-delivers logs of dropped blocks with Removed set
+				if vLog.Removed {
+					b.reverse(vLog)
+					continue
+				}
+
 				// Parse the event data
 				tx := b.parseTransactionEvent(vLog)
 				if tx != nil {
 					log.Printf("📦 Parsed transaction: %+v", tx)
 
-					// Store in MongoDB
-					collection := database.DB.Collection("transactions")
+					// Quote expiry is judged by when the payment was mined, not when it reached us
 					ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-					result, err := collection.InsertOne(ctx, tx)
+					if header, err := b.client.HeaderByHash(ctx, vLog.BlockHash); err == nil {
+						tx.BlockTime = time.Unix(int64(header.Time), 0)
+					} else {
+						log.Printf("⚠️ Failed to fetch block %s: %v", vLog.BlockHash.Hex(), err)
+					}
+
+					// Store in MongoDB
+					transactionID, err := StoreTransaction(ctx, tx)
 					cancel()
 
 					if err != nil {
 						log.Printf("❌ Failed to store transaction: %v", err)
 						continue
 					}
+					if transactionID == nil {
+						log.Printf("⏭️ Skipping already processed event %s", tx.PaymentKey())
+						continue
+					}
 
-					log.Printf("✅ Stored transaction with ID: %v", result.InsertedID)
+					log.Printf("✅ Stored transaction with ID: %v", transactionID)
 					log.Printf("📊 Transaction Details:")
 					log.Printf("   Order ID: %d", tx.OrderID)
 					log.Printf("   Customer: %s", tx.CustomerAddress)
 					log.Printf("   Amount: %s", tx.Amount)
 					log.Printf("   Status: %s", tx.Status)
 					log.Println("----------------------------------------")
+
+					b.reconcile(transactionID, tx)
 				} else {
 					log.Printf("⚠️ Failed to parse event data")
 				}
@@ -182,6 +221,15 @@ func (b *BlockchainEventListener) parseTransactionEvent(vLog types.Log) *models.
 	orderID := new(big.Int).SetBytes(vLog.Topics[2][:]).Uint64()
 	amount := new(big.Int).SetBytes(vLog.Topics[3][:]).String()
 
+	// The first data word is the token paid in, the zero address for ETH. Events of
+	// contracts that only take ETH carry no data.
+	token := ""
+	if len(vLog.Data) >= 32 {
+		if address := common.BytesToAddress(vLog.Data[12:32]); address != (common.Address{}) {
+			token = address.Hex()
+		}
+	}
+
 	log.Printf("📝 Parsed values:")
 	log.Printf("   Order ID: %d", orderID)
 	log.Printf("   Customer: %s", customerAddress)
@@ -191,21 +239,82 @@ func (b *BlockchainEventListener) parseTransactionEvent(vLog types.Log) *models.
 		OrderID:         orderID,
 		CustomerAddress: customerAddress,
 		Amount:          amount,
+		Token:           token,
+		TxHash:          vLog.TxHash.Hex(),
+		LogIndex:        vLog.Index,
+		BlockNumber:     vLog.BlockNumber,
 		Timestamp:       time.Now(),
 		Status:          "completed",
 	}
 }
 
+// reconcile applies a stored transaction to its order and records the outcome
+func (b *BlockchainEventListener) reconcile(transactionID interface{}, tx *models.Transaction) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	status := "reconciled"
+	if err := ReconcilePayment(ctx, tx); err != nil {
+		log.Printf("❌ Failed to reconcile order %d: %v", tx.OrderID, err)
+		status = "unmatched"
+		// Payments no quote authorized are refunded in full
+		if errors.Is(err, ErrQuoteMismatch) {
+			status = "rejected"
+		}
+	}
+
+	_, err := database.DB.Collection("transactions").UpdateOne(
+		ctx,
+		bson.M{"_id": transactionID},
+		bson.M{"$set": bson.M{"status": status}},
+	)
+	if err != nil {
+		log.Printf("❌ Failed to update transaction status: %v", err)
+	}
+}
+
+// reverse takes back a payment whose log was removed by a chain reorganisation
+func (b *BlockchainEventListener) reverse(vLog types.Log) {
+	tx := b.parseTransactionEvent(vLog)
+	if tx == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	log.Printf("⚠️ Payment %s was removed by a reorg", tx.PaymentKey())
+	UntrackPaymentConfirmations(tx.TxHash)
+
+	if err := ReversePayment(ctx, tx); err != nil {
+		log.Printf("❌ Failed to reverse payment for order %d: %v", tx.OrderID, err)
+	}
+
+	_, err := database.DB.Collection("transactions").UpdateOne(
+		ctx,
+		bson.M{"txHash": tx.TxHash, "logIndex": tx.LogIndex},
+		bson.M{"$set": bson.M{"status": "removed"}},
+	)
+	if err != nil {
+		log.Printf("❌ Failed to update transaction status: %v", err)
+	}
+}
+
 func (b *BlockchainEventListener) Restart() error {
 	if !b.isListening {
 		return errors.New("not currently listening")
 	}
 
 	log.Println("🔄 Restarting blockchain event listener...")
+	b.stopListening()
+	time.Sleep(5 * time.Second)
+	return b.Start()
+}
+
+// stopListening closes the connection so that Start can be called again
+func (b *BlockchainEventListener) stopListening() {
 	b.isListening = false
 	if b.client != nil {
 		b.client.Close()
 	}
-	time.Sleep(5 * time.Second)
-	return b.Start()
 }